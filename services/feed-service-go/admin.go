@@ -0,0 +1,178 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "log"
+    "net/http"
+    "sort"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+)
+
+// CachedKey describes one cache entry. TTLSeconds is -1 for keys without expiry.
+type CachedKey struct {
+    Key        string  `json:"key"`
+    TTLSeconds float64 `json:"ttlSeconds"`
+    SizeBytes  int64   `json:"sizeBytes"`
+    Empty      bool    `json:"empty,omitempty"` // cached empty result
+}
+
+// CacheStats is the response of GET /admin/users/:id/cache-stats.
+type CacheStats struct {
+    Success bool        `json:"success"`
+    UserID  string      `json:"userId"`
+    Pages   []CachedKey `json:"pages"`
+    // Present while force-refreshes are being debounced
+    RefreshMarker *CachedKey `json:"refreshMarker,omitempty"`
+}
+
+// GetCacheStats lists a user's cached feed pages with their TTLs and sizes,
+// which usually explains "my feed isn't updating" reports: a long-lived empty
+// page, or a refresh still inside its debounce window.
+func (fs *FeedService) GetCacheStats(c *gin.Context) {
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    userID := c.Param("id")
+
+    keys, err := fs.cache.Keys(ctx, userFeedKeyPattern(userID))
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to read cache")
+        return
+    }
+    sort.Strings(keys)
+
+    stats := CacheStats{Success: true, UserID: userID, Pages: []CachedKey{}}
+    for _, key := range keys {
+        entry, ok := fs.inspectKey(ctx, key)
+        if !ok {
+            continue // expired between Keys and Inspect
+        }
+        _, _, markerErr := fs.cache.Inspect(ctx, emptyFeedMarkerPrefix+key)
+        entry.Empty = markerErr == nil
+        stats.Pages = append(stats.Pages, entry)
+    }
+
+    if entry, ok := fs.inspectKey(ctx, refreshMarkerKey(userID)); ok {
+        stats.RefreshMarker = &entry
+    }
+
+    respondJSON(c, http.StatusOK, stats)
+}
+
+func (fs *FeedService) inspectKey(ctx context.Context, key string) (CachedKey, bool) {
+    ttl, size, err := fs.cache.Inspect(ctx, key)
+    if err != nil {
+        return CachedKey{}, false
+    }
+    entry := CachedKey{Key: key, TTLSeconds: -1, SizeBytes: size}
+    if ttl >= 0 {
+        entry.TTLSeconds = ttl.Seconds()
+    }
+    return entry, true
+}
+
+// purgeCachePatterns match every cached result that may embed a post: feed,
+// tag and search pages, discover sessions and trending with its stale copies.
+var purgeCachePatterns = []string{
+    "feed:*",
+    "tag:*",
+    "search:*",
+    "discover:*",
+    trendingKeyPattern,
+    staleTrendingPrefix + trendingKeyPattern,
+}
+
+// PurgePost removes a post outright for moderation, unlike DeletePost's soft
+// delete: the document goes, and so does every cached copy. The acting admin
+// is taken from X-Admin-ID for the audit log.
+func (fs *FeedService) PurgePost(c *gin.Context) {
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+    adminID := c.GetHeader("X-Admin-ID")
+    if adminID == "" {
+        adminID = "unknown"
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    // Loaded whatever its state, so a post already soft-deleted can still be purged
+    var post Post
+    err = fs.postsWriteCollection().FindOne(ctx, bson.M{"_id": postID}).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+        return
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to purge post")
+        return
+    }
+    if _, err := fs.postsWriteCollection().DeleteOne(ctx, bson.M{"_id": postID}); err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to purge post")
+        return
+    }
+    log.Printf("Moderation: admin %s purged post %s by author %s", adminID, postID.Hex(), post.Author.Hex())
+
+    recipients, err := fs.postRecipients(ctx, post)
+    if err != nil {
+        log.Printf("Failed to resolve recipients for post %s: %v", postID.Hex(), err)
+    }
+    recipients = append(recipients, post.Author.Hex())
+    if err := fs.publishEvent(ctx, recipients, "post_removed", gin.H{"postId": postID.Hex()}); err != nil {
+        log.Printf("Failed to publish post_removed event: %v", err)
+    }
+
+    // The document is already gone, so a scan cut short is reported but does
+    // not undo the purge; keys it missed expire with their TTLs
+    scanCtx, scanCancel := context.WithTimeout(c.Request.Context(), fs.cacheInvalidateTimeout)
+    defer scanCancel()
+    fs.invalidatePost(scanCtx, postID)
+    fs.invalidateStats(scanCtx, post.Author)
+    fs.cache.DeleteMatching(scanCtx, "render:"+postID.Hex()+":*")
+    deleted, err := fs.dropCachedContaining(scanCtx, purgeCachePatterns, []byte(postID.Hex()))
+    if err != nil {
+        log.Printf("Cache purge for post %s incomplete after %d keys: %v", postID.Hex(), deleted, err)
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":       true,
+        "postId":        postID.Hex(),
+        "keysDeleted":   deleted,
+        "cacheComplete": err == nil,
+    })
+}
+
+// dropCachedContaining deletes the keys under patterns whose value contains
+// needle, and returns how many it deleted. Reading each value keeps the
+// purge from evicting every feed in the cache at once.
+func (fs *FeedService) dropCachedContaining(ctx context.Context, patterns []string, needle []byte) (int64, error) {
+    var deleted int64
+    for _, pattern := range patterns {
+        keys, err := fs.cache.Keys(ctx, pattern)
+        if err != nil {
+            return deleted, err
+        }
+        var matching []string
+        for _, key := range keys {
+            if data, err := fs.cache.Get(ctx, key); err == nil && bytes.Contains(data, needle) {
+                matching = append(matching, key)
+            }
+        }
+        if len(matching) == 0 {
+            continue
+        }
+        n, err := fs.cache.Del(ctx, matching...)
+        deleted += n
+        if err != nil {
+            return deleted, err
+        }
+    }
+    return deleted, nil
+}
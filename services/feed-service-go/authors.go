@@ -0,0 +1,103 @@
+package main
+
+import (
+    "crypto/sha1"
+    "encoding/hex"
+    "fmt"
+    "net/http"
+    "sort"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FeedModeAuthors limits the feed to an explicit set of authors, e.g. a
+// user-curated list.
+const FeedModeAuthors = "authors"
+
+type AuthorsFeedRequest struct {
+    UserID    string   `json:"userId"`
+    AuthorIDs []string `json:"authorIds"`
+    Page      int      `json:"page"`
+    Limit     int      `json:"limit"`
+    Cursor    string   `json:"cursor,omitempty"`
+}
+
+// normalizeAuthors parses, dedupes and sorts author IDs so the same list in
+// any order shares one cache entry.
+func normalizeAuthors(ids []string) ([]primitive.ObjectID, error) {
+    seen := make(map[primitive.ObjectID]bool, len(ids))
+    authors := make([]primitive.ObjectID, 0, len(ids))
+    for _, id := range ids {
+        oid, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            return nil, fmt.Errorf("invalid author id %q", id)
+        }
+        if !seen[oid] {
+            seen[oid] = true
+            authors = append(authors, oid)
+        }
+    }
+    sort.Slice(authors, func(i, j int) bool { return authors[i].Hex() < authors[j].Hex() })
+    return authors, nil
+}
+
+// authorSetHash identifies a normalized author set in feed cache keys. Any
+// sorted ID set hashes the same way, so seenIds reuse it.
+func authorSetHash(authors []primitive.ObjectID) string {
+    h := sha1.New()
+    for _, author := range authors {
+        h.Write(author[:])
+    }
+    return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// GetAuthorsFeed serves a chronological feed restricted to the given authors,
+// with the same visibility rules as the personalized feed.
+func (fs *FeedService) GetAuthorsFeed(c *gin.Context) {
+    var body AuthorsFeedRequest
+    if !bindJSON(c, &body) {
+        return
+    }
+    if len(body.AuthorIDs) == 0 {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "authorIds is required")
+        return
+    }
+    if len(body.AuthorIDs) > fs.maxFeedAuthors {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("At most %d authors", fs.maxFeedAuthors))
+        return
+    }
+
+    authors, err := normalizeAuthors(body.AuthorIDs)
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+        return
+    }
+
+    req := FeedRequest{
+        UserID:  body.UserID,
+        Page:    body.Page,
+        Limit:   body.Limit,
+        Cursor:  body.Cursor,
+        Mode:    FeedModeAuthors,
+        Authors: authors,
+    }
+    if !fs.applyFeedCursor(c, &req) {
+        return
+    }
+    if !fs.applyPageDefaults(c, &req) {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    result, err := fs.getOrBuildFeed(ctx, req)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch feed")
+        return
+    }
+    fs.recordImpressions(req.UserID, result.Posts)
+
+    respondJSON(c, http.StatusOK, fs.newFeedResponse(req, result))
+}
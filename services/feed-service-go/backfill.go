@@ -0,0 +1,45 @@
+package main
+
+import (
+    "context"
+    "log"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// backfillTimeframe is the trending window used to top up short feed pages.
+const backfillTimeframe = "7d"
+
+// backfillFeed tops up a short first page (typically a new user with few
+// followed accounts) with trending posts so the feed is never empty.
+// Backfilled posts are flagged and never duplicate posts already on the page.
+func (fs *FeedService) backfillFeed(ctx context.Context, posts []Post, page, limit int) []Post {
+    if !fs.feedBackfill || page != 1 || len(posts) >= limit {
+        return posts
+    }
+
+    candidates, err := fs.fetchTrendingFromDB(ctx, TrendingQuery{Timeframe: backfillTimeframe, Limit: limit})
+    if err != nil {
+        log.Printf("Feed backfill failed: %v", err)
+        return posts
+    }
+
+    seen := make(map[primitive.ObjectID]bool, len(posts))
+    for _, post := range posts {
+        seen[post.ID] = true
+    }
+
+    for _, candidate := range candidates.Posts {
+        if len(posts) >= limit {
+            break
+        }
+        if seen[candidate.ID] {
+            continue
+        }
+        seen[candidate.ID] = true
+        candidate.Backfilled = true
+        candidate.TrendingScore = 0 // feed pages are not ranked by it
+        posts = append(posts, candidate)
+    }
+    return posts
+}
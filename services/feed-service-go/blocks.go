@@ -0,0 +1,165 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Block hides one author's posts from a user's feed and trending. It is
+// one-way: the author is not told and still sees the user's posts.
+type Block struct {
+    ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+    User      primitive.ObjectID `bson:"user" json:"user"`
+    Author    primitive.ObjectID `bson:"author" json:"author"`
+    CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+var blockIndex = mongo.IndexModel{
+    Keys:    bson.D{{Key: "user", Value: 1}, {Key: "author", Value: 1}},
+    Options: options.Index().SetName("blocks_user_author").SetUnique(true),
+}
+
+func (fs *FeedService) blocksCollection() *mongo.Collection {
+    return fs.mongo.Database("crown-social").Collection("blocks")
+}
+
+func blocksCacheKey(userID primitive.ObjectID) string {
+    return "blocks:" + userID.Hex()
+}
+
+// blockedAuthorIDs returns the authors the user has blocked, cached for
+// BLOCKS_CACHE_TTL. Changes through the block endpoints drop the cached copy,
+// so the TTL only bounds how long edits made elsewhere take to apply.
+func (fs *FeedService) blockedAuthorIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+    key := blocksCacheKey(userID)
+    if cached, err := fs.cache.Get(ctx, key); err == nil {
+        var ids []primitive.ObjectID
+        if json.Unmarshal(cached, &ids) == nil {
+            return ids, nil
+        }
+    }
+
+    opts := options.Find().SetProjection(bson.M{"author": 1})
+    cursor, err := fs.blocksCollection().Find(ctx, bson.M{"user": userID}, opts)
+    if err != nil {
+        return nil, err
+    }
+    var blocks []Block
+    if err := cursor.All(ctx, &blocks); err != nil {
+        return nil, err
+    }
+
+    ids := make([]primitive.ObjectID, 0, len(blocks))
+    for _, block := range blocks {
+        ids = append(ids, block.Author)
+    }
+    if data, err := json.Marshal(ids); err == nil {
+        fs.cache.Set(ctx, key, data, fs.blocksCacheTTL)
+    }
+    return ids, nil
+}
+
+// withoutAuthors drops posts by any of the given authors, for results that
+// cannot carry the block in their query: injected feed posts and trending,
+// which is cached for everyone.
+func withoutAuthors(posts []Post, authors []primitive.ObjectID) []Post {
+    if len(authors) == 0 {
+        return posts
+    }
+    kept := posts[:0:0]
+    for _, post := range posts {
+        if !containsID(authors, post.Author) {
+            kept = append(kept, post)
+        }
+    }
+    return kept
+}
+
+// BlockAuthor hides an author's posts from the caller. Blocking an author
+// already blocked succeeds without change.
+func (fs *FeedService) BlockAuthor(c *gin.Context) {
+    userID, authorID, ok := parseBlockParams(c)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    _, err := fs.blocksCollection().UpdateOne(ctx,
+        bson.M{"user": userID, "author": authorID},
+        bson.M{"$setOnInsert": bson.M{"createdAt": fs.now()}},
+        options.Update().SetUpsert(true),
+    )
+    if err != nil && !mongo.IsDuplicateKeyError(err) {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to block author")
+        return
+    }
+    fs.blocksChanged(ctx, userID)
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":  true,
+        "authorId": authorID.Hex(),
+        "blocked":  true,
+    })
+}
+
+func (fs *FeedService) UnblockAuthor(c *gin.Context) {
+    userID, authorID, ok := parseBlockParams(c)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    result, err := fs.blocksCollection().DeleteOne(ctx, bson.M{"user": userID, "author": authorID})
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to unblock author")
+        return
+    }
+    if result.DeletedCount == 0 {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Author not blocked")
+        return
+    }
+    fs.blocksChanged(ctx, userID)
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":  true,
+        "authorId": authorID.Hex(),
+        "blocked":  false,
+    })
+}
+
+func parseBlockParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return userID, primitive.NilObjectID, false
+    }
+    authorID, err := primitive.ObjectIDFromHex(c.Param("authorId"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid author id")
+        return userID, authorID, false
+    }
+    if authorID == userID {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Cannot block yourself")
+        return userID, authorID, false
+    }
+    return userID, authorID, true
+}
+
+// blocksChanged drops the cached block list and every cached feed page of
+// the user, which were built against the old list.
+func (fs *FeedService) blocksChanged(ctx context.Context, userID primitive.ObjectID) {
+    fs.cache.Del(ctx, blocksCacheKey(userID))
+    fs.invalidateUserFeeds(ctx, userID.Hex())
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Bookmark is one saved post in the bookmarks collection.
+type Bookmark struct {
+    ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+    User      primitive.ObjectID `bson:"user" json:"user"`
+    Post      primitive.ObjectID `bson:"post" json:"post"`
+    CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+}
+
+// bookmarkIndex makes a user's bookmark of a post unique; bookmarkListIndex
+// serves the saved list newest first.
+var bookmarkIndex = mongo.IndexModel{
+    Keys:    bson.D{{Key: "user", Value: 1}, {Key: "post", Value: 1}},
+    Options: options.Index().SetName("bookmarks_user_post").SetUnique(true),
+}
+
+var bookmarkListIndex = mongo.IndexModel{
+    Keys:    bson.D{{Key: "user", Value: 1}, {Key: "createdAt", Value: -1}},
+    Options: options.Index().SetName("bookmarks_user_createdAt"),
+}
+
+func (fs *FeedService) bookmarksCollection() *mongo.Collection {
+    return fs.mongo.Database("crown-social").Collection("bookmarks")
+}
+
+// SavePost bookmarks a post the caller can see. Saving it twice is a 409.
+func (fs *FeedService) SavePost(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+    if _, ok := fs.loadVisiblePost(c, postID); !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    bookmark := Bookmark{
+        ID:        primitive.NewObjectID(),
+        User:      userID,
+        Post:      postID,
+        CreatedAt: fs.now(),
+    }
+    if _, err := fs.bookmarksCollection().InsertOne(ctx, bookmark); err != nil {
+        if mongo.IsDuplicateKeyError(err) {
+            respondError(c, http.StatusConflict, ErrCodeConflict, "Post already saved")
+            return
+        }
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to save post")
+        return
+    }
+
+    respondJSON(c, http.StatusCreated, gin.H{
+        "success":  true,
+        "bookmark": bookmark,
+    })
+}
+
+func (fs *FeedService) UnsavePost(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    result, err := fs.bookmarksCollection().DeleteOne(ctx, bson.M{"user": userID, "post": postID})
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to unsave post")
+        return
+    }
+    if result.DeletedCount == 0 {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not saved")
+        return
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "postId":  postID.Hex(),
+    })
+}
+
+// GetSavedPosts lists the caller's saved posts, most recently saved first.
+// Bookmarks of posts since deleted, deactivated or hidden from the caller are
+// skipped, so a page may hold fewer than limit posts while hasMore is true.
+func (fs *FeedService) GetSavedPosts(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+
+    var req FeedRequest
+    if !parsePageQuery(c, &req) || !fs.applyPageDefaults(c, &req) {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetSkip(int64((req.Page - 1) * req.Limit)).
+        SetLimit(int64(req.Limit + 1))
+    cursor, err := fs.bookmarksCollection().Find(ctx, bson.M{"user": userID}, opts)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch saved posts")
+        return
+    }
+    var bookmarks []Bookmark
+    if err := cursor.All(ctx, &bookmarks); err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch saved posts")
+        return
+    }
+    // The extra bookmark only tells whether another page exists
+    hasMore := len(bookmarks) > req.Limit
+    if hasMore {
+        bookmarks = bookmarks[:req.Limit]
+    }
+
+    postIDs := make([]primitive.ObjectID, 0, len(bookmarks))
+    for _, bookmark := range bookmarks {
+        postIDs = append(postIDs, bookmark.Post)
+    }
+
+    rel, err := fs.resolveRelationship(ctx, userID)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch saved posts")
+        return
+    }
+    found, err := findPosts(ctx, fs.mongo.Database("crown-social").Collection("posts"),
+        bson.M{"_id": bson.M{"$in": postIDs}, "isActive": true, "deletedAt": nil}, options.Find())
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch saved posts")
+        return
+    }
+    byID := make(map[primitive.ObjectID]Post, len(found))
+    for _, post := range found {
+        if canView(post, rel) {
+            byID[post.ID] = post
+        }
+    }
+
+    posts := make([]Post, 0, len(found))
+    for _, id := range postIDs {
+        if post, ok := byID[id]; ok {
+            posts = append(posts, post)
+        }
+    }
+    fs.rewriteMediaURLs(posts)
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "posts":   posts,
+        "pagination": gin.H{
+            "page":    req.Page,
+            "limit":   req.Limit,
+            "hasMore": hasMore,
+        },
+    })
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "path"
+    "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/go-redis/redis/v8"
+)
+
+// ErrCacheMiss is returned by Cache.Get when the key is absent or expired.
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache is the storage used for feed/trending result caching. Pub/sub for
+// live updates is not part of it and always goes through Redis.
+type Cache interface {
+    Get(ctx context.Context, key string) ([]byte, error)
+    Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+    Del(ctx context.Context, keys ...string) (int64, error)
+    // Keys returns every key matching a glob-style pattern (e.g. "feed:123:*").
+    Keys(ctx context.Context, pattern string) ([]string, error)
+    // DeleteMatching deletes every key matching pattern in batches, returning
+    // how many were deleted before any error.
+    DeleteMatching(ctx context.Context, pattern string) (int64, error)
+    // Inspect reports a key's remaining TTL (-1 when it never expires) and
+    // value size; ErrCacheMiss when absent.
+    Inspect(ctx context.Context, key string) (time.Duration, int64, error)
+}
+
+// newCache builds the backend selected by CACHE_BACKEND (redis|memory).
+func newCache(backend string, redisClient *redis.Client) (Cache, error) {
+    switch backend {
+    case "", "redis":
+        return &redisCache{client: redisClient}, nil
+    case "memory":
+        return newMemoryCache(), nil
+    default:
+        return nil, fmt.Errorf("unknown CACHE_BACKEND %q", backend)
+    }
+}
+
+// redisCache is the production Cache backed by Redis.
+type redisCache struct {
+    client *redis.Client
+
+    // noUnlink is set once the server rejects UNLINK (Redis < 4)
+    noUnlink atomic.Bool
+}
+
+func (rc *redisCache) Get(ctx context.Context, key string) ([]byte, error) {
+    data, err := rc.client.Get(ctx, key).Bytes()
+    if err == redis.Nil {
+        return nil, ErrCacheMiss
+    }
+    return data, err
+}
+
+func (rc *redisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+    return rc.client.Set(ctx, key, value, ttl).Err()
+}
+
+func (rc *redisCache) Del(ctx context.Context, keys ...string) (int64, error) {
+    if len(keys) == 0 {
+        return 0, nil
+    }
+    return rc.client.Del(ctx, keys...).Result()
+}
+
+func (rc *redisCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+    var keys []string
+    iter := rc.client.Scan(ctx, 0, pattern, 0).Iterator()
+    for iter.Next(ctx) {
+        keys = append(keys, iter.Val())
+    }
+    return keys, iter.Err()
+}
+
+// scanBatchSize is the SCAN COUNT hint and the most keys sent in one delete.
+const scanBatchSize = 500
+
+// DeleteMatching deletes as the scan advances, so neither the key list nor
+// any single command grows with the keyspace. UNLINK frees values off the
+// main thread; servers without it get DEL.
+func (rc *redisCache) DeleteMatching(ctx context.Context, pattern string) (int64, error) {
+    var deleted int64
+    batch := make([]string, 0, scanBatchSize)
+    flush := func() error {
+        n, err := rc.unlink(ctx, batch)
+        deleted += n
+        batch = batch[:0]
+        return err
+    }
+
+    iter := rc.client.Scan(ctx, 0, pattern, scanBatchSize).Iterator()
+    for iter.Next(ctx) {
+        batch = append(batch, iter.Val())
+        if len(batch) == scanBatchSize {
+            if err := flush(); err != nil {
+                return deleted, err
+            }
+        }
+    }
+    if err := iter.Err(); err != nil {
+        return deleted, err
+    }
+    if len(batch) > 0 {
+        return deleted, flush()
+    }
+    return deleted, nil
+}
+
+func (rc *redisCache) unlink(ctx context.Context, keys []string) (int64, error) {
+    if !rc.noUnlink.Load() {
+        n, err := rc.client.Unlink(ctx, keys...).Result()
+        if err == nil || !strings.HasPrefix(err.Error(), "ERR unknown command") {
+            return n, err
+        }
+        rc.noUnlink.Store(true)
+    }
+    return rc.client.Del(ctx, keys...).Result()
+}
+
+func (rc *redisCache) Inspect(ctx context.Context, key string) (time.Duration, int64, error) {
+    pipe := rc.client.Pipeline()
+    ttlCmd := pipe.PTTL(ctx, key)
+    sizeCmd := pipe.StrLen(ctx, key)
+    if _, err := pipe.Exec(ctx); err != nil {
+        return 0, 0, err
+    }
+
+    // PTTL reports -2 for a missing key and -1 for one without expiry
+    ttl := ttlCmd.Val()
+    if ttl == -2 {
+        return 0, 0, ErrCacheMiss
+    }
+    if ttl < 0 {
+        ttl = -1
+    }
+    return ttl, sizeCmd.Val(), nil
+}
+
+// degradableCache fronts the Redis cache so the service can run without it.
+// While degraded every call is skipped outright, not attempted and failed, so
+// requests go straight to Mongo without paying a Redis timeout each time.
+// Invalidations skipped during an outage are lost: entries cached before it
+// may be served until their TTL once Redis is back.
+type degradableCache struct {
+    backend  Cache
+    degraded atomic.Bool
+}
+
+func (dc *degradableCache) Get(ctx context.Context, key string) ([]byte, error) {
+    if dc.degraded.Load() {
+        return nil, ErrCacheMiss
+    }
+    return dc.backend.Get(ctx, key)
+}
+
+func (dc *degradableCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+    if dc.degraded.Load() {
+        return nil
+    }
+    return dc.backend.Set(ctx, key, value, ttl)
+}
+
+func (dc *degradableCache) Del(ctx context.Context, keys ...string) (int64, error) {
+    if dc.degraded.Load() {
+        return 0, nil
+    }
+    return dc.backend.Del(ctx, keys...)
+}
+
+func (dc *degradableCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+    if dc.degraded.Load() {
+        return nil, nil
+    }
+    return dc.backend.Keys(ctx, pattern)
+}
+
+func (dc *degradableCache) DeleteMatching(ctx context.Context, pattern string) (int64, error) {
+    if dc.degraded.Load() {
+        return 0, nil
+    }
+    return dc.backend.DeleteMatching(ctx, pattern)
+}
+
+func (dc *degradableCache) Inspect(ctx context.Context, key string) (time.Duration, int64, error) {
+    if dc.degraded.Load() {
+        return 0, 0, ErrCacheMiss
+    }
+    return dc.backend.Inspect(ctx, key)
+}
+
+// memoryCache is an in-process Cache with TTL expiry for local development
+// and tests. Expired entries are dropped lazily on access.
+type memoryCache struct {
+    mu      sync.Mutex
+    entries map[string]memoryEntry
+}
+
+type memoryEntry struct {
+    value     []byte
+    expiresAt time.Time // zero means no expiry
+}
+
+func newMemoryCache() *memoryCache {
+    return &memoryCache{entries: make(map[string]memoryEntry)}
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+    return !e.expiresAt.IsZero() && now.After(e.expiresAt)
+}
+
+func (mc *memoryCache) Get(ctx context.Context, key string) ([]byte, error) {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    entry, ok := mc.entries[key]
+    if !ok {
+        return nil, ErrCacheMiss
+    }
+    if entry.expired(time.Now()) {
+        delete(mc.entries, key)
+        return nil, ErrCacheMiss
+    }
+    return entry.value, nil
+}
+
+func (mc *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+    entry := memoryEntry{value: append([]byte(nil), value...)}
+    if ttl > 0 {
+        entry.expiresAt = time.Now().Add(ttl)
+    }
+
+    mc.mu.Lock()
+    mc.entries[key] = entry
+    mc.mu.Unlock()
+    return nil
+}
+
+func (mc *memoryCache) Del(ctx context.Context, keys ...string) (int64, error) {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    var deleted int64
+    now := time.Now()
+    for _, key := range keys {
+        if entry, ok := mc.entries[key]; ok {
+            if !entry.expired(now) {
+                deleted++
+            }
+            delete(mc.entries, key)
+        }
+    }
+    return deleted, nil
+}
+
+func (mc *memoryCache) Keys(ctx context.Context, pattern string) ([]string, error) {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    var keys []string
+    now := time.Now()
+    for key, entry := range mc.entries {
+        if entry.expired(now) {
+            delete(mc.entries, key)
+            continue
+        }
+        if ok, err := path.Match(pattern, key); err != nil {
+            return nil, err
+        } else if ok {
+            keys = append(keys, key)
+        }
+    }
+    return keys, nil
+}
+
+func (mc *memoryCache) DeleteMatching(ctx context.Context, pattern string) (int64, error) {
+    keys, err := mc.Keys(ctx, pattern)
+    if err != nil {
+        return 0, err
+    }
+    return mc.Del(ctx, keys...)
+}
+
+func (mc *memoryCache) Inspect(ctx context.Context, key string) (time.Duration, int64, error) {
+    mc.mu.Lock()
+    defer mc.mu.Unlock()
+
+    now := time.Now()
+    entry, ok := mc.entries[key]
+    if !ok || entry.expired(now) {
+        return 0, 0, ErrCacheMiss
+    }
+    ttl := time.Duration(-1)
+    if !entry.expiresAt.IsZero() {
+        ttl = entry.expiresAt.Sub(now)
+    }
+    return ttl, int64(len(entry.value)), nil
+}
+
+// emptyFeedMarkerPrefix tags feed cache entries that hold an empty result, so
+// the whole negative cache can be dropped when new public content appears.
+const emptyFeedMarkerPrefix = "emptyfeed:"
+
+// invalidateEmptyFeeds schedules a sweep of every cached empty feed page. Call
+// it when a public post is created; a user's own follow changes are covered by
+// the per-user invalidation endpoint. It returns at once: the sweep scans the
+// keyspace, so it runs on runEmptyFeedSweeps, and posts arriving while one
+// runs share a single follow-up sweep.
+func (fs *FeedService) invalidateEmptyFeeds() {
+    select {
+    case fs.emptyFeedSweeps <- struct{}{}:
+    default:
+    }
+}
+
+// runEmptyFeedSweeps performs the sweeps invalidateEmptyFeeds asks for.
+func (fs *FeedService) runEmptyFeedSweeps(ctx context.Context) {
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-fs.emptyFeedSweeps:
+        }
+        sweepCtx, cancel := context.WithTimeout(context.Background(), fs.cacheInvalidateTimeout)
+        if err := fs.dropEmptyFeeds(sweepCtx); err != nil {
+            log.Printf("Empty feed sweep failed: %v", err)
+        }
+        cancel()
+    }
+}
+
+// dropEmptyFeeds deletes each empty feed page with its marker, scanBatchSize
+// markers per delete.
+func (fs *FeedService) dropEmptyFeeds(ctx context.Context) error {
+    markers, err := fs.cache.Keys(ctx, emptyFeedMarkerPrefix+"*")
+    if err != nil {
+        return err
+    }
+    for start := 0; start < len(markers); start += scanBatchSize {
+        end := start + scanBatchSize
+        if end > len(markers) {
+            end = len(markers)
+        }
+        keys := make([]string, 0, (end-start)*2)
+        for _, marker := range markers[start:end] {
+            keys = append(keys, marker, marker[len(emptyFeedMarkerPrefix):])
+        }
+        if _, err := fs.cache.Del(ctx, keys...); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+// invalidateUserFeeds drops every cached feed page of the given users before
+// returning. It is for the caller's own feed, which should reflect their
+// change on the very next read.
+func (fs *FeedService) invalidateUserFeeds(ctx context.Context, userIDs ...string) {
+    for _, userID := range userIDs {
+        fs.cache.DeleteMatching(ctx, userFeedKeyPattern(userID))
+    }
+}
+
+// queueFeedInvalidations hands the given users' feed invalidations to
+// feedInvalidations and returns without waiting. Post fan-out uses it: each
+// user costs a keyspace scan, and a post can reach FRIEND_INLINE_MAX
+// recipients. Their cached pages survive for up to
+// CACHE_INVALIDATE_COALESCE_WINDOW plus the queue behind
+// CACHE_INVALIDATE_CONCURRENCY.
+func (fs *FeedService) queueFeedInvalidations(userIDs ...string) {
+    for _, userID := range userIDs {
+        fs.feedInvalidations.invalidate(userID)
+    }
+}
+
+// trendingKeyPattern matches every cached trending result, but not the stale
+// copies kept as a fallback for failed aggregations.
+const trendingKeyPattern = "trending:*"
+
+// InvalidateTrending drops all cached trending results, e.g. after a burst of
+// engagement the cached ordering no longer reflects.
+func (fs *FeedService) InvalidateTrending(c *gin.Context) {
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    deleted, err := fs.cache.DeleteMatching(ctx, trendingKeyPattern)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to invalidate trending cache")
+        return
+    }
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":      true,
+        "message":      "Trending cache invalidated",
+        "keys_deleted": deleted,
+    })
+}
+
+func refreshMarkerKey(userID string) string {
+    return fmt.Sprintf("feed_refresh:%s", userID)
+}
+
+// refreshDebounced reports whether the user's feed was force-rebuilt within
+// the debounce window.
+func (fs *FeedService) refreshDebounced(ctx context.Context, userID string) bool {
+    if fs.refreshDebounce <= 0 {
+        return false
+    }
+    _, err := fs.cache.Get(ctx, refreshMarkerKey(userID))
+    return err == nil
+}
+
+// markRefreshed records a forced rebuild; the marker expires with the window.
+func (fs *FeedService) markRefreshed(ctx context.Context, userID string) {
+    if fs.refreshDebounce <= 0 {
+        return
+    }
+    now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+    fs.cache.Set(ctx, refreshMarkerKey(userID), []byte(now), fs.refreshDebounce)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+func TestDropEmptyFeeds(t *testing.T) {
+    fs := newTestService(t, nil)
+    ctx := context.Background()
+    fs.cache.Set(ctx, "feed:a:page:1", []byte("empty"), time.Minute)
+    fs.cache.Set(ctx, emptyFeedMarkerPrefix+"feed:a:page:1", []byte("1"), time.Minute)
+    fs.cache.Set(ctx, "feed:b:page:1", []byte("posts"), time.Minute)
+
+    if err := fs.dropEmptyFeeds(ctx); err != nil {
+        t.Fatal(err)
+    }
+    for _, key := range []string{"feed:a:page:1", emptyFeedMarkerPrefix + "feed:a:page:1"} {
+        if _, err := fs.cache.Get(ctx, key); err != ErrCacheMiss {
+            t.Errorf("%s survived the sweep", key)
+        }
+    }
+    if _, err := fs.cache.Get(ctx, "feed:b:page:1"); err != nil {
+        t.Error("sweep dropped a populated page")
+    }
+}
+
+func TestInvalidateEmptyFeedsCoalesces(t *testing.T) {
+    fs := newTestService(t, nil)
+    for i := 0; i < 3; i++ {
+        fs.invalidateEmptyFeeds()
+    }
+    if len(fs.emptyFeedSweeps) != 1 {
+        t.Fatalf("%d sweeps queued, want 1", len(fs.emptyFeedSweeps))
+    }
+}
+
+func TestTrendingInvalidationRequiresInternalToken(t *testing.T) {
+    fs := newTestService(t, nil)
+    fs.internalToken = "internal-secret"
+    pass := func(c *gin.Context) { c.Next() }
+    router := gin.New()
+    registerRoutes(router.Group("/api/v1"), fs.routes(),
+        routeMiddleware{Admin: pass, Internal: InternalAuth(fs.internalToken), RateLimit: pass})
+
+    ctx := context.Background()
+    tests := []struct {
+        name         string
+        target       string
+        token        string
+        wantStatus   int
+        wantTrending bool // whether the cached trending result survives
+    }{
+        {"trending route without token", "/api/v1/cache/trending", "", http.StatusForbidden, true},
+        {"trending route wrong token", "/api/v1/cache/trending", "nope", http.StatusForbidden, true},
+        {"trending route with token", "/api/v1/cache/trending", "internal-secret", http.StatusOK, false},
+        {"user cache", "/api/v1/cache/u1", "", http.StatusOK, true},
+        {"user cache trending without token", "/api/v1/cache/u1?trending=true", "", http.StatusForbidden, true},
+        {"user cache trending with token", "/api/v1/cache/u1?trending=true", "internal-secret", http.StatusOK, false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            fs.cache.Set(ctx, "trending:24h:page:1", []byte("posts"), time.Minute)
+            req := httptest.NewRequest(http.MethodDelete, tt.target, nil)
+            if tt.token != "" {
+                req.Header.Set("X-Internal-Token", tt.token)
+            }
+            rec := httptest.NewRecorder()
+            router.ServeHTTP(rec, req)
+
+            if rec.Code != tt.wantStatus {
+                t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+            }
+            _, err := fs.cache.Get(ctx, "trending:24h:page:1")
+            if survived := err == nil; survived != tt.wantTrending {
+                t.Fatalf("trending result survived = %v, want %v", survived, tt.wantTrending)
+            }
+        })
+    }
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+)
+
+// now is the clock for every time-windowed query (trending windows,
+// exploration recency, promotion expiry). With USE_SERVER_TIME=true it tracks
+// the MongoDB server's clock so all replicas agree on window boundaries even
+// when their host clocks drift; otherwise it is the local clock.
+func (fs *FeedService) now() time.Time {
+    return time.Now().Add(time.Duration(fs.clockOffset.Load()))
+}
+
+// syncServerClock measures the offset between the Mongo server clock and the
+// local one, attributing half the round trip to each direction.
+func (fs *FeedService) syncServerClock(ctx context.Context) error {
+    var reply struct {
+        LocalTime time.Time `bson:"localTime"`
+    }
+
+    sent := time.Now()
+    err := fs.mongo.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&reply)
+    if err != nil {
+        return err
+    }
+    received := time.Now()
+
+    midpoint := sent.Add(received.Sub(sent) / 2)
+    fs.clockOffset.Store(int64(reply.LocalTime.Sub(midpoint)))
+    return nil
+}
+
+// runServerClockSync refreshes the offset every SERVER_TIME_REFRESH.
+func (fs *FeedService) runServerClockSync(ctx context.Context) {
+    if !fs.useServerTime {
+        return
+    }
+
+    ticker := time.NewTicker(getEnvDuration("SERVER_TIME_REFRESH", time.Minute))
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+            if err := fs.syncServerClock(ctx); err != nil {
+                log.Printf("Server clock sync failed, keeping previous offset: %v", err)
+            }
+        }
+    }
+}
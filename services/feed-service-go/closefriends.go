@@ -0,0 +1,104 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxCloseFriends = 500
+
+// closeFriendsList is one document per author in the close_friends collection.
+type closeFriendsList struct {
+    Author    primitive.ObjectID   `bson:"_id"`
+    Members   []primitive.ObjectID `bson:"members"`
+    UpdatedAt time.Time            `bson:"updatedAt"`
+}
+
+type CloseFriendsRequest struct {
+    MemberIDs []string `json:"memberIds"`
+}
+
+// fetchCloseFriendOf returns the authors whose close-friends list contains viewerID.
+func (fs *FeedService) fetchCloseFriendOf(ctx context.Context, viewerID primitive.ObjectID) ([]primitive.ObjectID, error) {
+    collection := fs.mongo.Database("crown-social").Collection("close_friends")
+
+    opts := options.Find().SetProjection(bson.M{"_id": 1})
+    cursor, err := collection.Find(ctx, bson.M{"members": viewerID}, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var lists []closeFriendsList
+    if err := cursor.All(ctx, &lists); err != nil {
+        return nil, err
+    }
+
+    authors := make([]primitive.ObjectID, 0, len(lists))
+    for _, list := range lists {
+        authors = append(authors, list.Author)
+    }
+    return authors, nil
+}
+
+// UpdateCloseFriends replaces the caller's close-friends list. Feeds of both
+// removed and added members are invalidated since their visible set changed.
+func (fs *FeedService) UpdateCloseFriends(c *gin.Context) {
+    authorID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+
+    var req CloseFriendsRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    if len(req.MemberIDs) > maxCloseFriends {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("At most %d close friends allowed", maxCloseFriends))
+        return
+    }
+
+    members := make([]primitive.ObjectID, 0, len(req.MemberIDs))
+    for _, id := range req.MemberIDs {
+        memberID, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Invalid member id: %s", id))
+            return
+        }
+        members = append(members, memberID)
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    collection := fs.mongo.Database("crown-social").Collection("close_friends")
+
+    var previous closeFriendsList
+    collection.FindOne(ctx, bson.M{"_id": authorID}).Decode(&previous)
+
+    _, err = collection.UpdateOne(ctx,
+        bson.M{"_id": authorID},
+        bson.M{"$set": bson.M{"members": members, "updatedAt": time.Now()}},
+        options.Update().SetUpsert(true),
+    )
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update close friends")
+        return
+    }
+
+    for _, memberID := range append(previous.Members, members...) {
+        fs.cache.DeleteMatching(ctx, userFeedKeyPattern(memberID.Hex()))
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":      true,
+        "closeFriends": len(members),
+    })
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+    "unicode/utf8"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxCommentLength = 1000
+
+// Comment mirrors the main app's Comment model. Depth is 0 for top-level
+// comments and parent depth + 1 for replies.
+type Comment struct {
+    ID           primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+    Post         primitive.ObjectID  `bson:"post" json:"post"`
+    Author       primitive.ObjectID  `bson:"author" json:"author"`
+    Content      string              `bson:"content" json:"content"`
+    ParentID     *primitive.ObjectID `bson:"parentComment" json:"parentId,omitempty"`
+    Depth        int                 `bson:"depth" json:"depth"`
+    RepliesCount int                 `bson:"repliesCount" json:"repliesCount"`
+    LikesCount   int                 `bson:"likesCount" json:"likesCount"`
+    IsActive     bool                `bson:"isActive" json:"isActive"`
+    CreatedAt    time.Time           `bson:"createdAt" json:"createdAt"`
+    UpdatedAt    time.Time           `bson:"updatedAt" json:"updatedAt"`
+}
+
+type CreateCommentRequest struct {
+    Content  string `json:"content"`
+    ParentID string `json:"parentId,omitempty"`
+}
+
+func (fs *FeedService) commentsCollection() *mongo.Collection {
+    return fs.mongo.Database("crown-social").Collection("comments")
+}
+
+// loadVisiblePost fetches an active post and checks the viewer may see it,
+// writing the error response itself when not.
+func (fs *FeedService) loadVisiblePost(c *gin.Context, postID primitive.ObjectID) (Post, bool) {
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    collection := fs.mongo.Database("crown-social").Collection("posts")
+
+    var post Post
+    err := collection.FindOne(ctx, bson.M{"_id": postID, "isActive": true}).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+        return post, false
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch post")
+        return post, false
+    }
+
+    viewer, _ := primitive.ObjectIDFromHex(requestUserID(c))
+    rel, err := fs.resolveRelationship(ctx, viewer)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch post")
+        return post, false
+    }
+    if !canView(post, rel) {
+        // Hidden posts are indistinguishable from missing ones
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+        return post, false
+    }
+    return post, true
+}
+
+// GetComments returns the thread summary (top-level comments, newest first,
+// each with its reply count) or, with ?parentId=, one comment's replies in
+// conversation order.
+func (fs *FeedService) GetComments(c *gin.Context) {
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+    if _, ok := fs.loadVisiblePost(c, postID); !ok {
+        return
+    }
+
+    page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+    limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+    if page < 1 {
+        page = 1
+    }
+    if limit < 1 || limit > 100 {
+        limit = 20
+    }
+
+    filter := bson.M{"post": postID, "isActive": true, "parentComment": nil}
+    sort := bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+    if parent := c.Query("parentId"); parent != "" {
+        parentID, err := primitive.ObjectIDFromHex(parent)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid parent id")
+            return
+        }
+        filter["parentComment"] = parentID
+        sort = bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}
+    }
+
+    opts := options.Find().
+        SetSort(sort).
+        SetSkip(int64((page - 1) * limit)).
+        SetLimit(int64(limit + 1))
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    cursor, err := fs.commentsCollection().Find(ctx, filter, opts)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch comments")
+        return
+    }
+    defer cursor.Close(ctx)
+
+    comments := []Comment{}
+    if err := cursor.All(ctx, &comments); err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch comments")
+        return
+    }
+    // The extra comment only tells whether another page exists
+    hasMore := len(comments) > limit
+    if hasMore {
+        comments = comments[:limit]
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":  true,
+        "comments": comments,
+        "pagination": gin.H{
+            "page":    page,
+            "limit":   limit,
+            "hasMore": hasMore,
+        },
+    })
+}
+
+// CreateComment adds a comment or, with parentId, a reply. Replies bump both
+// the post's comment count and the parent's reply count, and nesting is
+// bounded by COMMENT_MAX_DEPTH.
+func (fs *FeedService) CreateComment(c *gin.Context) {
+    authorID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    var req CreateCommentRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    if length := utf8.RuneCountInString(req.Content); length == 0 || length > maxCommentLength {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Comment must be 1-%d characters", maxCommentLength))
+        return
+    }
+
+    post, ok := fs.loadVisiblePost(c, postID)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    now := fs.now()
+    comment := Comment{
+        ID:        primitive.NewObjectID(),
+        Post:      postID,
+        Author:    authorID,
+        Content:   req.Content,
+        IsActive:  true,
+        CreatedAt: now,
+        UpdatedAt: now,
+    }
+
+    var parent Comment
+    if req.ParentID != "" {
+        parentID, err := primitive.ObjectIDFromHex(req.ParentID)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid parent id")
+            return
+        }
+        err = fs.commentsCollection().FindOne(ctx, bson.M{"_id": parentID, "post": postID, "isActive": true}).Decode(&parent)
+        if err == mongo.ErrNoDocuments {
+            respondError(c, http.StatusNotFound, ErrCodeNotFound, "Parent comment not found")
+            return
+        }
+        if err != nil {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to create comment")
+            return
+        }
+        if parent.Depth+1 > fs.commentMaxDepth {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Replies may nest at most %d levels", fs.commentMaxDepth))
+            return
+        }
+        comment.ParentID = &parentID
+        comment.Depth = parent.Depth + 1
+    }
+
+    if _, err := fs.commentsCollection().InsertOne(ctx, comment); err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to create comment")
+        return
+    }
+
+    fs.postsWriteCollection().UpdateOne(ctx, bson.M{"_id": postID}, bson.M{"$inc": bson.M{"commentsCount": 1}})
+    fs.invalidatePost(ctx, postID)
+    if comment.ParentID != nil {
+        fs.commentsCollection().UpdateOne(ctx, bson.M{"_id": *comment.ParentID}, bson.M{"$inc": bson.M{"repliesCount": 1}})
+    }
+
+    // Notify the post author and, for replies, the parent comment's author
+    eventType := "comment_created"
+    recipients := map[primitive.ObjectID]bool{post.Author: true}
+    if comment.ParentID != nil {
+        eventType = "comment_reply"
+        recipients[parent.Author] = true
+    }
+    delete(recipients, authorID)
+
+    var userIDs []string
+    for recipient := range recipients {
+        userIDs = append(userIDs, recipient.Hex())
+        fs.incrementUnread(ctx, recipient.Hex())
+    }
+    if len(userIDs) > 0 {
+        if err := fs.publishEvent(ctx, userIDs, eventType, gin.H{"postId": postID.Hex(), "comment": comment}); err != nil {
+            log.Printf("Failed to publish %s event: %v", eventType, err)
+        }
+    }
+
+    respondJSON(c, http.StatusCreated, gin.H{
+        "success": true,
+        "comment": comment,
+    })
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestGetCommentsHasMore(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    for _, tc := range []struct {
+        name    string
+        rows    int
+        hasMore bool
+    }{
+        {"exactly a page", 2, false},
+        {"one past a page", 3, true},
+    } {
+        mt.Run(tc.name, func(mt *mtest.T) {
+            fs := newTestService(t, mt.Client)
+            viewer := primitive.NewObjectID()
+            post := editablePost(viewer, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+            var rows []bson.D
+            for i := 0; i < tc.rows; i++ {
+                rows = append(rows, mockDoc(t, Comment{ID: primitive.NewObjectID(), Post: post.ID, Author: viewer, Content: "hi", IsActive: true}))
+            }
+
+            mt.AddMockResponses(
+                mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, post)),
+                mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch),
+                mtest.CreateCursorResponse(0, "crown-social.close_friends", mtest.FirstBatch),
+                mtest.CreateCursorResponse(0, "crown-social.comments", mtest.FirstBatch, rows...),
+            )
+            target := fmt.Sprintf("/posts/%s/comments?limit=2", post.ID.Hex())
+            rec := serve(fs.GetComments, http.MethodGet, "/posts/:id/comments", target, viewer.Hex(), nil)
+            if rec.Code != http.StatusOK {
+                mt.Fatalf("status = %d: %s", rec.Code, rec.Body)
+            }
+            var body struct {
+                Comments   []Comment `json:"comments"`
+                Pagination struct {
+                    HasMore bool `json:"hasMore"`
+                } `json:"pagination"`
+            }
+            decodeBody(t, rec, &body)
+            if len(body.Comments) != 2 || body.Pagination.HasMore != tc.hasMore {
+                mt.Fatalf("got %d comments, hasMore %v; want 2, %v", len(body.Comments), body.Pagination.HasMore, tc.hasMore)
+            }
+        })
+    }
+}
+
+func TestCreateCommentUsesServerClock(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("skewed local clock", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        fs.clockOffset.Store(int64(time.Hour))
+        author := primitive.NewObjectID()
+        post := editablePost(author, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, post)),
+            mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.close_friends", mtest.FirstBatch),
+            mtest.CreateSuccessResponse(),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+        )
+        rec := serve(fs.CreateComment, http.MethodPost, "/posts/:id/comments", "/posts/"+post.ID.Hex()+"/comments", author.Hex(), CreateCommentRequest{Content: "hi"})
+        if rec.Code != http.StatusCreated {
+            mt.Fatalf("status = %d: %s", rec.Code, rec.Body)
+        }
+        var body struct {
+            Comment Comment `json:"comment"`
+        }
+        decodeBody(t, rec, &body)
+        if skew := time.Until(body.Comment.CreatedAt); skew < 30*time.Minute {
+            mt.Fatalf("createdAt is %s ahead of the local clock, want the server offset", skew)
+        }
+    })
+}
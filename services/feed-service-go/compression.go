@@ -0,0 +1,129 @@
+package main
+
+import (
+    "bytes"
+    "compress/gzip"
+    "strings"
+    "sync"
+
+    "github.com/gin-gonic/gin"
+)
+
+var gzipWriters = sync.Pool{
+    New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// Gzip compresses responses of at least minBytes for clients that accept
+// gzip. Smaller bodies are not worth the CPU and header bytes, so the body is
+// held back until it either reaches minBytes or the handler finishes. Bodies
+// that already carry a Content-Encoding (e.g. /metrics, which gzips itself)
+// or are not text-like pass through untouched, as do WebSocket upgrades.
+func Gzip(minBytes int) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !strings.Contains(c.GetHeader("Accept-Encoding"), "gzip") || c.GetHeader("Upgrade") != "" {
+            c.Next()
+            return
+        }
+
+        w := &gzipResponseWriter{ResponseWriter: c.Writer, minBytes: minBytes}
+        c.Writer = w
+        c.Writer.Header().Add("Vary", "Accept-Encoding")
+        defer w.finish()
+        c.Next()
+    }
+}
+
+// gzipResponseWriter buffers the start of a body to decide whether to
+// compress it; once decided, writes go straight to gz or the client.
+type gzipResponseWriter struct {
+    gin.ResponseWriter
+    minBytes int
+    buf      bytes.Buffer
+    gz       *gzip.Writer
+    decided  bool
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+    if !w.decided {
+        w.buf.Write(data)
+        if w.buf.Len() < w.minBytes {
+            return len(data), nil
+        }
+        if err := w.decide(true); err != nil {
+            return 0, err
+        }
+        return len(data), nil
+    }
+    if w.gz != nil {
+        return w.gz.Write(data)
+    }
+    return w.ResponseWriter.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+    return w.Write([]byte(s))
+}
+
+// decide starts compressing if the body is large enough and compressible,
+// then sends whatever has been buffered.
+func (w *gzipResponseWriter) decide(large bool) error {
+    w.decided = true
+    header := w.Header()
+    if large && header.Get("Content-Encoding") == "" && compressibleType(header.Get("Content-Type")) {
+        header.Set("Content-Encoding", "gzip")
+        header.Del("Content-Length")
+        w.gz = gzipWriters.Get().(*gzip.Writer)
+        w.gz.Reset(w.ResponseWriter)
+        _, err := w.gz.Write(w.buf.Bytes())
+        w.buf.Reset()
+        return err
+    }
+    if w.buf.Len() == 0 {
+        return nil
+    }
+    _, err := w.ResponseWriter.Write(w.buf.Bytes())
+    w.buf.Reset()
+    return err
+}
+
+// Flush sends a still-undecided body as is, since a streaming handler wants
+// it delivered now rather than held for minBytes.
+func (w *gzipResponseWriter) Flush() {
+    if !w.decided {
+        w.decide(false)
+    }
+    if w.gz != nil {
+        w.gz.Flush()
+    }
+    w.ResponseWriter.Flush()
+}
+
+func (w *gzipResponseWriter) finish() {
+    if !w.decided {
+        w.decide(false)
+        return
+    }
+    if w.gz != nil {
+        w.gz.Close()
+        w.gz.Reset(nil)
+        gzipWriters.Put(w.gz)
+        w.gz = nil
+    }
+}
+
+// compressibleType reports whether a Content-Type is text-like. Images,
+// video and archives are already compressed and only grow under gzip.
+func compressibleType(contentType string) bool {
+    mediaType, _, _ := strings.Cut(contentType, ";")
+    mediaType = strings.TrimSpace(mediaType)
+    switch {
+    case strings.HasPrefix(mediaType, "text/"),
+        mediaType == "application/json",
+        mediaType == "application/javascript",
+        mediaType == "application/xml",
+        strings.HasSuffix(mediaType, "+json"),
+        strings.HasSuffix(mediaType, "+xml"):
+        return true
+    }
+    return false
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "fmt"
+    "html"
+    "net/http"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/microcosm-cc/bluemonday"
+    "github.com/yuin/goldmark"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+    ContentFormatPlain    = "plain"
+    ContentFormatMarkdown = "markdown"
+)
+
+// renderedHTMLTTL is how long rendered HTML is cached. Keys include the
+// post's updatedAt, so an edit renders afresh rather than waiting this out.
+const renderedHTMLTTL = 24 * time.Hour
+
+var (
+    markdown = goldmark.New()
+    // Raw HTML is escaped by goldmark already; the sanitizer is the second
+    // line of defence for links (javascript: URLs) and anything it lets through
+    htmlPolicy = bluemonday.UGCPolicy()
+)
+
+// normalizeContentFormat validates a client-supplied format for post
+// creation. Empty means plain text.
+func normalizeContentFormat(format string) (string, bool) {
+    switch strings.ToLower(strings.TrimSpace(format)) {
+    case "", ContentFormatPlain:
+        return ContentFormatPlain, true
+    case ContentFormatMarkdown:
+        return ContentFormatMarkdown, true
+    }
+    return "", false
+}
+
+// renderContentHTML turns stored content into sanitized HTML. Posts written
+// before formats existed, or with an unknown format, render as plain text.
+func renderContentHTML(post Post) (string, error) {
+    if post.ContentFormat != ContentFormatMarkdown {
+        escaped := html.EscapeString(post.Content)
+        return "<p>" + strings.ReplaceAll(escaped, "\n", "<br>") + "</p>", nil
+    }
+
+    var buf bytes.Buffer
+    if err := markdown.Convert([]byte(post.Content), &buf); err != nil {
+        return "", err
+    }
+    return htmlPolicy.Sanitize(buf.String()), nil
+}
+
+func renderedHTMLKey(post Post) string {
+    return fmt.Sprintf("render:%s:%d", post.ID.Hex(), post.UpdatedAt.UnixNano())
+}
+
+// renderedHTML returns the post's sanitized HTML, rendering at most once per
+// post version.
+func (fs *FeedService) renderedHTML(ctx context.Context, post Post) (string, error) {
+    key := renderedHTMLKey(post)
+    if cached, err := fs.cache.Get(ctx, key); err == nil {
+        return string(cached), nil
+    }
+
+    rendered, err := renderContentHTML(post)
+    if err != nil {
+        return "", err
+    }
+    fs.cache.Set(ctx, key, []byte(rendered), renderedHTMLTTL)
+    return rendered, nil
+}
+
+// attachRenderedHTML fills ContentHTML for each post. A post that fails to
+// render is left without HTML rather than failing the whole page.
+func (fs *FeedService) attachRenderedHTML(ctx context.Context, posts []Post) {
+    for i := range posts {
+        if rendered, err := fs.renderedHTML(ctx, posts[i]); err == nil {
+            posts[i].ContentHTML = rendered
+        }
+    }
+}
+
+// RenderedPost is the response of GET /posts/:id/html.
+type RenderedPost struct {
+    Success bool   `json:"success"`
+    ID      string `json:"id"`
+    Format  string `json:"format"`
+    HTML    string `json:"html"`
+}
+
+// GetPostHTML returns one post's content as sanitized HTML.
+func (fs *FeedService) GetPostHTML(c *gin.Context) {
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    post, ok := fs.loadVisiblePost(c, postID)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    rendered, err := fs.renderedHTML(ctx, post)
+    if err != nil {
+        respondError(c, http.StatusInternalServerError, ErrCodeFetchFailed, "Failed to render post")
+        return
+    }
+
+    format, _ := normalizeContentFormat(post.ContentFormat)
+    respondJSON(c, http.StatusOK, RenderedPost{
+        Success: true,
+        ID:      post.ID.Hex(),
+        Format:  format,
+        HTML:    rendered,
+    })
+}
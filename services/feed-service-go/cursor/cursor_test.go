@@ -0,0 +1,111 @@
+package cursor
+
+import (
+    "encoding/base64"
+    "encoding/json"
+    "strings"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// signed builds a token for an arbitrary payload, as Encode would.
+func signed(c *Codec, p interface{}) string {
+    body, _ := json.Marshal(p)
+    return signedRaw(c, body)
+}
+
+func signedRaw(c *Codec, body []byte) string {
+    enc := base64.RawURLEncoding
+    return enc.EncodeToString(body) + "." + enc.EncodeToString(c.sign(body))
+}
+
+func TestRoundTrip(t *testing.T) {
+    codec := New([]byte("secret"), time.Hour)
+    score := 12.5
+    cases := []Fields{
+        {CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), ID: primitive.NewObjectID()},
+        {CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC), ID: primitive.NewObjectID(), Score: &score},
+    }
+    for _, want := range cases {
+        token := codec.Encode(want)
+        if strings.ContainsAny(token, "+/=") {
+            t.Errorf("token %q is not URL-safe", token)
+        }
+        got, err := codec.Decode(token)
+        if err != nil {
+            t.Fatalf("Decode: %v", err)
+        }
+        if !got.CreatedAt.Equal(want.CreatedAt) || got.ID != want.ID {
+            t.Errorf("got %+v, want %+v", got, want)
+        }
+        if (got.Score == nil) != (want.Score == nil) || (got.Score != nil && *got.Score != *want.Score) {
+            t.Errorf("score %v, want %v", got.Score, want.Score)
+        }
+    }
+}
+
+func TestZeroTTLNeverExpires(t *testing.T) {
+    codec := New([]byte("secret"), 0)
+    token := signed(codec, payload{Fields: Fields{ID: primitive.NewObjectID()}})
+    if _, err := codec.Decode(token); err != nil {
+        t.Fatalf("Decode: %v", err)
+    }
+}
+
+func TestDecodeRejectsTampering(t *testing.T) {
+    codec := New([]byte("secret"), time.Hour)
+    token := codec.Encode(Fields{CreatedAt: time.Now(), ID: primitive.NewObjectID()})
+    body, sig, _ := strings.Cut(token, ".")
+
+    forgedBody := base64.RawURLEncoding.EncodeToString([]byte(`{"t":"2020-01-01T00:00:00Z","id":"` + primitive.NewObjectID().Hex() + `"}`))
+    otherKey := New([]byte("other"), time.Hour).Encode(Fields{CreatedAt: time.Now(), ID: primitive.NewObjectID()})
+    flipped := []byte(sig)
+    if flipped[0] == 'A' {
+        flipped[0] = 'B'
+    } else {
+        flipped[0] = 'A'
+    }
+
+    cases := map[string]string{
+        "body swapped under the signature": forgedBody + "." + sig,
+        "signature altered":                body + "." + string(flipped),
+        "signed with another secret":       otherKey,
+        "signature missing":                body + ".",
+    }
+    for name, tampered := range cases {
+        if _, err := codec.Decode(tampered); err != ErrInvalid {
+            t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+        }
+    }
+}
+
+func TestDecodeRejectsMalformed(t *testing.T) {
+    codec := New([]byte("secret"), time.Hour)
+    cases := map[string]string{
+        "empty":                "",
+        "no separator":         "abc",
+        "too many parts":       "a.b.c",
+        "body not base64":      "!!!." + base64.RawURLEncoding.EncodeToString(codec.sign([]byte("!!!"))),
+        "signature not base64": "e30.!!!",
+        "body not JSON":        signedRaw(codec, []byte("not json")),
+        "missing id":           signed(codec, map[string]string{"t": "2026-03-01T12:00:00Z"}),
+    }
+    for name, token := range cases {
+        if _, err := codec.Decode(token); err != ErrInvalid {
+            t.Errorf("%s: err = %v, want ErrInvalid", name, err)
+        }
+    }
+}
+
+func TestDecodeRejectsExpired(t *testing.T) {
+    codec := New([]byte("secret"), time.Hour)
+    expired := signed(codec, payload{
+        Fields:    Fields{CreatedAt: time.Now(), ID: primitive.NewObjectID()},
+        ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+    })
+    if _, err := codec.Decode(expired); err != ErrExpired {
+        t.Fatalf("err = %v, want ErrExpired", err)
+    }
+}
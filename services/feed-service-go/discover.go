@@ -0,0 +1,150 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "hash/fnv"
+    "net/http"
+    "sort"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    discoverWindow     = 7 * 24 * time.Hour
+    discoverCandidates = 500
+    discoverSessionTTL = 15 * time.Minute
+)
+
+// discoverRank orders a post within a session. Hashing instead of shuffling
+// keeps the order stable for the session and unrelated across sessions.
+func discoverRank(postID primitive.ObjectID, session string) uint64 {
+    h := fnv.New64a()
+    h.Write(postID[:])
+    h.Write([]byte(session))
+    return h.Sum64()
+}
+
+// discoverOrder returns the session's ordered candidate IDs, computing and
+// caching them on first use so every page of a session slices the same list.
+func (fs *FeedService) discoverOrder(ctx context.Context, session string) ([]primitive.ObjectID, error) {
+    key := "discover:" + session
+    if cached, err := fs.cache.Get(ctx, key); err == nil {
+        var ids []primitive.ObjectID
+        if json.Unmarshal(cached, &ids) == nil {
+            return ids, nil
+        }
+    }
+
+    filter := visibilityFilter(viewerRelationship{})
+    filter["isActive"] = true
+    filter["createdAt"] = bson.M{"$gte": fs.now().Add(-discoverWindow)}
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}}).
+        SetLimit(discoverCandidates).
+        SetProjection(bson.M{"_id": 1})
+
+    cursor, err := fs.mongo.Database("crown-social").Collection("posts").Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    var docs []struct {
+        ID primitive.ObjectID `bson:"_id"`
+    }
+    if err := cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+
+    ids := make([]primitive.ObjectID, len(docs))
+    for i, doc := range docs {
+        ids[i] = doc.ID
+    }
+    sort.Slice(ids, func(i, j int) bool {
+        return discoverRank(ids[i], session) < discoverRank(ids[j], session)
+    })
+
+    if data, err := json.Marshal(ids); err == nil {
+        fs.cache.Set(ctx, key, data, discoverSessionTTL)
+    }
+    return ids, nil
+}
+
+// GetDiscover pages through recent public posts in an order that is shuffled
+// per session but stable within it.
+func (fs *FeedService) GetDiscover(c *gin.Context) {
+    session := c.Query("session")
+    if session == "" {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "session is required")
+        return
+    }
+    page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+    limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+    if page < 1 {
+        page = 1
+    }
+    if limit < 1 || limit > 50 {
+        limit = 20
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    ids, err := fs.discoverOrder(ctx, session)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch posts")
+        return
+    }
+
+    start := (page - 1) * limit
+    if start > len(ids) {
+        start = len(ids)
+    }
+    end := start + limit
+    if end > len(ids) {
+        end = len(ids)
+    }
+    pageIDs := ids[start:end]
+
+    posts := []Post{}
+    if len(pageIDs) > 0 {
+        // Refetch with the visibility filter: a post may have been hidden or
+        // removed since the session's order was cached
+        filter := visibilityFilter(viewerRelationship{})
+        filter["isActive"] = true
+        filter["_id"] = bson.M{"$in": pageIDs}
+        cursor, err := fs.mongo.Database("crown-social").Collection("posts").Find(ctx, filter)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch posts")
+            return
+        }
+        var found []Post
+        if err := cursor.All(ctx, &found); err != nil {
+            respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch posts")
+            return
+        }
+
+        byID := make(map[primitive.ObjectID]Post, len(found))
+        for _, post := range found {
+            byID[post.ID] = post
+        }
+        for _, id := range pageIDs {
+            if post, ok := byID[id]; ok {
+                posts = append(posts, post)
+            }
+        }
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "posts":   posts,
+        "pagination": gin.H{
+            "page":    page,
+            "limit":   limit,
+            "hasMore": end < len(ids),
+        },
+    })
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/hex"
+    "strings"
+    "time"
+)
+
+// DuplicateConfig controls rejection of repeated identical posts by one author.
+type DuplicateConfig struct {
+    Enabled     bool
+    Window      time.Duration
+    ExemptTypes []string // post types that may legitimately repeat (e.g. poll)
+}
+
+// normalizeForDuplicate lowercases content and collapses whitespace, so
+// trivially varied copies hash the same.
+func normalizeForDuplicate(content string) string {
+    return strings.Join(strings.Fields(strings.ToLower(content)), " ")
+}
+
+func duplicateContentKey(authorID, content string) string {
+    sum := sha256.Sum256([]byte(normalizeForDuplicate(content)))
+    return "recentcontent:" + authorID + ":" + hex.EncodeToString(sum[:16])
+}
+
+// isDuplicateContent records the content for the author and reports whether
+// the same normalized content was already posted within the window. The
+// check and the record are one SETNX, so concurrent duplicates cannot both
+// pass. Redis errors fail open: a spam check must not block posting.
+func (fs *FeedService) isDuplicateContent(ctx context.Context, authorID, postType, content string) bool {
+    cfg := fs.duplicates
+    if !cfg.Enabled || containsString(cfg.ExemptTypes, postType) || normalizeForDuplicate(content) == "" {
+        return false
+    }
+    fresh, err := fs.redis.SetNX(ctx, duplicateContentKey(authorID, content), 1, cfg.Window).Result()
+    return err == nil && !fresh
+}
+
+// forgetContent clears the record left by isDuplicateContent when the write
+// it guarded failed.
+func (fs *FeedService) forgetContent(ctx context.Context, authorID, content string) {
+    if fs.duplicates.Enabled {
+        fs.redis.Del(ctx, duplicateContentKey(authorID, content))
+    }
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+func TestIsDuplicateContent(t *testing.T) {
+    ctx := context.Background()
+    fs := newTestService(t, nil)
+    server := useMiniredis(t, fs)
+    fs.duplicates = DuplicateConfig{Enabled: true, Window: time.Minute, ExemptTypes: []string{"poll"}}
+
+    if fs.isDuplicateContent(ctx, "u1", "text", "Hello  World") {
+        t.Fatal("first post reported as duplicate")
+    }
+
+    tests := []struct {
+        name     string
+        author   string
+        postType string
+        content  string
+        want     bool
+    }{
+        {"exact repeat", "u1", "text", "Hello  World", true},
+        {"case and whitespace", "u1", "text", "\thello\nworld ", true},
+        {"different words", "u1", "text", "Hello there world", false},
+        {"other author", "u2", "text", "Hello World", false},
+        {"exempt type", "u1", "poll", "Hello World", false},
+        {"empty content", "u1", "text", "", false},
+        {"whitespace only", "u1", "text", " \n\t ", false},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            if got := fs.isDuplicateContent(ctx, tt.author, tt.postType, tt.content); got != tt.want {
+                t.Fatalf("isDuplicateContent(%q, %q, %q) = %v, want %v", tt.author, tt.postType, tt.content, got, tt.want)
+            }
+        })
+    }
+
+    // Exempt and empty posts must not record anything that a later post
+    // could collide with.
+    if fs.isDuplicateContent(ctx, "u3", "poll", "Vote") || fs.isDuplicateContent(ctx, "u3", "text", "Vote") {
+        t.Fatal("exempt poll recorded its content")
+    }
+
+    t.Run("window expiry", func(t *testing.T) {
+        server.FastForward(time.Minute - time.Second)
+        if !fs.isDuplicateContent(ctx, "u1", "text", "hello world") {
+            t.Fatal("repeat inside the window accepted")
+        }
+        server.FastForward(2 * time.Second)
+        if fs.isDuplicateContent(ctx, "u1", "text", "hello world") {
+            t.Fatal("repeat after the window rejected")
+        }
+        if !fs.isDuplicateContent(ctx, "u1", "text", "hello world") {
+            t.Fatal("window did not restart after expiry")
+        }
+    })
+}
+
+func TestIsDuplicateContentDisabledAndFailOpen(t *testing.T) {
+    ctx := context.Background()
+    fs := newTestService(t, nil)
+    useMiniredis(t, fs)
+    for i := 0; i < 2; i++ {
+        if fs.isDuplicateContent(ctx, "u1", "text", "same") {
+            t.Fatal("disabled check rejected a post")
+        }
+    }
+
+    // newTestService's own Redis refuses connections.
+    down := newTestService(t, nil)
+    down.duplicates = DuplicateConfig{Enabled: true, Window: time.Minute}
+    for i := 0; i < 2; i++ {
+        if down.isDuplicateContent(ctx, "u1", "text", "same") {
+            t.Fatal("Redis error did not fail open")
+        }
+    }
+}
+
+func TestForgetContent(t *testing.T) {
+    ctx := context.Background()
+    fs := newTestService(t, nil)
+    useMiniredis(t, fs)
+    fs.duplicates = DuplicateConfig{Enabled: true, Window: time.Minute}
+
+    fs.isDuplicateContent(ctx, "u1", "text", "Retry me")
+    fs.forgetContent(ctx, "u1", "RETRY  me")
+    if fs.isDuplicateContent(ctx, "u1", "text", "Retry me") {
+        t.Fatal("content still recorded after forgetContent")
+    }
+}
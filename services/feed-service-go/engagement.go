@@ -0,0 +1,120 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/go-redis/redis/v8"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+    engagementStream       = "engagement"
+    engagementStreamMaxLen = 100000
+)
+
+type DwellRequest struct {
+    Ms int64 `json:"ms"`
+}
+
+// affinityKey holds a viewer's accumulated dwell milliseconds per author,
+// which rankByFeatures reads through authorAffinity.
+func affinityKey(viewerID string) string {
+    return fmt.Sprintf("affinity:%s", viewerID)
+}
+
+// RecordDwell stores how long a post stayed on screen. The duration is
+// appended to the engagement stream and folded into the viewer's affinity for
+// the post's author. Durations outside [DWELL_MIN_MS, DWELL_MAX_MS] are
+// rejected so a client cannot inflate affinity with absurd values, and so is
+// dwell on a post the viewer cannot see.
+func (fs *FeedService) RecordDwell(c *gin.Context) {
+    viewerID := requestUserID(c)
+    if viewerID == "" {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    var req DwellRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    if req.Ms < fs.dwellMinMs || req.Ms > fs.dwellMaxMs {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest,
+            fmt.Sprintf("Dwell time must be between %d and %d ms", fs.dwellMinMs, fs.dwellMaxMs))
+        return
+    }
+
+    post, ok := fs.loadVisiblePost(c, postID)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    pipe := fs.redis.Pipeline()
+    pipe.XAdd(ctx, &redis.XAddArgs{
+        Stream: engagementStream,
+        MaxLen: engagementStreamMaxLen,
+        Approx: true,
+        Values: map[string]interface{}{
+            "type":     "dwell",
+            "postId":   postID.Hex(),
+            "authorId": post.Author.Hex(),
+            "userId":   viewerID,
+            "ms":       strconv.FormatInt(req.Ms, 10),
+            "ts":       strconv.FormatInt(time.Now().UnixMilli(), 10),
+        },
+    })
+    pipe.HIncrBy(ctx, affinityKey(viewerID), post.Author.Hex(), req.Ms)
+    if _, err := pipe.Exec(ctx); err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to record dwell time")
+        return
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{"success": true})
+}
+
+// authorAffinity returns the viewer's accumulated dwell milliseconds for each
+// author on the page that has any. It is best effort: with the cache degraded
+// or Redis failing, ranking simply goes without it.
+func (fs *FeedService) authorAffinity(ctx context.Context, viewerID string, posts []Post) map[primitive.ObjectID]float64 {
+    if fs.dwellAffinityWeight <= 0 || viewerID == "" || fs.cacheDegraded() {
+        return nil
+    }
+    var authors []primitive.ObjectID
+    var fields []string
+    for _, post := range posts {
+        if !containsID(authors, post.Author) {
+            authors = append(authors, post.Author)
+            fields = append(fields, post.Author.Hex())
+        }
+    }
+    if len(fields) == 0 {
+        return nil
+    }
+    values, err := fs.redis.HMGet(ctx, affinityKey(viewerID), fields...).Result()
+    if err != nil {
+        return nil
+    }
+
+    affinity := make(map[primitive.ObjectID]float64)
+    for i, value := range values {
+        raw, _ := value.(string)
+        if ms, err := strconv.ParseFloat(raw, 64); err == nil && ms > 0 {
+            affinity[authors[i]] = ms
+        }
+    }
+    return affinity
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "strconv"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestRankByFeaturesBoostsDwelledAuthors(t *testing.T) {
+    fs := newTestService(t, nil)
+    server := useMiniredis(t, fs)
+    fs.features = noopFeatureProvider{}
+    fs.dwellAffinityWeight = 0.5
+    viewer := primitive.NewObjectID().Hex()
+    quick, lingered, unseen := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+    server.HSet(affinityKey(viewer), quick.Hex(), "2000", lingered.Hex(), strconv.Itoa(5*60*1000))
+
+    posts := []Post{
+        {ID: primitive.NewObjectID(), Author: unseen},
+        {ID: primitive.NewObjectID(), Author: quick},
+        {ID: primitive.NewObjectID(), Author: unseen},
+        {ID: primitive.NewObjectID(), Author: lingered},
+    }
+    want := []primitive.ObjectID{posts[3].ID, posts[1].ID, posts[0].ID, posts[2].ID}
+    ranked := fs.rankByFeatures(context.Background(), viewer, posts)
+    for i, post := range ranked {
+        if post.ID != want[i] {
+            t.Fatalf("position %d: got author %s, want dwell order then chronological", i, post.Author.Hex())
+        }
+    }
+
+    fs.dwellAffinityWeight = 0
+    posts = []Post{{ID: want[2], Author: unseen}, {ID: want[0], Author: lingered}}
+    if ranked := fs.rankByFeatures(context.Background(), viewer, posts); ranked[0].ID != want[2] {
+        t.Fatal("a zero DWELL_AFFINITY_WEIGHT still reordered the page")
+    }
+}
+
+func TestRecordDwellRejectsHiddenPost(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("private post of another author", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        server := useMiniredis(t, fs)
+        fs.dwellMinMs, fs.dwellMaxMs = 1, 60000
+        viewer := primitive.NewObjectID()
+        post := editablePost(primitive.NewObjectID(), time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, post)),
+            mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.close_friends", mtest.FirstBatch),
+        )
+        rec := serve(fs.RecordDwell, http.MethodPost, "/posts/:id/dwell", "/posts/"+post.ID.Hex()+"/dwell", viewer.Hex(), DwellRequest{Ms: 5000})
+        if rec.Code != http.StatusNotFound {
+            mt.Fatalf("status = %d, want 404: %s", rec.Code, rec.Body)
+        }
+        if server.Exists(affinityKey(viewer.Hex())) {
+            mt.Error("dwell on a hidden post recorded affinity")
+        }
+    })
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+// Error codes. Clients branch on these; the message is for people and may
+// change wording at any time.
+const (
+    ErrCodeInvalidRequest  = "INVALID_REQUEST"
+    ErrCodeUnauthorized    = "UNAUTHORIZED"
+    ErrCodeForbidden       = "FORBIDDEN"
+    ErrCodeNotFound        = "NOT_FOUND"
+    ErrCodeConflict        = "CONFLICT"
+    ErrCodeBodyTooLarge    = "BODY_TOO_LARGE"
+    ErrCodeRateLimited     = "RATE_LIMITED"
+    ErrCodeTimeout         = "TIMEOUT"
+    ErrCodeInternal        = "INTERNAL_ERROR"
+    ErrCodeFeedFetchFailed = "FEED_FETCH_FAILED" // feed, trending, tag, search and saved lists
+    ErrCodeFetchFailed     = "FETCH_FAILED"      // other reads
+    ErrCodeWriteFailed     = "WRITE_FAILED"
+)
+
+// ErrorResponse is the body of every error response. Error repeats Message
+// under the key clients read before codes existed.
+type ErrorResponse struct {
+    Code      string      `json:"code"`
+    Message   string      `json:"message"`
+    Error     string      `json:"error"`
+    Details   interface{} `json:"details,omitempty"`
+    RequestID string      `json:"requestId,omitempty"`
+}
+
+func newErrorResponse(c *gin.Context, code, message string, details interface{}) ErrorResponse {
+    return ErrorResponse{
+        Code:      code,
+        Message:   message,
+        Error:     message,
+        Details:   details,
+        RequestID: c.GetString("requestId"),
+    }
+}
+
+// respondError writes an error response. The status is chosen by the caller
+// as before; the code adds what the status alone cannot tell apart.
+func respondError(c *gin.Context, status int, code, message string) {
+    respondJSON(c, status, newErrorResponse(c, code, message, nil))
+}
+
+// respondErrorDetails is respondError with structured context, e.g. which
+// inputs were rejected.
+func respondErrorDetails(c *gin.Context, status int, code, message string, details interface{}) {
+    respondJSON(c, status, newErrorResponse(c, code, message, details))
+}
+
+// abortWithError is respondError for middleware, which must also stop the chain.
+func abortWithError(c *gin.Context, status int, code, message string) {
+    c.AbortWithStatusJSON(status, newErrorResponse(c, code, message, nil))
+}
+
+// respondFetchError answers 504 for deadline errors and 500 with code
+// otherwise.
+func respondFetchError(c *gin.Context, err error, code, message string) {
+    if isTimeout(err) {
+        respondError(c, http.StatusGatewayTimeout, ErrCodeTimeout, "Request timed out")
+        return
+    }
+    respondError(c, http.StatusInternalServerError, code, message)
+}
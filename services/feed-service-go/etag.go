@@ -0,0 +1,58 @@
+package main
+
+import (
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+)
+
+// pageETag is a weak validator for a page of posts. It hashes the posts as
+// served, counts included, so a like or edit changes it even where updatedAt
+// does not move. It is weak because per-request decoration (cacheHit, media
+// URL rewriting) may differ between responses that share it.
+func pageETag(posts []Post, hasMore bool) string {
+    data, _ := json.Marshal(posts)
+    sum := sha256.New()
+    sum.Write(data)
+    if hasMore {
+        sum.Write([]byte{1})
+    }
+    return `W/"` + hex.EncodeToString(sum.Sum(nil))[:32] + `"`
+}
+
+// notModified sets etag on a GET response and, when the request's
+// If-None-Match already holds it, answers 304 and returns true. POST feed
+// requests are not cacheable and get neither. The response is per viewer, so
+// shared caches must not store it.
+func notModified(c *gin.Context, etag string) bool {
+    if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+        return false
+    }
+    c.Header("ETag", etag)
+    c.Header("Cache-Control", "private, no-cache")
+    if !etagMatches(c.GetHeader("If-None-Match"), etag) {
+        return false
+    }
+    c.Status(http.StatusNotModified)
+    return true
+}
+
+// etagMatches applies If-None-Match's weak comparison: the W/ prefix is
+// ignored on both sides.
+func etagMatches(header, etag string) bool {
+    if header == "" {
+        return false
+    }
+    want := strings.TrimPrefix(etag, "W/")
+    for _, candidate := range strings.Split(header, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FeedEvent is the envelope for everything published to user_feed channels.
+// ID is stable per event so connections can drop duplicates delivered via
+// more than one channel.
+type FeedEvent struct {
+    ID        string      `json:"id"`
+    Type      string      `json:"type"`
+    Data      interface{} `json:"data"`
+    Timestamp time.Time   `json:"timestamp"`
+}
+
+func userFeedChannel(userID string) string {
+    return fmt.Sprintf("user_feed:%s", userID)
+}
+
+// notificationsChannel carries a user's notification stream, which clients
+// may multiplex onto their feed WebSocket.
+func notificationsChannel(userID string) string {
+    return fmt.Sprintf("notifications:%s", userID)
+}
+
+// publishEvent sends one event, with a single ID, to each user's feed channel
+// and records it in their resume stream.
+func (fs *FeedService) publishEvent(ctx context.Context, userIDs []string, eventType string, data interface{}) error {
+    event := FeedEvent{
+        ID:        primitive.NewObjectID().Hex(),
+        Type:      eventType,
+        Data:      data,
+        Timestamp: time.Now(),
+    }
+    payload, err := json.Marshal(event)
+    if err != nil {
+        return err
+    }
+
+    pipe := fs.redis.Pipeline()
+    for _, userID := range userIDs {
+        fs.appendFeedStream(ctx, pipe, userID, event.ID, payload)
+        pipe.Publish(ctx, userFeedChannel(userID), payload)
+    }
+    _, err = pipe.Exec(ctx)
+    return err
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+    "context"
+    "log"
+    "math"
+    "math/rand"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    // ReasonExplore annotates posts injected for exploration
+    ReasonExplore = "explore"
+
+    exploreCandidatePool = 100
+    exploreWindow        = 7 * 24 * time.Hour
+)
+
+// injectExploration mixes a small fraction (FEED_EXPLORE_RATE) of recent
+// public posts the page would not otherwise contain, to counter filter
+// bubbles. With a request seed both the picked posts and their positions are
+// reproducible; without one they vary per build.
+func (fs *FeedService) injectExploration(ctx context.Context, posts []Post, userID string, limit int, seed *int64) []Post {
+    if fs.exploreRate <= 0 {
+        return posts
+    }
+    count := int(math.Round(fs.exploreRate * float64(limit)))
+    if count > fs.exploreMaxPerPage {
+        count = fs.exploreMaxPerPage
+    }
+    if count <= 0 {
+        return posts
+    }
+
+    candidates, err := fs.fetchExploreCandidates(ctx, userID)
+    if err != nil {
+        log.Printf("Feed exploration failed: %v", err)
+        return posts
+    }
+
+    seen := make(map[primitive.ObjectID]bool, len(posts))
+    for _, post := range posts {
+        seen[post.ID] = true
+    }
+    fresh := candidates[:0]
+    for _, candidate := range candidates {
+        if !seen[candidate.ID] {
+            fresh = append(fresh, candidate)
+        }
+    }
+
+    var rng *rand.Rand
+    if seed != nil {
+        rng = rand.New(rand.NewSource(*seed))
+    } else {
+        rng = rand.New(rand.NewSource(time.Now().UnixNano()))
+    }
+    rng.Shuffle(len(fresh), func(i, j int) { fresh[i], fresh[j] = fresh[j], fresh[i] })
+
+    for i := 0; i < count && i < len(fresh); i++ {
+        explore := fresh[i]
+        explore.Reason = ReasonExplore
+
+        pos := rng.Intn(len(posts) + 1)
+        posts = append(posts, Post{})
+        copy(posts[pos+1:], posts[pos:])
+        posts[pos] = explore
+    }
+    return posts
+}
+
+// fetchExploreCandidates returns recent public posts by other authors, newest
+// first, so a given seed picks from a stable pool.
+func (fs *FeedService) fetchExploreCandidates(ctx context.Context, userID string) ([]Post, error) {
+    collection := fs.mongo.Database("crown-social").Collection("posts")
+
+    // Exploration only draws on what any stranger could see
+    filter := visibilityFilter(viewerRelationship{})
+    filter["isActive"] = true
+    filter["createdAt"] = bson.M{"$gte": fs.now().Add(-exploreWindow)}
+    if userObjectID, err := primitive.ObjectIDFromHex(userID); err == nil {
+        filter["author"] = bson.M{"$ne": userObjectID}
+    }
+
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetLimit(exploreCandidatePool)
+
+    cursor, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var posts []Post
+    if err := cursor.All(ctx, &posts); err != nil {
+        return nil, err
+    }
+    return posts, nil
+}
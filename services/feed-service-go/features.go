@@ -0,0 +1,128 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "math"
+    "sort"
+    "strconv"
+
+    "github.com/go-redis/redis/v8"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Features are precomputed ranking inputs for one post as seen by one viewer
+// (author affinity, topic similarity, engagement rates, ...).
+type Features map[string]float64
+
+// rankScoreFeature is the combined relevance score the ranking pipeline
+// writes; rankByFeatures orders on it.
+const rankScoreFeature = "score"
+
+// FeatureProvider supplies ranking features in one batch per feed page so
+// ranking does not recompute them per request.
+type FeatureProvider interface {
+    GetFeatures(ctx context.Context, viewerID string, postIDs []string) (map[string]Features, error)
+}
+
+// newFeatureProvider builds the provider selected by FEATURE_PROVIDER (none|redis).
+func newFeatureProvider(kind string, redisClient *redis.Client) (FeatureProvider, error) {
+    switch kind {
+    case "", "none":
+        return noopFeatureProvider{}, nil
+    case "redis":
+        return &redisFeatureProvider{client: redisClient}, nil
+    default:
+        return nil, fmt.Errorf("unknown FEATURE_PROVIDER %q", kind)
+    }
+}
+
+// noopFeatureProvider returns no features, leaving feed order unchanged.
+type noopFeatureProvider struct{}
+
+func (noopFeatureProvider) GetFeatures(ctx context.Context, viewerID string, postIDs []string) (map[string]Features, error) {
+    return nil, nil
+}
+
+// redisFeatureProvider reads features from hashes populated by an offline
+// pipeline: features:post:<postId> holds viewer-independent values and
+// features:viewer:<viewerId>:<postId> overrides them per viewer.
+type redisFeatureProvider struct {
+    client *redis.Client
+}
+
+func (rp *redisFeatureProvider) GetFeatures(ctx context.Context, viewerID string, postIDs []string) (map[string]Features, error) {
+    pipe := rp.client.Pipeline()
+    postCmds := make([]*redis.StringStringMapCmd, len(postIDs))
+    viewerCmds := make([]*redis.StringStringMapCmd, len(postIDs))
+    for i, postID := range postIDs {
+        postCmds[i] = pipe.HGetAll(ctx, "features:post:"+postID)
+        viewerCmds[i] = pipe.HGetAll(ctx, fmt.Sprintf("features:viewer:%s:%s", viewerID, postID))
+    }
+    if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+        return nil, err
+    }
+
+    result := make(map[string]Features, len(postIDs))
+    for i, postID := range postIDs {
+        features := Features{}
+        for _, cmd := range []*redis.StringStringMapCmd{postCmds[i], viewerCmds[i]} {
+            for name, raw := range cmd.Val() {
+                if value, err := strconv.ParseFloat(raw, 64); err == nil {
+                    features[name] = value
+                }
+            }
+        }
+        if len(features) > 0 {
+            result[postID] = features
+        }
+    }
+    return result, nil
+}
+
+// rankByFeatures reorders a page by the provider's relevance score plus the
+// viewer's dwell affinity for each post's author, DWELL_AFFINITY_WEIGHT times
+// the log of the seconds they have spent on that author's posts. Posts with
+// neither keep their chronological position relative to each other, after
+// scored posts. Without features or dwell the page is returned as is.
+func (fs *FeedService) rankByFeatures(ctx context.Context, viewerID string, posts []Post) []Post {
+    if len(posts) < 2 {
+        return posts
+    }
+
+    postIDs := make([]string, len(posts))
+    for i, post := range posts {
+        postIDs[i] = post.ID.Hex()
+    }
+    features, err := fs.features.GetFeatures(ctx, viewerID, postIDs)
+    if err != nil {
+        log.Printf("Feature lookup failed, ranking without features: %v", err)
+        features = nil
+    }
+    affinity := fs.authorAffinity(ctx, viewerID, posts)
+    if len(features) == 0 && len(affinity) == 0 {
+        return posts
+    }
+
+    scores := make(map[primitive.ObjectID]float64, len(posts))
+    for _, post := range posts {
+        score, ok := features[post.ID.Hex()][rankScoreFeature]
+        if ms, dwelt := affinity[post.Author]; dwelt {
+            score += fs.dwellAffinityWeight * math.Log1p(ms/1000)
+            ok = true
+        }
+        if ok {
+            scores[post.ID] = score
+        }
+    }
+    sort.SliceStable(posts, func(i, j int) bool {
+        si, iok := scores[posts[i].ID]
+        sj, jok := scores[posts[j].ID]
+        if iok != jok {
+            return iok
+        }
+        return si > sj
+    })
+    return posts
+}
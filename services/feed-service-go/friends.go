@@ -0,0 +1,207 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Friendship statuses, matching the main app's Friend model.
+const (
+    FriendStatusAccepted = "accepted"
+    FriendStatusBlocked  = "blocked"
+)
+
+// friendship is a document in the friends collection. A pair may have one
+// document per direction.
+type friendship struct {
+    Requester primitive.ObjectID `bson:"requester"`
+    Recipient primitive.ObjectID `bson:"recipient"`
+    Status    string             `bson:"status"`
+}
+
+func friendsCacheKey(userID primitive.ObjectID) string {
+    return "friends:" + userID.Hex()
+}
+
+// friendIDs returns the user's accepted friends, cached for FRIENDS_CACHE_TTL
+// so a scrolling session does not re-read the graph for every page.
+func (fs *FeedService) friendIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+    key := friendsCacheKey(userID)
+    if cached, err := fs.cache.Get(ctx, key); err == nil {
+        var ids []primitive.ObjectID
+        if json.Unmarshal(cached, &ids) == nil {
+            return ids, nil
+        }
+    }
+
+    ids, err := fs.fetchFriendIDs(ctx, userID)
+    if err != nil {
+        return nil, err
+    }
+    if data, err := json.Marshal(ids); err == nil {
+        fs.cache.Set(ctx, key, data, fs.friendsCacheTTL)
+    }
+    return ids, nil
+}
+
+// fetchFriendIDs loads accepted friendships in either direction, excluding
+// anyone with a block between the two users in either direction. The list is
+// never cut short: visibility checks and post fan-out rely on it being the
+// whole graph.
+func (fs *FeedService) fetchFriendIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+    collection := fs.mongo.Database("crown-social").Collection("friends")
+
+    filter := bson.M{
+        "$or": []bson.M{{"requester": userID}, {"recipient": userID}},
+        "status": bson.M{"$in": []string{FriendStatusAccepted, FriendStatusBlocked}},
+    }
+    opts := options.Find().SetProjection(bson.M{"requester": 1, "recipient": 1, "status": 1})
+    cursor, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var docs []friendship
+    if err := cursor.All(ctx, &docs); err != nil {
+        return nil, err
+    }
+
+    blocked := make(map[primitive.ObjectID]bool)
+    for _, doc := range docs {
+        if doc.Status == FriendStatusBlocked {
+            blocked[doc.other(userID)] = true
+        }
+    }
+
+    seen := make(map[primitive.ObjectID]bool, len(docs))
+    friends := make([]primitive.ObjectID, 0, len(docs))
+    for _, doc := range docs {
+        other := doc.other(userID)
+        if doc.Status != FriendStatusAccepted || blocked[other] || seen[other] {
+            continue
+        }
+        seen[other] = true
+        friends = append(friends, other)
+    }
+    if fs.friendInlineMax > 0 && len(friends) > fs.friendInlineMax {
+        log.Printf("User %s has %d friends, above FRIEND_INLINE_MAX (%d); feed queries join the friends collection", userID.Hex(), len(friends), fs.friendInlineMax)
+    }
+    return friends, nil
+}
+
+func (f friendship) other(userID primitive.ObjectID) primitive.ObjectID {
+    if f.Requester == userID {
+        return f.Recipient
+    }
+    return f.Requester
+}
+
+// feedQuery is a feed's post filter. Up to FRIEND_INLINE_MAX friends the
+// friend list is inlined into Filter as an $in. Past it JoinFriendsOf names
+// the viewer: Filter then admits every friends-only candidate, or in
+// following mode every author, and a $lookup against the friends collection
+// narrows them to the viewer's friends, so the list never enters the query.
+type feedQuery struct {
+    Filter        bson.M
+    JoinFriendsOf primitive.ObjectID
+    FollowingOnly bool
+}
+
+// joinsFriends reports whether the query takes the $lookup path.
+func (q feedQuery) joinsFriends() bool {
+    return !q.JoinFriendsOf.IsZero()
+}
+
+// friendJoinStages looks up the friendship between each candidate's author
+// and the viewer, then keeps posts the join allows: an accepted friendship
+// with no block in either direction, the rule fetchFriendIDs applies.
+func (q feedQuery) friendJoinStages() []bson.M {
+    viewer := q.JoinFriendsOf
+    isFriend := bson.M{"$and": []bson.M{
+        {"_friendship.status": FriendStatusAccepted},
+        {"_friendship.status": bson.M{"$ne": FriendStatusBlocked}},
+    }}
+    keep := isFriend
+    if !q.FollowingOnly {
+        keep = bson.M{"$or": []bson.M{
+            {"visibility": bson.M{"$ne": VisibilityFriends}},
+            {"author": viewer},
+            isFriend,
+        }}
+    }
+    return []bson.M{
+        {"$lookup": bson.M{
+            "from": "friends",
+            "let":  bson.M{"author": "$author"},
+            "pipeline": []bson.M{
+                {"$match": bson.M{
+                    "$expr": bson.M{"$or": []bson.M{
+                        {"$and": []bson.M{{"$eq": []interface{}{"$requester", viewer}}, {"$eq": []interface{}{"$recipient", "$$author"}}}},
+                        {"$and": []bson.M{{"$eq": []interface{}{"$requester", "$$author"}}, {"$eq": []interface{}{"$recipient", viewer}}}},
+                    }},
+                    "status": bson.M{"$in": []string{FriendStatusAccepted, FriendStatusBlocked}},
+                }},
+                {"$project": bson.M{"status": 1}},
+            },
+            "as": "_friendship",
+        }},
+        {"$match": keep},
+    }
+}
+
+// find runs the query sorted by sort, skipping skip rows and returning at
+// most limit.
+func (q feedQuery) find(ctx context.Context, collection *mongo.Collection, sort bson.D, skip, limit int) ([]Post, error) {
+    if !q.joinsFriends() {
+        opts := options.Find().SetSort(sort).SetSkip(int64(skip)).SetLimit(int64(limit))
+        return findPosts(ctx, collection, q.Filter, opts)
+    }
+
+    pipeline := []bson.M{{"$match": q.Filter}, {"$sort": sort}}
+    pipeline = append(pipeline, q.friendJoinStages()...)
+    if skip > 0 {
+        pipeline = append(pipeline, bson.M{"$skip": skip})
+    }
+    pipeline = append(pipeline, bson.M{"$limit": limit}, bson.M{"$project": bson.M{"_friendship": 0}})
+    cursor, err := collection.Aggregate(ctx, pipeline)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var posts []Post
+    if err := cursor.All(ctx, &posts); err != nil {
+        return nil, err
+    }
+    return posts, nil
+}
+
+// count counts the posts the query matches.
+func (q feedQuery) count(ctx context.Context, collection *mongo.Collection) (int64, error) {
+    if !q.joinsFriends() {
+        return collection.CountDocuments(ctx, q.Filter)
+    }
+
+    pipeline := append([]bson.M{{"$match": q.Filter}}, q.friendJoinStages()...)
+    pipeline = append(pipeline, bson.M{"$count": "total"})
+    cursor, err := collection.Aggregate(ctx, pipeline)
+    if err != nil {
+        return 0, err
+    }
+    defer cursor.Close(ctx)
+
+    var result []struct {
+        Total int64 `bson:"total"`
+    }
+    if err := cursor.All(ctx, &result); err != nil || len(result) == 0 {
+        return 0, err
+    }
+    return result[0].Total, nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+    "context"
+    "os"
+    "sort"
+    "strings"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestFetchFriendIDsReturnsWholeGraph(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("above FRIEND_INLINE_MAX", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        fs.friendInlineMax = 2
+        user := primitive.NewObjectID()
+        blockedFriend := primitive.NewObjectID()
+
+        var want []primitive.ObjectID
+        var docs []friendship
+        for i := 0; i < 5; i++ {
+            other := primitive.NewObjectID()
+            want = append(want, other)
+            doc := friendship{Requester: user, Recipient: other, Status: FriendStatusAccepted}
+            if i%2 == 1 {
+                doc = friendship{Requester: other, Recipient: user, Status: FriendStatusAccepted}
+            }
+            docs = append(docs, doc)
+        }
+        docs = append(docs,
+            friendship{Requester: user, Recipient: blockedFriend, Status: FriendStatusAccepted},
+            friendship{Requester: blockedFriend, Recipient: user, Status: FriendStatusBlocked},
+            friendship{Requester: want[0], Recipient: user, Status: FriendStatusAccepted}, // the reverse document
+        )
+        batch := make([]bson.D, 0, len(docs))
+        for _, doc := range docs {
+            batch = append(batch, mockDoc(t, doc))
+        }
+        mt.AddMockResponses(mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch, batch...))
+
+        friends, err := fs.fetchFriendIDs(context.Background(), user)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(friends) != len(want) {
+            t.Fatalf("%d friends, want all %d", len(friends), len(want))
+        }
+        for _, id := range want {
+            if !containsID(friends, id) {
+                t.Errorf("friend %s missing", id.Hex())
+            }
+        }
+        if containsID(friends, blockedFriend) {
+            t.Error("blocked friend kept")
+        }
+    })
+}
+
+func TestFeedQueryStrategy(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    for _, tc := range []struct {
+        name        string
+        inlineMax   int
+        wantCommand string
+    }{
+        {"at the threshold inlines the list", 3, "find"},
+        {"above the threshold joins", 2, "aggregate"},
+    } {
+        mt.Run(tc.name, func(mt *mtest.T) {
+            fs := newTestService(t, mt.Client)
+            fs.friendInlineMax = tc.inlineMax
+            user := primitive.NewObjectID()
+            var friends []bson.D
+            var friendIDs []primitive.ObjectID
+            for i := 0; i < 3; i++ {
+                friendIDs = append(friendIDs, primitive.NewObjectID())
+                friends = append(friends, mockDoc(t, friendship{Requester: user, Recipient: friendIDs[i], Status: FriendStatusAccepted}))
+            }
+            mt.AddMockResponses(
+                mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch, friends...),
+                mtest.CreateCursorResponse(0, "crown-social.close_friends", mtest.FirstBatch),
+                mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch), // pinned post
+                mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch),
+            )
+
+            if _, err := fs.fetchFeedFromDB(context.Background(), FeedRequest{UserID: user.Hex(), Page: 1, Limit: 10}); err != nil {
+                t.Fatal(err)
+            }
+            events := mt.GetAllStartedEvents()
+            last := events[len(events)-1]
+            if last.CommandName != tc.wantCommand {
+                t.Fatalf("feed query sent %s, want %s", last.CommandName, tc.wantCommand)
+            }
+            if tc.wantCommand == "aggregate" {
+                stages, _ := last.Command.Lookup("pipeline").Array().Values()
+                var lookup bool
+                for _, stage := range stages {
+                    if _, err := stage.Document().LookupErr("$lookup"); err == nil {
+                        lookup = true
+                    }
+                }
+                if !lookup {
+                    t.Error("joined feed query has no $lookup")
+                }
+            }
+            inlined := strings.Contains(last.Command.String(), friendIDs[0].Hex())
+            if inlined != (tc.wantCommand == "find") {
+                t.Errorf("friend list inlined = %v", inlined)
+            }
+        })
+    }
+}
+
+// TestFriendJoinMatchesInlineFeed checks against a real server that the
+// joined feed query returns exactly what the inlined one does. Set
+// MONGO_TEST_URI to run it.
+func TestFriendJoinMatchesInlineFeed(t *testing.T) {
+    uri := os.Getenv("MONGO_TEST_URI")
+    if uri == "" {
+        t.Skip("MONGO_TEST_URI not set")
+    }
+    ctx := context.Background()
+    client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer client.Disconnect(ctx)
+    db := client.Database("crown-social")
+
+    viewer := primitive.NewObjectID()
+    friendA, friendB, blocked, stranger := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+    links := []interface{}{
+        friendship{Requester: viewer, Recipient: friendA, Status: FriendStatusAccepted},
+        friendship{Requester: friendB, Recipient: viewer, Status: FriendStatusAccepted},
+        friendship{Requester: viewer, Recipient: blocked, Status: FriendStatusAccepted},
+        friendship{Requester: blocked, Recipient: viewer, Status: FriendStatusBlocked},
+    }
+    if _, err := db.Collection("friends").InsertMany(ctx, links); err != nil {
+        t.Fatal(err)
+    }
+    authors := []primitive.ObjectID{viewer, friendA, friendB, blocked, stranger}
+    defer db.Collection("friends").DeleteMany(ctx, bson.M{"requester": bson.M{"$in": authors}})
+
+    var posts []interface{}
+    created := time.Now().UTC().Truncate(time.Millisecond)
+    for i, author := range authors {
+        for j, visibility := range []string{VisibilityPublic, VisibilityFriends, VisibilityPrivate} {
+            post := editablePost(author, created.Add(-time.Duration(i*3+j)*time.Minute))
+            post.Visibility = visibility
+            posts = append(posts, post)
+        }
+    }
+    if _, err := db.Collection("posts").InsertMany(ctx, posts); err != nil {
+        t.Fatal(err)
+    }
+    defer db.Collection("posts").DeleteMany(ctx, bson.M{"author": bson.M{"$in": authors}})
+
+    feedIDs := func(inlineMax int, mode string) []string {
+        fs := newTestService(t, client)
+        fs.friendInlineMax = inlineMax
+        req := FeedRequest{UserID: viewer.Hex(), Mode: mode, Page: 1, Limit: 50, Authors: authors}
+        query, err := fs.feedFilter(ctx, req)
+        if err != nil {
+            t.Fatal(err)
+        }
+        // Scope to this test's authors without narrowing following mode
+        query.Filter["$and"] = []bson.M{{"author": bson.M{"$in": authors}}}
+        found, err := query.find(ctx, db.Collection("posts"), bson.D{{Key: "_id", Value: 1}}, 0, 100)
+        if err != nil {
+            t.Fatal(err)
+        }
+        ids := make([]string, 0, len(found))
+        for _, post := range found {
+            ids = append(ids, post.ID.Hex())
+        }
+        sort.Strings(ids)
+        return ids
+    }
+    for _, mode := range []string{"", FeedModeFollowing} {
+        inline, joined := feedIDs(0, mode), feedIDs(1, mode)
+        if len(inline) == 0 || len(inline) != len(joined) {
+            t.Fatalf("mode %q: inline found %d posts, join %d", mode, len(inline), len(joined))
+        }
+        for i := range inline {
+            if inline[i] != joined[i] {
+                t.Fatalf("mode %q: inline and joined feeds differ", mode)
+            }
+        }
+    }
+}
@@ -10,6 +10,12 @@ require (
     github.com/joho/godotenv v1.4.0
     github.com/dgrijalva/jwt-go v3.2.0+incompatible
     github.com/gin-contrib/cors v1.4.0
+    github.com/prometheus/client_golang v1.16.0
+    github.com/microcosm-cc/bluemonday v1.0.26
+    github.com/yuin/goldmark v1.5.6
+    golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4
+    github.com/alicebob/miniredis/v2 v2.30.5
+    github.com/prometheus/client_model v0.3.0
 )
 
 require (
@@ -43,7 +49,6 @@ require (
     golang.org/x/arch v0.3.0 // indirect
     golang.org/x/crypto v0.9.0 // indirect
     golang.org/x/net v0.10.0 // indirect
-    golang.org/x/sync v0.0.0-20220722155255-886fb9371eb4 // indirect
     golang.org/x/sys v0.8.0 // indirect
     golang.org/x/text v0.9.0 // indirect
     gopkg.in/yaml.v3 v3.0.1 // indirect
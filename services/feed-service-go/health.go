@@ -0,0 +1,109 @@
+package main
+
+import (
+    "context"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// healthCheckTimeout bounds each dependency ping so a hung backend fails the
+// probe instead of stalling it.
+const healthCheckTimeout = 2 * time.Second
+
+// checkDependencies pings Mongo and Redis concurrently, returning each one's
+// status ("ok" or the error) and whether the service can serve: Mongo alone
+// decides that, since without Redis it runs uncached.
+func (fs *FeedService) checkDependencies(ctx context.Context) (map[string]string, bool) {
+    ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+    defer cancel()
+
+    mongoErr := make(chan error, 1)
+    go func() { mongoErr <- fs.mongo.Ping(ctx, nil) }()
+    redisErr := fs.redis.Ping(ctx).Err()
+
+    status := map[string]string{"mongo": "ok", "redis": "ok"}
+    healthy := true
+    if err := <-mongoErr; err != nil {
+        status["mongo"] = err.Error()
+        healthy = false
+    }
+    if redisErr != nil {
+        status["redis"] = redisErr.Error()
+    }
+    return status, healthy
+}
+
+// cacheDegraded reports whether the cache is currently bypassed.
+func (fs *FeedService) cacheDegraded() bool {
+    return fs.cacheState != nil && fs.cacheState.degraded.Load()
+}
+
+// runRedisCheck pings Redis every REDIS_CHECK_INTERVAL, switching the cache
+// into degraded mode while it is unreachable and back once it answers.
+func (fs *FeedService) runRedisCheck(ctx context.Context) {
+    if fs.cacheState == nil {
+        return
+    }
+    fs.metrics.cacheDegraded.Set(boolGauge(fs.cacheDegraded()))
+
+    ticker := time.NewTicker(fs.redisCheckInterval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+        pingCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+        err := fs.redis.Ping(pingCtx).Err()
+        cancel()
+
+        degraded := err != nil
+        if fs.cacheState.degraded.Swap(degraded) != degraded {
+            if degraded {
+                log.Printf("⚠️ Redis unreachable, bypassing cache: %v", err)
+            } else {
+                log.Printf("Redis reachable again, cache re-enabled")
+            }
+        }
+        fs.metrics.cacheDegraded.Set(boolGauge(degraded))
+    }
+}
+
+func boolGauge(b bool) float64 {
+    if b {
+        return 1
+    }
+    return 0
+}
+
+// Ready is the readiness probe: unready until Mongo has answered once since
+// startup, whenever it is down, and while shutting down. A Redis outage keeps
+// the node ready but is reported as degraded.
+func (fs *FeedService) Ready(c *gin.Context) {
+    select {
+    case <-fs.shuttingDown:
+        respondJSON(c, http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+        return
+    default:
+    }
+
+    dependencies, healthy := fs.checkDependencies(c.Request.Context())
+    if healthy {
+        fs.ready.Store(true)
+    }
+    if !healthy || !fs.ready.Load() {
+        respondJSON(c, http.StatusServiceUnavailable, gin.H{"status": "not_ready", "dependencies": dependencies})
+        return
+    }
+    respondJSON(c, http.StatusOK, gin.H{"status": "ready", "dependencies": dependencies, "degraded": fs.cacheDegraded()})
+}
+
+// Live is the liveness probe. It never touches a backend: an outage is for
+// readiness and degraded mode to handle, not a reason to restart.
+func (fs *FeedService) Live(c *gin.Context) {
+    respondJSON(c, http.StatusOK, gin.H{"status": "alive"})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+    "context"
+    "hash/fnv"
+    "strconv"
+    "time"
+
+    "github.com/go-redis/redis/v8"
+)
+
+const (
+    impressionStream       = "impressions"
+    impressionStreamMaxLen = 100000
+)
+
+// impressionSampled decides whether the (post, viewer) pair is emitted at the
+// given sample rate. The decision hashes the pair instead of rolling a die per
+// event, so a pair is either always or never sampled: repeated views of the
+// same post by the same user are not thinned independently and per-pair
+// frequencies stay intact. Aggregate counts must be scaled by 1/rate, which is
+// why each event carries the rate it was sampled at. At low rates small
+// populations (a niche post, a new user) may have no sampled pairs at all.
+func impressionSampled(postID, userID string, rate float64) bool {
+    if rate >= 1 {
+        return true
+    }
+    if rate <= 0 {
+        return false
+    }
+    h := fnv.New64a()
+    h.Write([]byte(postID))
+    h.Write([]byte{0})
+    h.Write([]byte(userID))
+    return float64(h.Sum64()%10000)/10000 < rate
+}
+
+// recordImpressions appends the sampled impressions for a served feed page to
+// the capped impression stream. It runs asynchronously and never fails the
+// request.
+func (fs *FeedService) recordImpressions(userID string, posts []Post) {
+    if fs.impressionSampleRate <= 0 || len(posts) == 0 {
+        return
+    }
+
+    go func() {
+        ctx := context.Background()
+        now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+        rate := strconv.FormatFloat(fs.impressionSampleRate, 'f', -1, 64)
+
+        pipe := fs.redis.Pipeline()
+        for _, post := range posts {
+            postID := post.ID.Hex()
+            if !impressionSampled(postID, userID, fs.impressionSampleRate) {
+                continue
+            }
+            pipe.XAdd(ctx, &redis.XAddArgs{
+                Stream: impressionStream,
+                MaxLen: impressionStreamMaxLen,
+                Approx: true,
+                Values: map[string]interface{}{
+                    "postId":     postID,
+                    "userId":     userID,
+                    "ts":         now,
+                    "sampleRate": rate,
+                },
+            })
+        }
+        pipe.Exec(ctx)
+    }()
+}
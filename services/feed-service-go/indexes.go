@@ -0,0 +1,65 @@
+package main
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// postIndexes back the feed query (visibility filter sorted by recency),
+// per-author lookups and tag pages.
+var postIndexes = []mongo.IndexModel{
+    {
+        Keys:    bson.D{{Key: "isActive", Value: 1}, {Key: "visibility", Value: 1}, {Key: "createdAt", Value: -1}},
+        Options: options.Index().SetName("feed_active_visibility_createdAt"),
+    },
+    {
+        Keys:    bson.D{{Key: "author", Value: 1}, {Key: "createdAt", Value: -1}},
+        Options: options.Index().SetName("feed_author_createdAt"),
+    },
+    {
+        Keys:    bson.D{{Key: "tags", Value: 1}, {Key: "createdAt", Value: -1}},
+        Options: options.Index().SetName("feed_tags_createdAt"),
+    },
+}
+
+// ensureIndexes creates the indexes the feed queries rely on. Creating an
+// existing index is a no-op, so this runs on every start. Failures, including
+// another replica creating the same index concurrently, are logged and never
+// stop the service: missing indexes make queries slower, not wrong.
+func (fs *FeedService) ensureIndexes(ctx context.Context) {
+    ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+    defer cancel()
+
+    posts := fs.mongo.Database("crown-social").Collection("posts")
+    names, err := posts.Indexes().CreateMany(ctx, postIndexes)
+    if err != nil {
+        log.Printf("⚠️ Failed to ensure posts indexes: %v", err)
+    } else {
+        log.Printf("Ensured posts indexes: %v", names)
+    }
+
+    // Without it two concurrent pins could leave an author with both pinned
+    if _, err := posts.Indexes().CreateOne(ctx, pinnedPostIndex); err != nil {
+        log.Printf("⚠️ Failed to ensure posts pinned index: %v", err)
+    }
+
+    if _, err := posts.Indexes().CreateOne(ctx, contentTextIndex); err != nil {
+        log.Printf("⚠️ Failed to ensure posts text index, search will use regex: %v", err)
+    }
+
+    // Without the unique index a double save is not rejected
+    bookmarks := fs.mongo.Database("crown-social").Collection("bookmarks")
+    if _, err := bookmarks.Indexes().CreateMany(ctx, []mongo.IndexModel{bookmarkIndex, bookmarkListIndex}); err != nil {
+        log.Printf("⚠️ Failed to ensure bookmarks indexes: %v", err)
+    }
+
+    blocks := fs.mongo.Database("crown-social").Collection("blocks")
+    if _, err := blocks.Indexes().CreateOne(ctx, blockIndex); err != nil {
+        log.Printf("⚠️ Failed to ensure blocks indexes: %v", err)
+    }
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// invalidationBatch is one pending feed invalidation of a user, shared by
+// every request that asked for it before it ran.
+type invalidationBatch struct {
+    done    chan struct{}
+    deleted int64
+    err     error
+}
+
+// invalidationCoalescer collapses repeated invalidations of the same user.
+// The first request for a user waits out the window before scanning, and
+// requests arriving meanwhile join it. A request arriving once the scan has
+// started gets a fresh batch, since the scan may already have passed keys
+// its change affects. A semaphore bounds scans across all requests.
+type invalidationCoalescer struct {
+    window  time.Duration
+    timeout time.Duration
+    scans   chan struct{}
+    run     func(ctx context.Context, userID string) (int64, error)
+
+    mu      sync.Mutex
+    pending map[string]*invalidationBatch
+}
+
+func newInvalidationCoalescer(window, timeout time.Duration, concurrency int, run func(context.Context, string) (int64, error)) *invalidationCoalescer {
+    if concurrency < 1 {
+        concurrency = 1
+    }
+    return &invalidationCoalescer{
+        window:  window,
+        timeout: timeout,
+        scans:   make(chan struct{}, concurrency),
+        run:     run,
+        pending: make(map[string]*invalidationBatch),
+    }
+}
+
+// invalidate schedules userID's invalidation, reporting whether it joined
+// one already pending.
+func (ic *invalidationCoalescer) invalidate(userID string) (*invalidationBatch, bool) {
+    ic.mu.Lock()
+    defer ic.mu.Unlock()
+    if batch, ok := ic.pending[userID]; ok {
+        return batch, true
+    }
+
+    batch := &invalidationBatch{done: make(chan struct{})}
+    ic.pending[userID] = batch
+    time.AfterFunc(ic.window, func() {
+        ic.mu.Lock()
+        delete(ic.pending, userID)
+        ic.mu.Unlock()
+
+        // Runs on its own deadline: the requests waiting on it may give up,
+        // but a joined one still needs the scan done
+        ic.scans <- struct{}{}
+        ctx, cancel := context.WithTimeout(context.Background(), ic.timeout)
+        batch.deleted, batch.err = ic.run(ctx, userID)
+        cancel()
+        <-ic.scans
+        close(batch.done)
+    })
+    return batch, false
+}
+
+// InvalidateUsersRequest lists the users POST /cache/invalidate-users drops
+// feed pages for.
+type InvalidateUsersRequest struct {
+    UserIDs []string `json:"userIds"`
+}
+
+type InvalidateUserResult struct {
+    UserID      string `json:"userId"`
+    KeysDeleted int64  `json:"keysDeleted"`
+    // Coalesced is set when another request's pending invalidation did the work
+    Coalesced bool   `json:"coalesced,omitempty"`
+    Error     string `json:"error,omitempty"`
+}
+
+type InvalidateUsersResponse struct {
+    Success     bool                   `json:"success"`
+    Invalidated int                    `json:"invalidated"`
+    Failed      int                    `json:"failed"`
+    KeysDeleted int64                  `json:"keysDeleted"`
+    Results     []InvalidateUserResult `json:"results"`
+}
+
+// InvalidateUsers drops the cached feed pages of many users at once, for
+// bulk edits that would otherwise call DELETE /cache/:userId in a loop.
+// Duplicate IDs are merged, and invalidations of a user within
+// CACHE_INVALIDATE_COALESCE_WINDOW share one scan; at most
+// CACHE_INVALIDATE_CONCURRENCY scans run at a time.
+func (fs *FeedService) InvalidateUsers(c *gin.Context) {
+    var body InvalidateUsersRequest
+    if !bindJSON(c, &body) {
+        return
+    }
+    if len(body.UserIDs) == 0 {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "userIds is required")
+        return
+    }
+    if len(body.UserIDs) > fs.cacheInvalidateMaxUsers {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("At most %d users per request", fs.cacheInvalidateMaxUsers))
+        return
+    }
+
+    results := make([]InvalidateUserResult, 0, len(body.UserIDs))
+    batches := make([]*invalidationBatch, 0, len(body.UserIDs))
+    seen := make(map[string]bool, len(body.UserIDs))
+    for _, userID := range body.UserIDs {
+        if seen[userID] {
+            continue
+        }
+        seen[userID] = true
+        result := InvalidateUserResult{UserID: userID}
+        var batch *invalidationBatch
+        if _, err := primitive.ObjectIDFromHex(userID); err != nil {
+            result.Error = "invalid user id"
+        } else {
+            batch, result.Coalesced = fs.feedInvalidations.invalidate(userID)
+        }
+        results = append(results, result)
+        batches = append(batches, batch)
+    }
+
+    response := InvalidateUsersResponse{Success: true}
+    for i, batch := range batches {
+        if batch != nil {
+            select {
+            case <-batch.done:
+                results[i].KeysDeleted = batch.deleted
+                if batch.err != nil {
+                    results[i].Error = batch.err.Error()
+                }
+            case <-c.Request.Context().Done():
+                // The scan still runs; only this response gives up on it
+                results[i].Error = "request cancelled"
+            }
+        }
+        if results[i].Error != "" {
+            response.Failed++
+        } else {
+            response.Invalidated++
+        }
+        response.KeysDeleted += results[i].KeysDeleted
+    }
+    response.Results = results
+    respondJSON(c, http.StatusOK, response)
+}
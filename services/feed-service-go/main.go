@@ -2,12 +2,20 @@ package main
 
 import (
     "context"
+    "crypto/rand"
+    "crypto/tls"
     "encoding/json"
     "fmt"
     "log"
     "net/http"
+    "net/url"
     "os"
+    "os/signal"
     "strconv"
+    "strings"
+    "sync"
+    "sync/atomic"
+    "syscall"
     "time"
 
     "github.com/gin-gonic/gin"
@@ -15,33 +23,243 @@ import (
     "github.com/go-redis/redis/v8"
     "github.com/gorilla/websocket"
     "github.com/joho/godotenv"
+    "github.com/prometheus/client_golang/prometheus/promhttp"
+    "golang.org/x/sync/singleflight"
     "go.mongodb.org/mongo-driver/mongo"
     "go.mongodb.org/mongo-driver/mongo/options"
+    "go.mongodb.org/mongo-driver/mongo/writeconcern"
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "crown-feed-service/cursor"
 )
 
 type FeedService struct {
     mongo     *mongo.Client
-    redis     *redis.Client
+    redis     *redis.Client // pub/sub for live updates
+    cache     Cache
+    // cacheState is the Redis-backed cache's degraded switch; nil for the
+    // memory backend, which never degrades
+    cacheState *degradableCache
+    features  FeatureProvider
     upgrader  websocket.Upgrader
+    wsOrigins originAllowlist
+
+    // Collapses concurrent builds of the same feed page
+    feedBuilds singleflight.Group
+
+    // Closed on shutdown so open WebSockets close cleanly; wsConns tracks
+    // them, since http.Server.Shutdown does not wait for hijacked connections
+    shuttingDown chan struct{}
+    wsConns      sync.WaitGroup
+
+    // Set once Mongo and Redis have both answered; see Ready
+    ready atomic.Bool
+
+    // Inbound WebSocket frame limits (per connection)
+    wsMessageRate  float64
+    wsMessageBurst int
+    wsDedupeWindow int
+
+    // Selective compression of forwarded frames (threshold 0 disables)
+    wsCompressThreshold int
+    wsCompressScheme    string
+
+    // gzip for HTTP responses of at least compressionMinBytes, and
+    // permessage-deflate for WebSocket clients that offer it
+    enableCompression   bool
+    compressionMinBytes int
+
+    // Per-connection send buffer and what to do when it fills up
+    wsSendBuffer     int
+    wsOverflowPolicy string // "drop-oldest" or "disconnect"
+
+    // Keepalive: ping every wsPingInterval, drop clients silent for wsPongTimeout
+    wsPingInterval time.Duration
+    wsPongTimeout  time.Duration
+
+    // Missed-event replay on reconnect: how many events a backlog may hold and
+    // how long an idle user's resume stream is kept
+    wsBacklogMax int
+    wsStreamTTL  time.Duration
+
+    // Per-connection buffer between Redis pub/sub and the send loop
+    wsPubSubBuffer     int
+    pubsubDropLoggedAt atomic.Int64
+
+    quality QualityConfig
+
+    duplicates DuplicateConfig
+
+    // Fraction of feed impressions written to the impression stream
+    impressionSampleRate float64
+
+    // Top up short first feed pages with trending posts
+    feedBackfill bool
+
+    // Empty feeds rarely change, so they are cached longer than populated ones;
+    // new public posts queue a sweep of them here
+    emptyFeedTTL    time.Duration
+    emptyFeedSweeps chan struct{}
+
+    // Feed pages beyond this are never cached (0 disables the cap)
+    maxCachedPages int
+
+    // Fraction of each page given to exploration posts, bounded per page
+    exploreRate       float64
+    exploreMaxPerPage int
+
+    // Window within which repeated force-refreshes reuse the last build
+    refreshDebounce time.Duration
+
+    // Promoted posts go after every Nth organic post (0 disables)
+    promotedEvery      int
+    promotedMaxPerPage int
+
+    // Accepted bounds for client-reported dwell times, and how strongly
+    // dwell on an author lifts their posts in ranking (0 disables)
+    dwellMinMs          int64
+    dwellMaxMs          int64
+    dwellAffinityWeight float64
+
+    // Maximum reply nesting below a top-level comment
+    commentMaxDepth int
+
+    maxFollowedTags int
+
+    postsWriteConcern *writeconcern.WriteConcern
+
+    // Deadline for the Mongo and Redis work behind one request or job
+    opTimeout time.Duration
+
+    // Lifetime of cached feed pages and trending results
+    feedCacheTTL     time.Duration
+    // Feed totals are recounted only once this expires or the feed changes
+    feedTotalTTL     time.Duration
+    // Bound on scanning and deleting one user's cached pages
+    cacheInvalidateTimeout time.Duration
+    searchCacheTTL   time.Duration
+    postCacheTTL     time.Duration
+    trendingCacheTTL time.Duration
+
+    trendingWeights TrendingWeights
+    // Off: trending is public posts only. On: friends-only posts are ranked
+    // too and shown only to those who may see them
+    trendingIncludeFriends bool
+
+    // Stale trending fallback
+    trendingStaleTTL   time.Duration
+    trendingRefreshing sync.Map
+
+    cursors *cursor.Codec
+
+    metrics *feedMetrics
+
+    // Hard cap on aggregation output regardless of the requested limit
+    maxAggregationResults int
+
+    // Offset from the local clock to Mongo's, when USE_SERVER_TIME is set
+    useServerTime bool
+    clockOffset   atomic.Int64
+
+    // Shared secret for /admin routes; empty disables them
+    adminToken string
+
+    // Requests per minute per caller on rate-limited routes (0 disables);
+    // internalToken exempts our own services
+    rateLimit     int
+    internalToken string
+
+    // Largest page size served by the feed and trending endpoints
+    maxFeedLimit int
+
+    // Largest author set accepted by POST /feed/authors
+    maxFeedAuthors int
+
+    // Largest seenIds list accepted on a feed request
+    maxSeenIDs int
+
+    // POST /cache/warm bounds
+    cacheWarmConcurrency int
+    cacheWarmMaxUsers    int
+
+    // POST /cache/invalidate-users: the request bound, and the coalescer that
+    // merges repeat invalidations of a user
+    cacheInvalidateMaxUsers int
+    feedInvalidations       *invalidationCoalescer
+
+    // Most media attachments accepted on one post
+    maxPostMedia int
+
+    // Views count once per user per window and reach Mongo in batches
+    viewDedupWindow   time.Duration
+    viewFlushInterval time.Duration
+
+    // Shares count once per user per post per window
+    shareDedupWindow time.Duration
+
+    // CDN origin media URLs are rewritten to on the way out; nil disables
+    cdnBase *url.URL
+
+    // Friend lists are cached briefly; feed queries for graphs above
+    // FRIEND_INLINE_MAX join the friends collection instead of inlining them
+    friendsCacheTTL time.Duration
+    friendInlineMax int
+
+    // Block lists are cached per user; editing one drops the cached copy
+    blocksCacheTTL time.Duration
+
+    // Profile stats per author, dropped on post create, delete and reactions
+    userStatsTTL time.Duration
+
+    // How often Redis is pinged to enter or leave degraded (no-cache) mode
+    redisCheckInterval time.Duration
 }
 
 type Post struct {
     ID           primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
     Author       primitive.ObjectID   `bson:"author" json:"author"`
     Content      string              `bson:"content" json:"content"`
+    // ContentFormat is "plain" or "markdown"; posts predating it read as plain
+    ContentFormat string             `bson:"contentFormat,omitempty" json:"contentFormat,omitempty"`
     Type         string              `bson:"type" json:"type"`
     Visibility   string              `bson:"visibility" json:"visibility"`
     Media        []MediaItem         `bson:"media" json:"media"`
     Tags         []string            `bson:"tags" json:"tags"`
+    // LikesCount is the total across all reaction types, kept for clients
+    // that predate reactions
     LikesCount   int                 `bson:"likesCount" json:"likesCount"`
+    Reactions    map[string]int      `bson:"reactions" json:"reactions,omitempty"`
     CommentsCount int                `bson:"commentsCount" json:"commentsCount"`
     SharesCount  int                 `bson:"sharesCount" json:"sharesCount"`
     ViewsCount   int                 `bson:"viewsCount" json:"viewsCount"`
+    // AuthorVerified is denormalized from users.verified at creation to avoid
+    // per-request user joins; older posts read as unverified until backfilled
+    AuthorVerified bool              `bson:"authorVerified" json:"authorVerified"`
     IsActive     bool                `bson:"isActive" json:"isActive"`
+    PromotedUntil *time.Time         `bson:"promotedUntil,omitempty" json:"promotedUntil,omitempty"`
     CreatedAt    time.Time           `bson:"createdAt" json:"createdAt"`
     UpdatedAt    time.Time           `bson:"updatedAt" json:"updatedAt"`
+    // Edited and EditedAt back the "(edited)" badge; creation leaves both empty
+    Edited       bool                `bson:"edited,omitempty" json:"edited,omitempty"`
+    EditedAt     *time.Time          `bson:"editedAt,omitempty" json:"editedAt,omitempty"`
+    // SharedPost is the original a share post reposts; Type is then "share"
+    SharedPost   *primitive.ObjectID `bson:"sharedPost,omitempty" json:"sharedPost,omitempty"`
+    // Pinned puts the post first in its author's own feed; one per author
+    Pinned       bool                `bson:"pinned,omitempty" json:"pinned,omitempty"`
+    // DeletedAt records when the author deleted the post; deleted posts also
+    // have isActive false
+    DeletedAt    *time.Time          `bson:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+    // TrendingScore is computed by the trending pipeline; stored posts never
+    // carry it, so it stays empty everywhere else
+    TrendingScore float64            `bson:"trendingScore,omitempty" json:"trendingScore,omitempty"`
+
+    // Backfilled marks trending posts used to top up a short feed page
+    Backfilled   bool                `bson:"-" json:"backfilled,omitempty"`
+    // Reason explains why a non-organic post was included (e.g. "explore")
+    Reason       string              `bson:"-" json:"reason,omitempty"`
+    // ContentHTML is sanitized HTML, only set when the caller asks for render=html
+    ContentHTML  string              `bson:"-" json:"contentHtml,omitempty"`
 }
 
 type MediaItem struct {
@@ -55,211 +273,1024 @@ type FeedRequest struct {
     UserID string `json:"userId"`
     Page   int    `json:"page"`
     Limit  int    `json:"limit"`
+    Seed   *int64 `json:"seed,omitempty"` // makes exploration reproducible
+
+    // Cursor is the previous response's nextCursor. When set it replaces the
+    // page offset (page still numbers the page); Page/Limit-only requests
+    // are deprecated and will be removed after the next release.
+    Cursor string         `json:"cursor,omitempty"`
+    After  *cursor.Fields `json:"-"`
+
+    // Restrict the feed to posts from verified/official accounts
+    AuthorVerified bool `json:"authorVerified,omitempty"`
+
+    // BypassCache forces a rebuild (pull-to-refresh), subject to debouncing
+    BypassCache bool `json:"bypassCache,omitempty"`
+
+    // Mode selects the candidate set: "personalized" (the default, also ""),
+    // "following" or "tags"
+    Mode string `json:"mode,omitempty"`
+
+    // FollowedTags is resolved server-side for tags mode
+    FollowedTags []string `json:"-"`
+
+    // Authors is the normalized author set for authors mode (POST /feed/authors)
+    Authors []primitive.ObjectID `json:"-"`
+
+    // SeenIDs are posts the client already rendered, excluded from the page
+    // so posts shifting across page boundaries are not shown twice
+    SeenIDs []string             `json:"seenIds,omitempty"`
+    Seen    []primitive.ObjectID `json:"-"`
+
+    // Blocked is the user's block list, resolved server-side by buildFeed
+    Blocked []primitive.ObjectID `json:"-"`
+
+    // IncludeTotal adds pagination.total; off by default since it costs a count
+    IncludeTotal bool `json:"includeTotal,omitempty"`
+}
+
+// FeedModePersonalized mixes public posts with the user's own and friends';
+// FeedModeFollowing keeps only posts by the user's friends.
+const (
+    FeedModePersonalized = "personalized"
+    FeedModeFollowing    = "following"
+)
+
+// TrendingQuery selects one trending result set.
+type TrendingQuery struct {
+    Timeframe    string
+    Limit        int
+    VerifiedOnly bool
+    // IncludeFriends ranks friends-only posts alongside public ones; the
+    // result must then be narrowed per viewer with visibleTo
+    IncludeFriends bool
+    // Page is 1-based; After, when set, seeks past a score position instead
+    Page  int
+    After *cursor.Fields
+}
+
+// cacheKey is per page, so scrolling reuses each page's aggregation rather
+// than caching one list long enough for every page.
+func (q TrendingQuery) cacheKey() string {
+    key := fmt.Sprintf("trending:%s:limit:%d:page:%d", q.Timeframe, q.Limit, q.Page)
+    if q.After != nil {
+        key += fmt.Sprintf(":after:%g:%s", *q.After.Score, q.After.ID.Hex())
+    }
+    if q.VerifiedOnly {
+        key += ":verified"
+    }
+    if q.IncludeFriends {
+        key += ":friends"
+    }
+    return key
 }
 
 type FeedResponse struct {
     Success    bool   `json:"success"`
     Posts      []Post `json:"posts"`
     Pagination struct {
-        Page    int  `json:"page"`
-        Limit   int  `json:"limit"`
-        HasMore bool `json:"hasMore"`
+        Page       int    `json:"page"`
+        Limit      int    `json:"limit"`
+        HasMore    bool   `json:"hasMore"`
+        NextCursor string `json:"nextCursor,omitempty"`
+        Total      *int64 `json:"total,omitempty"`
     } `json:"pagination"`
     CacheHit bool `json:"cacheHit"`
+    // Debounced is set when a forced refresh reused a just-built page
+    Debounced bool `json:"debounced,omitempty"`
+}
+
+// connectMongo connects and pings, retrying up to attempts times with the
+// wait doubling from interval (capped at 30s), so a database mid-restart delays
+// startup instead of crash-looping it. A malformed URI fails at once.
+func connectMongo(ctx context.Context, clientOpts *options.ClientOptions, attempts int, interval time.Duration) (*mongo.Client, error) {
+    if attempts < 1 {
+        attempts = 1
+    }
+    wait := interval
+    var lastErr error
+    for attempt := 1; attempt <= attempts; attempt++ {
+        client, err := mongo.Connect(ctx, clientOpts)
+        if err != nil {
+            return nil, fmt.Errorf("connect to MongoDB: %w", err)
+        }
+        pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+        err = client.Ping(pingCtx, nil)
+        cancel()
+        if err == nil {
+            return client, nil
+        }
+        client.Disconnect(context.Background())
+        lastErr = err
+
+        if attempt < attempts {
+            log.Printf("⚠️ MongoDB ping failed (attempt %d/%d), retrying in %s: %v", attempt, attempts, wait, err)
+            select {
+            case <-time.After(wait):
+            case <-ctx.Done():
+                return nil, ctx.Err()
+            }
+            if wait *= 2; wait > 30*time.Second {
+                wait = 30 * time.Second
+            }
+        }
+    }
+    return nil, fmt.Errorf("MongoDB ping failed after %d attempts: %w", attempts, lastErr)
 }
 
-func NewFeedService() *FeedService {
+// mongoClientOptions applies the pool and timeout settings on top of the URI,
+// overriding any the URI sets. Feed reads are short and many, so the pool keeps
+// warm connections ready for bursts, and server selection fails well inside
+// the HTTP timeout rather than after the driver's 30s default.
+func mongoClientOptions(uri string) *options.ClientOptions {
+    maxPool := getEnvInt("MONGO_MAX_POOL_SIZE", 100)
+    if maxPool < 1 {
+        log.Printf("⚠️ Invalid MONGO_MAX_POOL_SIZE %d, using 100", maxPool)
+        maxPool = 100
+    }
+    minPool := getEnvInt("MONGO_MIN_POOL_SIZE", 10)
+    if minPool < 0 {
+        log.Printf("⚠️ Invalid MONGO_MIN_POOL_SIZE %d, using 0", minPool)
+        minPool = 0
+    }
+    if minPool > maxPool {
+        log.Printf("⚠️ MONGO_MIN_POOL_SIZE (%d) exceeds MONGO_MAX_POOL_SIZE (%d), using %d", minPool, maxPool, maxPool)
+        minPool = maxPool
+    }
+    connectTimeout := getEnvDuration("MONGO_CONNECT_TIMEOUT", 10*time.Second)
+    selectionTimeout := getEnvDuration("MONGO_SERVER_SELECTION_TIMEOUT", 5*time.Second)
+
+    log.Printf("MongoDB pool: max %d, min %d; connect timeout %s, server selection timeout %s",
+        maxPool, minPool, connectTimeout, selectionTimeout)
+
+    return options.Client().ApplyURI(uri).
+        SetMaxPoolSize(uint64(maxPool)).
+        SetMinPoolSize(uint64(minPool)).
+        SetConnectTimeout(connectTimeout).
+        SetServerSelectionTimeout(selectionTimeout)
+}
+
+// redisClientOptions accepts REDIS_URL as a redis:// or rediss:// URL, or as a
+// bare host:port as before. REDIS_PASSWORD, REDIS_DB and REDIS_TLS override
+// what the URL says when set.
+func redisClientOptions(redisURL string) (*redis.Options, error) {
+    opts := &redis.Options{Addr: redisURL}
+    if strings.Contains(redisURL, "://") {
+        parsed, err := redis.ParseURL(redisURL)
+        if err != nil {
+            return nil, fmt.Errorf("parse REDIS_URL: %w", err)
+        }
+        opts = parsed
+    }
+
+    if password := getEnv("REDIS_PASSWORD", ""); password != "" {
+        opts.Password = password
+    }
+    if db := getEnv("REDIS_DB", ""); db != "" {
+        n, err := strconv.Atoi(db)
+        if err != nil || n < 0 {
+            return nil, fmt.Errorf("invalid REDIS_DB %q", db)
+        }
+        opts.DB = n
+    }
+    if getEnvBool("REDIS_TLS", false) && opts.TLSConfig == nil {
+        opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+    }
+    return opts, nil
+}
+
+// NewFeedService connects to the backends and loads configuration. Errors are
+// returned rather than fatal so main decides whether to exit.
+func NewFeedService() (*FeedService, error) {
     // Load environment variables
     godotenv.Load()
 
     // MongoDB connection
-    mongoClient, err := mongo.Connect(context.Background(), options.Client().ApplyURI(
-        getEnv("MONGODB_URI", "mongodb://localhost:27017/crown-social"),
-    ))
+    mongoClient, err := connectMongo(context.Background(),
+        mongoClientOptions(getEnv("MONGODB_URI", "mongodb://localhost:27017/crown-social")),
+        getEnvInt("MONGO_CONNECT_ATTEMPTS", 5),
+        getEnvDuration("MONGO_CONNECT_INTERVAL", time.Second),
+    )
     if err != nil {
-        log.Fatal("Failed to connect to MongoDB:", err)
+        return nil, err
     }
 
     // Redis connection
-    redisClient := redis.NewClient(&redis.Options{
-        Addr:     getEnv("REDIS_URL", "localhost:6379"),
-        Password: "",
-        DB:       0,
-    })
+    redisOpts, err := redisClientOptions(getEnv("REDIS_URL", "localhost:6379"))
+    if err != nil {
+        return nil, err
+    }
+    redisClient := redis.NewClient(redisOpts)
+
+    cacheBackend := getEnv("CACHE_BACKEND", "redis")
+    cache, err := newCache(cacheBackend, redisClient)
+    if err != nil {
+        return nil, fmt.Errorf("initialize cache: %w", err)
+    }
 
-    // Test connections
-    if err := mongoClient.Ping(context.Background(), nil); err != nil {
-        log.Fatal("MongoDB ping failed:", err)
+    var cacheState *degradableCache
+    if cacheBackend != "memory" {
+        cacheState = &degradableCache{backend: cache}
+        cache = cacheState
     }
 
     if err := redisClient.Ping(context.Background()).Err(); err != nil {
-        log.Fatal("Redis ping failed:", err)
+        // Redis is optional: without it there is no caching or live updates,
+        // but every request can still be served from Mongo
+        if cacheState != nil {
+            cacheState.degraded.Store(true)
+            log.Printf("⚠️ Redis ping failed, running without cache until it answers: %v", err)
+        } else {
+            log.Printf("⚠️ Redis ping failed, live updates unavailable: %v", err)
+        }
+    }
+
+    features, err := newFeatureProvider(getEnv("FEATURE_PROVIDER", "none"), redisClient)
+    if err != nil {
+        return nil, fmt.Errorf("initialize feature provider: %w", err)
+    }
+
+    postsWriteConcern, err := parseWriteConcern(
+        getEnv("MONGO_WRITE_CONCERN", "majority"),
+        getEnvBool("MONGO_WRITE_JOURNAL", false),
+    )
+    if err != nil {
+        return nil, fmt.Errorf("invalid write concern: %w", err)
     }
 
-    return &FeedService{
+    // Pagination cursors are signed so clients cannot forge positions
+    cursorSecret := []byte(getEnv("CURSOR_SECRET", ""))
+    if len(cursorSecret) == 0 {
+        log.Printf("⚠️ CURSOR_SECRET not set, using a random secret; cursors will not survive restarts or cross replicas")
+        cursorSecret = make([]byte, 32)
+        rand.Read(cursorSecret)
+    }
+
+    fs := &FeedService{
         mongo: mongoClient,
         redis: redisClient,
-        upgrader: websocket.Upgrader{
-            CheckOrigin: func(r *http.Request) bool {
-                return true // Allow all origins in development
-            },
+        cache: cache,
+        cacheState: cacheState,
+        features: features,
+        shuttingDown: make(chan struct{}),
+        wsOrigins: loadOriginAllowlist(),
+        wsMessageRate:       getEnvFloat("WS_MAX_MESSAGE_RATE", 5),
+        wsMessageBurst:      getEnvInt("WS_MESSAGE_BURST", 10),
+        wsDedupeWindow:      getEnvInt("WS_DEDUPE_WINDOW", 256),
+        wsCompressThreshold: getEnvInt("WS_COMPRESS_THRESHOLD", 0),
+        wsCompressScheme:    getEnv("WS_COMPRESS_SCHEME", "gzip"),
+        enableCompression:   getEnvBool("ENABLE_COMPRESSION", true),
+        compressionMinBytes: getEnvInt("COMPRESSION_MIN_BYTES", 1024),
+        wsSendBuffer:        getEnvInt("WS_SEND_BUFFER", 64),
+        wsOverflowPolicy:    getEnv("WS_OVERFLOW_POLICY", "drop-oldest"),
+        wsPubSubBuffer:      getEnvInt("WS_PUBSUB_BUFFER", 100),
+        wsBacklogMax:        getEnvInt("WS_BACKLOG_MAX", 200),
+        wsStreamTTL:         getEnvDuration("WS_STREAM_TTL", 24*time.Hour),
+        wsPingInterval:      getEnvDuration("WS_PING_INTERVAL", 30*time.Second),
+        wsPongTimeout:       getEnvDuration("WS_PONG_TIMEOUT", 60*time.Second),
+        quality: QualityConfig{
+            Enabled:          getEnvBool("FEED_QUALITY_FILTER", false),
+            MinContentLength: getEnvInt("FEED_QUALITY_MIN_LENGTH", 10),
         },
+        duplicates: DuplicateConfig{
+            Enabled:     getEnvBool("DUPLICATE_CONTENT_CHECK", false),
+            Window:      getEnvDuration("DUPLICATE_CONTENT_WINDOW", 10*time.Minute),
+            ExemptTypes: getEnvList("DUPLICATE_CONTENT_EXEMPT_TYPES"),
+        },
+        impressionSampleRate: getEnvFloat("IMPRESSION_SAMPLE_RATE", 1),
+        feedBackfill:         getEnvBool("FEED_BACKFILL", false),
+        emptyFeedTTL:         getEnvDuration("FEED_EMPTY_CACHE_TTL", 30*time.Minute),
+        emptyFeedSweeps:      make(chan struct{}, 1),
+        maxCachedPages:       getEnvInt("MAX_CACHED_PAGES_PER_USER", 3),
+        exploreRate:          getEnvFloat("FEED_EXPLORE_RATE", 0),
+        exploreMaxPerPage:    getEnvInt("FEED_EXPLORE_MAX_PER_PAGE", 2),
+        refreshDebounce:      getEnvDuration("FEED_REFRESH_DEBOUNCE", 10*time.Second),
+        promotedEvery:        getEnvInt("FEED_PROMOTED_EVERY", 0),
+        promotedMaxPerPage:   getEnvInt("FEED_PROMOTED_MAX_PER_PAGE", 1),
+        dwellMinMs:           int64(getEnvInt("DWELL_MIN_MS", 100)),
+        dwellMaxMs:           int64(getEnvInt("DWELL_MAX_MS", 10*60*1000)),
+        dwellAffinityWeight:  getEnvFloat("DWELL_AFFINITY_WEIGHT", 0.5),
+        commentMaxDepth:      getEnvInt("COMMENT_MAX_DEPTH", 3),
+        maxFollowedTags:      getEnvInt("MAX_FOLLOWED_TAGS", 100),
+        postsWriteConcern:    postsWriteConcern,
+        opTimeout:            getEnvDuration("DB_OP_TIMEOUT", 5*time.Second),
+        feedCacheTTL:         getEnvDuration("FEED_CACHE_TTL", 5*time.Minute),
+        feedTotalTTL:         getEnvDuration("FEED_TOTAL_CACHE_TTL", 30*time.Minute),
+        cacheInvalidateTimeout: getEnvDuration("CACHE_INVALIDATE_TIMEOUT", 10*time.Second),
+        searchCacheTTL:       getEnvDuration("SEARCH_CACHE_TTL", time.Minute),
+        postCacheTTL:         getEnvDuration("POST_CACHE_TTL", 30*time.Second),
+        trendingCacheTTL:     getEnvDuration("TRENDING_CACHE_TTL", 10*time.Minute),
+        trendingWeights:      loadTrendingWeights(),
+        trendingIncludeFriends: getEnvBool("TRENDING_INCLUDE_FRIENDS", false),
+        trendingStaleTTL:     getEnvDuration("TRENDING_STALE_TTL", 24*time.Hour),
+        cursors:              cursor.New(cursorSecret, getEnvDuration("CURSOR_TTL", 24*time.Hour)),
+        metrics:              newFeedMetrics(),
+        redisCheckInterval:   getEnvDuration("REDIS_CHECK_INTERVAL", 10*time.Second),
+        maxAggregationResults: getEnvInt("MAX_AGGREGATION_RESULTS", 100),
+        useServerTime:        getEnvBool("USE_SERVER_TIME", false),
+        adminToken:           getEnv("ADMIN_TOKEN", ""),
+        maxFeedLimit:         getEnvInt("MAX_FEED_LIMIT", 50),
+        maxSeenIDs:           getEnvInt("MAX_SEEN_IDS", 500),
+        maxPostMedia:         getEnvInt("MAX_POST_MEDIA", 10),
+        cacheWarmConcurrency: getEnvInt("CACHE_WARM_CONCURRENCY", 8),
+        cacheWarmMaxUsers:    getEnvInt("CACHE_WARM_MAX_USERS", 1000),
+        cacheInvalidateMaxUsers: getEnvInt("CACHE_INVALIDATE_MAX_USERS", 1000),
+        cdnBase:              parseCDNBase(os.Getenv("CDN_BASE_URL")),
+        viewDedupWindow:      getEnvDuration("VIEW_DEDUP_WINDOW", 30*time.Minute),
+        shareDedupWindow:     getEnvDuration("SHARE_DEDUP_WINDOW", time.Hour),
+        viewFlushInterval:    getEnvDuration("VIEW_FLUSH_INTERVAL", 10*time.Second),
+        maxFeedAuthors:       getEnvInt("MAX_FEED_AUTHORS", 200),
+        rateLimit:            getEnvInt("FEED_RATE_LIMIT", 120),
+        internalToken:        getEnv("INTERNAL_SERVICE_TOKEN", ""),
+        friendsCacheTTL:      getEnvDuration("FRIENDS_CACHE_TTL", time.Minute),
+        friendInlineMax:      getEnvInt("FRIEND_INLINE_MAX", 5000),
+        blocksCacheTTL:       getEnvDuration("BLOCKS_CACHE_TTL", 10*time.Minute),
+        userStatsTTL:         getEnvDuration("USER_STATS_CACHE_TTL", 5*time.Minute),
     }
+    // HandleWebSocket already answered 403 for disallowed origins; replacing
+    // gorilla's same-origin default lets allowlisted cross-origin apps through
+    fs.upgrader.CheckOrigin = fs.wsOrigins.Allows
+    fs.upgrader.EnableCompression = fs.enableCompression
+    fs.feedInvalidations = newInvalidationCoalescer(
+        getEnvDuration("CACHE_INVALIDATE_COALESCE_WINDOW", time.Second),
+        fs.cacheInvalidateTimeout,
+        getEnvInt("CACHE_INVALIDATE_CONCURRENCY", 8),
+        func(ctx context.Context, userID string) (int64, error) {
+            return fs.cache.DeleteMatching(ctx, userFeedKeyPattern(userID))
+        },
+    )
+
+    log.Printf("Cache TTLs: feed %s, trending %s, search %s", fs.feedCacheTTL, fs.trendingCacheTTL, fs.searchCacheTTL)
+
+    if fs.wsPongTimeout <= fs.wsPingInterval {
+        log.Printf("⚠️ WS_PONG_TIMEOUT (%s) must exceed WS_PING_INTERVAL (%s), using %s", fs.wsPongTimeout, fs.wsPingInterval, 2*fs.wsPingInterval)
+        fs.wsPongTimeout = 2 * fs.wsPingInterval
+    }
+
+    fs.ensureIndexes(context.Background())
+
+    if _, healthy := fs.checkDependencies(context.Background()); healthy {
+        fs.ready.Store(true)
+    }
+
+    if fs.useServerTime {
+        if err := fs.syncServerClock(context.Background()); err != nil {
+            log.Printf("⚠️ Server clock sync failed, using local time until it succeeds: %v", err)
+        }
+    }
+
+    return fs, nil
 }
 
 func (fs *FeedService) GetPersonalizedFeed(c *gin.Context) {
     var req FeedRequest
-    if err := c.ShouldBindJSON(&req); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+    if !bindJSON(c, &req) {
+        return
+    }
+    fs.serveFeed(c, req)
+}
+
+// GetFeed is the GET form of GetPersonalizedFeed for browsers, prefetchers and
+// edge caches: the user comes from the auth context, page, limit and cursor
+// from the query string.
+func (fs *FeedService) GetFeed(c *gin.Context) {
+    req := FeedRequest{
+        UserID: requestUserID(c),
+        Cursor: c.Query("cursor"),
+        Mode:   c.Query("mode"),
+
+        IncludeTotal: c.Query("includeTotal") == "true",
+    }
+    if req.UserID == "" {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
         return
     }
 
-    // Set defaults
-    if req.Page == 0 {
-        req.Page = 1
+    if !parsePageQuery(c, &req) {
+        return
     }
-    if req.Limit == 0 {
-        req.Limit = 10
+    fs.serveFeed(c, req)
+}
+
+// serveFeed is the code path shared by the feed handlers once the request has
+// been parsed: defaults, cursor, cache-or-build and the response.
+func (fs *FeedService) serveFeed(c *gin.Context, req FeedRequest) {
+    switch req.Mode {
+    case FeedModePersonalized:
+        // Same feed, and cache key, as no mode at all
+        req.Mode = ""
+    case "", FeedModeFollowing, FeedModeTags, FeedModeAuthors:
+    default:
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid mode")
+        return
     }
 
-    // Check Redis cache first
-    cacheKey := fmt.Sprintf("feed:%s:page:%d:limit:%d", req.UserID, req.Page, req.Limit)
-    cachedData, err := fs.redis.Get(context.Background(), cacheKey).Result()
-    
-    if err == nil {
-        // Cache hit
-        var cachedFeed []Post
-        if json.Unmarshal([]byte(cachedData), &cachedFeed) == nil {
-            c.JSON(http.StatusOK, FeedResponse{
-                Success:  true,
-                Posts:    cachedFeed,
-                CacheHit: true,
-                Pagination: struct {
-                    Page    int  `json:"page"`
-                    Limit   int  `json:"limit"`
-                    HasMore bool `json:"hasMore"`
-                }{
-                    Page:    req.Page,
-                    Limit:   req.Limit,
-                    HasMore: len(cachedFeed) == req.Limit,
-                },
-            })
+    if !fs.applyPageDefaults(c, &req) {
+        return
+    }
+
+    if !fs.applyFeedCursor(c, &req) {
+        return
+    }
+
+    if !fs.applySeenIDs(c, &req) {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    if req.Mode == FeedModeTags {
+        userObjectID, err := primitive.ObjectIDFromHex(req.UserID)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request")
+            return
+        }
+        if req.FollowedTags, err = fs.fetchFollowedTags(ctx, userObjectID); err != nil {
+            respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch feed")
             return
         }
     }
 
-    // Cache miss - fetch from database
-    posts, err := fs.fetchFeedFromDB(req.UserID, req.Page, req.Limit)
+    result, err := fs.getOrBuildFeed(ctx, req)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feed"})
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch feed")
         return
     }
+    // The client already holds this page; impressions were recorded when it
+    // was first served
+    if notModified(c, result.ETag) {
+        return
+    }
+    if req.IncludeTotal {
+        // The page is already in hand, so a failed count only drops the total
+        if total, err := fs.feedTotal(ctx, req); err != nil {
+            log.Printf("Feed total failed for user %s: %v", req.UserID, err)
+        } else {
+            result.Total = &total
+        }
+    }
+    fs.recordImpressions(req.UserID, result.Posts)
+    if c.Query("render") == "html" {
+        fs.attachRenderedHTML(ctx, result.Posts)
+    }
+    fs.rewriteMediaURLs(result.Posts)
 
-    // Cache the results for 5 minutes
-    postsJSON, _ := json.Marshal(posts)
-    fs.redis.Set(context.Background(), cacheKey, postsJSON, 5*time.Minute)
+    respondJSON(c, http.StatusOK, fs.newFeedResponse(req, result))
+}
 
-    c.JSON(http.StatusOK, FeedResponse{
-        Success:  true,
-        Posts:    posts,
-        CacheHit: false,
+func (fs *FeedService) newFeedResponse(req FeedRequest, result feedResult) FeedResponse {
+    hasMore := result.HasMore
+    nextCursor := ""
+    if hasMore {
+        nextCursor = fs.nextFeedCursor(result.Posts)
+    }
+
+    return FeedResponse{
+        Success:   true,
+        Posts:     result.Posts,
+        CacheHit:  result.CacheHit,
+        Debounced: result.Debounced,
         Pagination: struct {
-            Page    int  `json:"page"`
-            Limit   int  `json:"limit"`
-            HasMore bool `json:"hasMore"`
+            Page       int    `json:"page"`
+            Limit      int    `json:"limit"`
+            HasMore    bool   `json:"hasMore"`
+            NextCursor string `json:"nextCursor,omitempty"`
+            Total      *int64 `json:"total,omitempty"`
         }{
-            Page:    req.Page,
-            Limit:   req.Limit,
-            HasMore: len(posts) == req.Limit,
+            Page:       req.Page,
+            Limit:      req.Limit,
+            HasMore:    hasMore,
+            NextCursor: nextCursor,
+            Total:      result.Total,
         },
+    }
+}
+
+// feedResult is a feed page plus how it was obtained.
+type feedResult struct {
+    Posts     []Post
+    HasMore   bool
+    Total     *int64
+    ETag      string
+    CacheHit  bool
+    Debounced bool
+}
+
+// userFeedKeyPattern matches every cached feed page of one user.
+func userFeedKeyPattern(userID string) string {
+    return fmt.Sprintf("feed:%s:*", userID)
+}
+
+func feedCacheKey(req FeedRequest) string {
+    cacheKey := fmt.Sprintf("feed:%s:page:%d:limit:%d", req.UserID, req.Page, req.Limit)
+    if req.Seed != nil {
+        cacheKey += fmt.Sprintf(":seed:%d", *req.Seed)
+    }
+    cacheKey += feedSelectorSuffix(req)
+    if len(req.Seen) > 0 {
+        cacheKey += ":seen:" + authorSetHash(req.Seen)
+    }
+    if req.After != nil {
+        cacheKey += fmt.Sprintf(":after:%d:%s", req.After.CreatedAt.UnixNano(), req.After.ID.Hex())
+    }
+    return cacheKey
+}
+
+// feedTotalCacheKey sits under the user's feed pattern, so anything that
+// invalidates the user's pages drops the total with them.
+func feedTotalCacheKey(req FeedRequest) string {
+    return fmt.Sprintf("feed:%s:total", req.UserID) + feedSelectorSuffix(req)
+}
+
+// feedSelectorSuffix encodes the parts of a request that choose which posts
+// are in the feed at all, as opposed to which page of it.
+func feedSelectorSuffix(req FeedRequest) string {
+    cacheKey := ""
+    if req.AuthorVerified {
+        cacheKey += ":verified"
+    }
+    if req.Mode == FeedModeTags {
+        cacheKey += ":tags:" + tagSetHash(req.FollowedTags)
+    }
+    if req.Mode == FeedModeAuthors {
+        cacheKey += ":authors:" + authorSetHash(req.Authors)
+    }
+    if req.Mode == FeedModeFollowing {
+        cacheKey += ":following"
+    }
+    return cacheKey
+}
+
+// feedPageCacheable reports whether a page falls within the per-user cache
+// cap. Deeper pages are rarely revisited and would otherwise let one user pin
+// unbounded Redis memory.
+func (fs *FeedService) feedPageCacheable(req FeedRequest) bool {
+    return fs.maxCachedPages <= 0 || req.Page <= fs.maxCachedPages
+}
+
+// getOrBuildFeed serves a feed page from cache when possible and builds it
+// otherwise. Every caller that needs a feed page (HTTP handlers, cache
+// warming) goes through here or buildFeedShared.
+func (fs *FeedService) getOrBuildFeed(ctx context.Context, req FeedRequest) (feedResult, error) {
+    cacheable := fs.feedPageCacheable(req)
+    if !cacheable {
+        log.Printf("Feed page %d for user %s exceeds cached page cap (%d), serving uncached", req.Page, req.UserID, fs.maxCachedPages)
+    }
+
+    // Repeated force-refreshes within the debounce window get the page that
+    // was just built instead of rebuilding it
+    debounced := req.BypassCache && fs.refreshDebounced(ctx, req.UserID)
+
+    // Check Redis cache first
+    if cacheable && (!req.BypassCache || debounced) {
+        if cachedData, err := fs.cache.Get(ctx, feedCacheKey(req)); err == nil {
+            // Entries cached before pages carried hasMore are bare arrays;
+            // they fail to decode here and are rebuilt
+            var cachedFeed feedPage
+            if json.Unmarshal(cachedData, &cachedFeed) == nil {
+                fs.metrics.observeCacheLookup("feed", true)
+                if cachedFeed.ETag == "" {
+                    cachedFeed.ETag = pageETag(cachedFeed.Posts, cachedFeed.HasMore)
+                }
+                return feedResult{Posts: cachedFeed.Posts, HasMore: cachedFeed.HasMore, ETag: cachedFeed.ETag, CacheHit: true, Debounced: debounced}, nil
+            }
+        }
+        fs.metrics.observeCacheLookup("feed", false)
+    }
+
+    // Cache miss - build from database
+    page, err := fs.buildFeedShared(ctx, req)
+    if err != nil {
+        return feedResult{}, err
+    }
+    posts := page.Posts
+    if req.BypassCache {
+        fs.markRefreshed(ctx, req.UserID)
+    }
+    return feedResult{Posts: posts, HasMore: page.HasMore, ETag: page.ETag}, nil
+}
+
+// buildFeedShared runs buildFeed for req, sharing one build between
+// concurrent callers on the same cache key instead of stampeding Mongo; the
+// error, if any, reaches every waiter. It skips the cache read, so cache
+// warming calls it directly to replace the cached page, and getOrBuildFeed
+// calls it on a miss. The build has its own deadline so one caller giving up
+// does not fail the others. Each caller gets its own copy of the posts, since
+// handlers decorate them in place.
+func (fs *FeedService) buildFeedShared(ctx context.Context, req FeedRequest) (feedPage, error) {
+    builds := fs.feedBuilds.DoChan(feedCacheKey(req), func() (interface{}, error) {
+        buildCtx, cancel := fs.opContext(context.Background())
+        defer cancel()
+        return fs.buildFeed(buildCtx, req)
     })
+    var shared singleflight.Result
+    select {
+    case shared = <-builds:
+    case <-ctx.Done():
+        return feedPage{}, ctx.Err()
+    }
+    if shared.Err != nil {
+        return feedPage{}, shared.Err
+    }
+    page := shared.Val.(feedPage)
+    page.Posts = append([]Post(nil), page.Posts...)
+    return page, nil
 }
 
-func (fs *FeedService) fetchFeedFromDB(userID string, page, limit int) ([]Post, error) {
+// buildFeed computes a feed page from the database and stores it in the
+// cache, unconditionally replacing any cached copy.
+func (fs *FeedService) buildFeed(ctx context.Context, req FeedRequest) (feedPage, error) {
+    if userID, err := primitive.ObjectIDFromHex(req.UserID); err == nil {
+        blocked, err := fs.blockedAuthorIDs(ctx, userID)
+        if err != nil {
+            return feedPage{}, err
+        }
+        req.Blocked = blocked
+    }
+
+    page, err := fs.fetchFeedFromDB(ctx, req)
+    if err != nil {
+        return feedPage{}, err
+    }
+    pinned, posts := splitPinned(page.Posts, req.UserID)
+    posts = filterByQuality(posts, fs.quality)
+    posts = fs.rankByFeatures(ctx, req.UserID, posts)
+    // A curated author list, or the following feed, shows only those authors
+    if req.Mode != FeedModeAuthors && req.Mode != FeedModeFollowing {
+        posts = fs.backfillFeed(ctx, posts, req.Page, req.Limit)
+        posts = fs.injectExploration(ctx, posts, req.UserID, req.Limit, req.Seed)
+        posts = fs.injectPromoted(ctx, posts, req.Page)
+        // Injected posts come from outside the feed query and its block clause
+        posts = withoutAuthors(posts, req.Blocked)
+    }
+    // Quality filtering may drop rows, but hasMore still follows the query
+    page.Posts = hoistPinned(posts, pinned)
+    page.ETag = pageETag(page.Posts, page.HasMore)
+
+    // Cache the results for FEED_CACHE_TTL, empty feeds for longer
+    if fs.feedPageCacheable(req) {
+        cacheKey := feedCacheKey(req)
+        postsJSON, _ := json.Marshal(page)
+        if len(page.Posts) == 0 {
+            fs.cache.Set(ctx, emptyFeedMarkerPrefix+cacheKey, []byte("1"), fs.emptyFeedTTL)
+            fs.cache.Set(ctx, cacheKey, postsJSON, fs.emptyFeedTTL)
+        } else {
+            fs.cache.Set(ctx, cacheKey, postsJSON, fs.feedCacheTTL)
+        }
+    }
+    return page, nil
+}
+
+func (fs *FeedService) fetchFeedFromDB(ctx context.Context, req FeedRequest) (feedPage, error) {
+    defer fs.metrics.observeQuery("feed", time.Now())
     collection := fs.mongo.Database("crown-social").Collection("posts")
-    
+
+    query, err := fs.feedFilter(ctx, req)
+    if err != nil {
+        return feedPage{}, err
+    }
+    if query.Filter == nil {
+        return feedPage{Posts: []Post{}}, nil
+    }
+    // The viewer's pinned post leads the first page of their personalized
+    // feed, on top of the limit, and is kept out of the query on every page so
+    // it never shows up again further down
+    var pinned *Post
+    if req.Mode == "" {
+        if userID, err := primitive.ObjectIDFromHex(req.UserID); err == nil {
+            if pinned, err = fs.pinnedPost(ctx, userID); err != nil {
+                return feedPage{}, err
+            }
+        }
+    }
+    excluded := req.Seen
+    if pinned != nil {
+        excluded = append(append([]primitive.ObjectID{}, req.Seen...), pinned.ID)
+    }
+    if len(excluded) > 0 {
+        query.Filter["_id"] = bson.M{"$nin": excluded}
+    }
+
+    page, err := findFeedPage(ctx, collection, query, req)
+    if err != nil {
+        return feedPage{}, err
+    }
+    if pinned != nil && req.Page == 1 && req.After == nil {
+        page.Posts = hoistPinned(page.Posts, pinned)
+    }
+    return page, nil
+}
+
+// feedTotal counts every post the feed query can return, cached for
+// FEED_TOTAL_CACHE_TTL. It ignores the cursor and seenIds so the figure holds
+// steady while the client scrolls, and counts query results only: injected
+// posts and ones dropped by quality filtering leave it slightly off.
+func (fs *FeedService) feedTotal(ctx context.Context, req FeedRequest) (int64, error) {
+    key := feedTotalCacheKey(req)
+    if data, err := fs.cache.Get(ctx, key); err == nil {
+        if total, err := strconv.ParseInt(string(data), 10, 64); err == nil {
+            return total, nil
+        }
+    }
+
+    defer fs.metrics.observeQuery("feed_total", time.Now())
+    if userID, err := primitive.ObjectIDFromHex(req.UserID); err == nil {
+        if req.Blocked, err = fs.blockedAuthorIDs(ctx, userID); err != nil {
+            return 0, err
+        }
+    }
+    query, err := fs.feedFilter(ctx, req)
+    if err != nil {
+        return 0, err
+    }
+    var total int64
+    if query.Filter != nil {
+        collection := fs.mongo.Database("crown-social").Collection("posts")
+        if total, err = query.count(ctx, collection); err != nil {
+            return 0, err
+        }
+    }
+    fs.cache.Set(ctx, key, []byte(strconv.FormatInt(total, 10)), fs.feedTotalTTL)
+    return total, nil
+}
+
+// feedFilter is the feed query without pagination or seenIds. A nil Filter
+// means the feed is empty by construction, e.g. following mode with no
+// friends.
+func (fs *FeedService) feedFilter(ctx context.Context, req FeedRequest) (feedQuery, error) {
     // Convert userID to ObjectID
-    userObjectID, err := primitive.ObjectIDFromHex(userID)
+    userObjectID, err := primitive.ObjectIDFromHex(req.UserID)
     if err != nil {
-        return nil, err
+        return feedQuery{}, err
     }
 
-    // In production, this would include friend filtering
-    filter := bson.M{
-        "isActive": true,
-        "$or": []bson.M{
-            {"visibility": "public"},
-            {"author": userObjectID}, // User's own posts
-        },
+    rel, err := fs.resolveRelationship(ctx, userObjectID)
+    if err != nil {
+        return feedQuery{}, err
+    }
+
+    // Past FRIEND_INLINE_MAX the friend list is joined, not inlined
+    var query feedQuery
+    inline := rel
+    if fs.friendInlineMax > 0 && len(rel.Friends) > fs.friendInlineMax {
+        query.JoinFriendsOf = userObjectID
+        query.FollowingOnly = req.Mode == FeedModeFollowing
+        inline.Friends = nil
+    }
+    filter := visibilityFilter(inline)
+    if query.joinsFriends() {
+        filter["$or"] = append(filter["$or"].([]bson.M), bson.M{"visibility": VisibilityFriends})
+    }
+    filter["isActive"] = true
+    filter["deletedAt"] = nil
+    if req.Mode == FeedModeTags {
+        if len(req.FollowedTags) == 0 {
+            return feedQuery{}, nil
+        }
+        filter["tags"] = bson.M{"$in": req.FollowedTags}
+    }
+    author := bson.M{}
+    if req.Mode == FeedModeAuthors {
+        if len(req.Authors) == 0 {
+            return feedQuery{}, nil
+        }
+        author["$in"] = req.Authors
+    }
+    if req.Mode == FeedModeFollowing {
+        // Visibility still applies on top, so this narrows the public and
+        // own-post branches away rather than widening anything
+        if len(rel.Friends) == 0 {
+            return feedQuery{}, nil
+        }
+        if !query.joinsFriends() {
+            author["$in"] = rel.Friends
+        }
+    }
+    if len(req.Blocked) > 0 {
+        author["$nin"] = req.Blocked
+    }
+    if len(author) > 0 {
+        filter["author"] = author
+    }
+    if req.AuthorVerified {
+        filter["authorVerified"] = true
     }
+    query.Filter = filter
+    return query, nil
+}
+
+// findPostsPage runs a newest-first page query for filter, paginated by
+// req's cursor or page offset. One row past the page is fetched to learn
+// whether another page exists, then dropped.
+func findPostsPage(ctx context.Context, collection *mongo.Collection, filter bson.M, req FeedRequest) (feedPage, error) {
+    return findFeedPage(ctx, collection, feedQuery{Filter: filter}, req)
+}
 
-    // Calculate skip
-    skip := (page - 1) * limit
+// findFeedPage is findPostsPage for a feed query that may join friends.
+func findFeedPage(ctx context.Context, collection *mongo.Collection, query feedQuery, req FeedRequest) (feedPage, error) {
+    // Calculate skip; a cursor seeks by range instead, which stays cheap
+    // however deep the client scrolls
+    skip := (req.Page - 1) * req.Limit
+    if req.After != nil {
+        query.Filter["$and"] = []bson.M{afterFilter(*req.After)}
+        skip = 0
+    }
 
-    // Query options
-    opts := options.Find().
-        SetSort(bson.D{{Key: "createdAt", Value: -1}}).
-        SetSkip(int64(skip)).
-        SetLimit(int64(limit))
+    // _id breaks createdAt ties so pages never overlap
+    newestFirst := bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+    posts, err := query.find(ctx, collection, newestFirst, skip, req.Limit+1)
+    if err != nil {
+        return feedPage{}, err
+    }
+    return trimPage(posts, req.Limit), nil
+}
 
-    cursor, err := collection.Find(context.Background(), filter, opts)
+func findPosts(ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptions) ([]Post, error) {
+    cursor, err := collection.Find(ctx, filter, opts)
     if err != nil {
         return nil, err
     }
-    defer cursor.Close(context.Background())
+    defer cursor.Close(ctx)
 
     var posts []Post
-    if err := cursor.All(context.Background(), &posts); err != nil {
+    if err := cursor.All(ctx, &posts); err != nil {
         return nil, err
     }
-
     return posts, nil
 }
 
 func (fs *FeedService) GetTrendingPosts(c *gin.Context) {
-    timeframe := c.DefaultQuery("timeframe", "24h")
-    limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+    limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+    if err != nil || limit < 0 {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "limit must be a non-negative integer")
+        return
+    }
+    if limit == 0 {
+        limit = 20
+    }
+    maxLimit := fs.maxFeedLimit
+    if fs.maxAggregationResults < maxLimit {
+        maxLimit = fs.maxAggregationResults
+    }
+    limitClamped := limit > maxLimit
+    if limitClamped {
+        limit = maxLimit
+    }
+    page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+    if err != nil || page < 0 {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "page must be a non-negative integer")
+        return
+    }
+    if page == 0 {
+        page = 1
+    }
+    query := TrendingQuery{
+        Timeframe:    c.DefaultQuery("timeframe", "24h"),
+        Limit:        limit,
+        Page:         page,
+        VerifiedOnly: c.Query("author_verified") == "true",
+
+        IncludeFriends: fs.trendingIncludeFriends,
+    }
+    if token := c.Query("cursor"); token != "" {
+        // Feed cursors carry no score and cannot position a trending page
+        fields, err := fs.cursors.Decode(token)
+        if err != nil || fields.Score == nil {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid or expired cursor")
+            return
+        }
+        query.After = &fields
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    // Trending is cached for everyone, so the caller's blocks and, with
+    // friends posts included, visibility are applied to the result rather
+    // than the aggregation; a page may come up short. For the same reason the
+    // ETag is taken from the filtered list each time rather than cached
+    var blocked []primitive.ObjectID
+    viewer, _ := primitive.ObjectIDFromHex(requestUserID(c))
+    if !viewer.IsZero() {
+        if blocked, err = fs.blockedAuthorIDs(ctx, viewer); err != nil {
+            respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch trending posts")
+            return
+        }
+    }
+    var rel viewerRelationship
+    if query.IncludeFriends {
+        if rel, err = fs.resolveRelationship(ctx, viewer); err != nil {
+            respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch trending posts")
+            return
+        }
+    }
+    forViewer := func(posts []Post) []Post {
+        posts = withoutAuthors(posts, blocked)
+        if query.IncludeFriends {
+            posts = visibleTo(posts, rel)
+        }
+        return posts
+    }
 
-    // Check cache first
-    cacheKey := fmt.Sprintf("trending:%s:limit:%d", timeframe, limit)
-    cachedData, err := fs.redis.Get(context.Background(), cacheKey).Result()
+    // Check cache first. Results cached before pagination were bare post
+    // lists; they fail to decode as a page and are treated as a miss
+    cacheKey := query.cacheKey()
+    cachedData, err := fs.cache.Get(ctx, cacheKey)
     
     if err == nil {
-        var cachedPosts []Post
-        if json.Unmarshal([]byte(cachedData), &cachedPosts) == nil {
-            c.JSON(http.StatusOK, gin.H{
-                "success":  true,
-                "posts":    cachedPosts,
-                "cacheHit": true,
+        var cachedPage feedPage
+        if json.Unmarshal(cachedData, &cachedPage) == nil {
+            fs.metrics.observeCacheLookup("trending", true)
+            pagination := fs.trendingPagination(query, cachedPage)
+            cachedPosts := forViewer(cachedPage.Posts)
+            if notModified(c, pageETag(cachedPosts, cachedPage.HasMore)) {
+                return
+            }
+            fs.rewriteMediaURLs(cachedPosts)
+            respondJSON(c, http.StatusOK, gin.H{
+                "success":      true,
+                "posts":        cachedPosts,
+                "pagination":   pagination,
+                "cacheHit":     true,
+                "limitClamped": limitClamped,
+                "scoreFormula": fs.trendingWeights.Formula(),
             })
             return
         }
     }
 
+    fs.metrics.observeCacheLookup("trending", false)
+
     // Fetch from database
-    posts, err := fs.fetchTrendingFromDB(timeframe, limit)
+    result, err := fs.fetchTrendingFromDB(ctx, query)
     if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch trending posts"})
+        log.Printf("Trending aggregation failed: %v", err)
+
+        // Serve the last known list rather than failing discovery outright;
+        // the aggregation may have used up the deadline, so the fallback
+        // read gets a fresh one
+        staleCtx, cancelStale := fs.opContext(context.Background())
+        defer cancelStale()
+        if stalePage, ok := fs.staleTrending(staleCtx, cacheKey); ok {
+            fs.refreshTrendingAsync(query)
+            pagination := fs.trendingPagination(query, stalePage)
+            stalePosts := forViewer(stalePage.Posts)
+            if notModified(c, pageETag(stalePosts, stalePage.HasMore)) {
+                return
+            }
+            fs.rewriteMediaURLs(stalePosts)
+            c.Header("X-Cache", "STALE")
+            respondJSON(c, http.StatusOK, gin.H{
+                "success":      true,
+                "posts":        stalePosts,
+                "pagination":   pagination,
+                "cacheHit":     true,
+                "stale":        true,
+                "limitClamped": limitClamped,
+                "scoreFormula": fs.trendingWeights.Formula(),
+            })
+            return
+        }
+
+        if isTimeout(err) {
+            respondError(c, http.StatusGatewayTimeout, ErrCodeTimeout, "Request timed out")
+            return
+        }
+        respondError(c, http.StatusServiceUnavailable, ErrCodeFeedFetchFailed, "Failed to fetch trending posts")
         return
     }
 
-    // Cache results for 10 minutes
-    postsJSON, _ := json.Marshal(posts)
-    fs.redis.Set(context.Background(), cacheKey, postsJSON, 10*time.Minute)
+    // Cache results for TRENDING_CACHE_TTL
+    fs.cacheTrending(ctx, cacheKey, result)
+    pagination := fs.trendingPagination(query, result)
+    posts := forViewer(result.Posts)
+    if notModified(c, pageETag(posts, result.HasMore)) {
+        return
+    }
+    fs.rewriteMediaURLs(posts)
 
-    c.JSON(http.StatusOK, gin.H{
-        "success":  true,
-        "posts":    posts,
-        "cacheHit": false,
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":      true,
+        "posts":        posts,
+        "pagination":   pagination,
+        "cacheHit":     false,
+        "limitClamped": limitClamped,
+        "scoreFormula": fs.trendingWeights.Formula(),
     })
 }
 
-func (fs *FeedService) fetchTrendingFromDB(timeframe string, limit int) ([]Post, error) {
+// fetchTrendingFromDB ranks one page of trending posts. Scores are
+// recomputed from the current time and counts on every run, so pagination is
+// best-effort: a post whose score moves between requests can be repeated or
+// skipped across pages. Pages never reach past MAX_AGGREGATION_RESULTS.
+func (fs *FeedService) fetchTrendingFromDB(ctx context.Context, query TrendingQuery) (feedPage, error) {
+    defer fs.metrics.observeQuery("trending", time.Now())
     collection := fs.mongo.Database("crown-social").Collection("posts")
 
     // Calculate time range
     var hoursAgo time.Duration
-    switch timeframe {
+    switch query.Timeframe {
     case "24h":
         hoursAgo = 24 * time.Hour
     case "7d":
@@ -270,48 +1301,101 @@ func (fs *FeedService) fetchTrendingFromDB(timeframe string, limit int) ([]Post,
         hoursAgo = 24 * time.Hour
     }
 
-    since := time.Now().Add(-hoursAgo)
+    since := fs.now().Add(-hoursAgo)
+
+    match := bson.M{
+        "createdAt": bson.M{"$gte": since},
+        "isActive":  true,
+        "deletedAt": nil,
+        "visibility": VisibilityPublic,
+    }
+    if query.IncludeFriends {
+        // Never close_friends/private: those audiences are too narrow to rank for
+        match["visibility"] = bson.M{"$in": []string{VisibilityPublic, VisibilityFriends}}
+    }
+    if query.VerifiedOnly {
+        match["authorVerified"] = true
+    }
+
+    // A cursor seeks by score, which survives posts entering the ranking above
+    // it; a page number skips, and is bounded by the aggregation cap
+    skip := 0
+    if query.After == nil && query.Page > 1 {
+        // Every page past MAX_AGGREGATION_RESULTS is empty; returning early
+        // also keeps a huge page number from overflowing the skip
+        if query.Page-1 >= fs.maxAggregationResults {
+            return feedPage{Posts: []Post{}}, nil
+        }
+        skip = (query.Page - 1) * query.Limit
+    }
+    limit := fs.aggregationLimit(skip+query.Limit) - skip
+    if limit <= 0 {
+        return feedPage{Posts: []Post{}}, nil
+    }
+    lastPage := query.After == nil && skip+limit >= fs.maxAggregationResults
 
     // Aggregation pipeline for trending posts
     pipeline := []bson.M{
         {
-            "$match": bson.M{
-                "createdAt": bson.M{"$gte": since},
-                "isActive":  true,
-                "visibility": bson.M{"$in": []string{"public", "friends"}},
+            "$match": match,
+        },
+        {
+            "$addFields": bson.M{
+                "ageHours": ageHoursExpr(fs.now()),
             },
         },
         {
             "$addFields": bson.M{
-                "trendingScore": bson.M{
-                    "$add": []bson.M{
-                        {"$multiply": []interface{}{"$likesCount", 1}},
-                        {"$multiply": []interface{}{"$commentsCount", 2}},
-                        {"$multiply": []interface{}{"$sharesCount", 3}},
-                        {"$multiply": []interface{}{"$viewsCount", 0.1}},
-                    },
-                },
+                "trendingScore": fs.trendingWeights.scoreExpr(),
             },
         },
-        {"$sort": bson.M{"trendingScore": -1}},
-        {"$limit": limit},
     }
+    if query.After != nil {
+        pipeline = append(pipeline, bson.M{"$match": trendingAfterFilter(*query.After)})
+    }
+    // _id breaks score ties so pages never overlap
+    pipeline = append(pipeline,
+        bson.M{"$sort": bson.D{{Key: "trendingScore", Value: -1}, {Key: "_id", Value: -1}}},
+        bson.M{"$skip": skip},
+        bson.M{"$limit": limit + 1},
+    )
 
-    cursor, err := collection.Aggregate(context.Background(), pipeline)
+    cursor, err := collection.Aggregate(ctx, pipeline)
     if err != nil {
-        return nil, err
+        return feedPage{}, err
     }
-    defer cursor.Close(context.Background())
+    defer cursor.Close(ctx)
 
     var posts []Post
-    if err := cursor.All(context.Background(), &posts); err != nil {
-        return nil, err
+    if err := cursor.All(ctx, &posts); err != nil {
+        return feedPage{}, err
     }
+    fs.metrics.aggregationResults.WithLabelValues("trending").Observe(float64(len(posts)))
 
-    return posts, nil
+    page := trimPage(posts, limit)
+    if lastPage {
+        page.HasMore = false
+    }
+    return page, nil
+}
+
+// aggregationLimit clamps a requested $limit to MAX_AGGREGATION_RESULTS.
+func (fs *FeedService) aggregationLimit(limit int) int {
+    if limit > fs.maxAggregationResults {
+        return fs.maxAggregationResults
+    }
+    return limit
 }
 
 func (fs *FeedService) HandleWebSocket(c *gin.Context) {
+    // Checked before upgrading so a page on another site cannot ride the
+    // user's browser into their feed
+    if !fs.wsOrigins.Allows(c.Request) {
+        log.Printf("WebSocket upgrade rejected for origin %q", c.GetHeader("Origin"))
+        respondError(c, http.StatusForbidden, ErrCodeForbidden, "Origin not allowed")
+        return
+    }
+
     conn, err := fs.upgrader.Upgrade(c.Writer, c.Request, nil)
     if err != nil {
         log.Printf("WebSocket upgrade failed: %v", err)
@@ -325,20 +1409,113 @@ func (fs *FeedService) HandleWebSocket(c *gin.Context) {
         return
     }
 
+    select {
+    case <-fs.shuttingDown:
+        fs.closeWebSocket(conn, websocket.CloseGoingAway, "server shutting down")
+        return
+    default:
+    }
+    fs.wsConns.Add(1)
+    defer fs.wsConns.Done()
+    fs.metrics.wsActive.Inc()
+    defer fs.metrics.wsActive.Dec()
+
     log.Printf("WebSocket connected for user: %s", userID)
 
     // Subscribe to Redis channel for real-time updates
-    pubsub := fs.redis.Subscribe(context.Background(), fmt.Sprintf("user_feed:%s", userID))
+    subscribeCtx, cancelSubscribe := fs.opContext(c.Request.Context())
+    defer cancelSubscribe()
+    pubsub := fs.redis.Subscribe(subscribeCtx, userFeedChannel(userID))
     defer pubsub.Close()
 
-    ch := pubsub.Channel()
+    // With lastEventId, replay what was missed. The subscription is confirmed
+    // first so nothing published meanwhile falls between backlog and live;
+    // events in both are dropped by the dedupe window below
+    var missed *backlog
+    if lastEventID := c.Query("lastEventId"); lastEventID != "" {
+        if _, err := pubsub.Receive(subscribeCtx); err != nil {
+            log.Printf("WebSocket subscribe failed for user %s: %v", userID, err)
+            return
+        }
+        events, truncated, err := fs.missedEvents(subscribeCtx, userID, lastEventID)
+        if err != nil {
+            log.Printf("Failed to load missed events for user %s: %v", userID, err)
+        }
+        missed = &backlog{events: events, truncated: truncated}
+    }
+
+    ch := fs.pumpPubSub(pubsub)
+
+    // Reader goroutine enforces the inbound rate limit, forwards control
+    // messages and tells us when the client is gone
+    done := make(chan struct{})
+    controls := make(chan clientControl, 4)
+    go fs.readClientMessages(conn, userID, done, controls)
+
+    // Writes go through a bounded buffer so a slow client cannot stall
+    // Redis message processing
+    send := make(chan string, fs.wsSendBuffer)
+    writeFailed := make(chan struct{})
+    go fs.writeFrames(conn, send, writeFailed)
+    defer close(send)
+
+    delivered := newRecentEvents(fs.wsDedupeWindow)
+    if missed != nil {
+        for _, event := range missed.events {
+            delivered.Seen(eventID(event))
+        }
+        // The first page goes out unasked, even when empty, so the client
+        // knows whether anything was lost
+        fs.enqueueFrame(send, missed.next())
+    }
+
+    pings := time.NewTicker(fs.wsPingInterval)
+    defer pings.Stop()
 
     for {
+        // Live events wait in the pub/sub buffer until the backlog is done
+        live := ch
+        if missed.pending() {
+            live = nil
+        }
+
         select {
-        case msg := <-ch:
+        case <-done:
+            return
+        case control := <-controls:
+            switch control.Type {
+            case "backlog_next":
+                if missed.pending() {
+                    fs.enqueueFrame(send, missed.next())
+                }
+            case "backlog_skip":
+                missed = nil
+            }
+            switch control.Action {
+            case "subscribe", "unsubscribe":
+                fs.enqueueFrame(send, fs.updateSubscriptions(pubsub, userID, control))
+            }
+        case <-pings.C:
+            // WriteControl may run alongside the writer goroutine
+            if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+                return
+            }
+        case <-writeFailed:
+            return
+        case <-fs.shuttingDown:
+            fs.closeWebSocket(conn, websocket.CloseGoingAway, "server shutting down")
+            return
+        case msg, ok := <-live:
+            if !ok {
+                return
+            }
+            if delivered.Seen(eventID(msg.Payload)) {
+                continue
+            }
             // Forward Redis message to WebSocket client
-            if err := conn.WriteMessage(websocket.TextMessage, []byte(msg.Payload)); err != nil {
-                log.Printf("WebSocket write error: %v", err)
+            if !fs.enqueueFrame(send, tagChannel(msg.Channel, msg.Payload)) {
+                log.Printf("WebSocket send buffer full, disconnecting slow client: %s", userID)
+                fs.closeWebSocket(conn, websocket.CloseTryAgainLater, "client too slow")
                 return
             }
         }
@@ -347,36 +1524,79 @@ func (fs *FeedService) HandleWebSocket(c *gin.Context) {
 
 func (fs *FeedService) InvalidateCache(c *gin.Context) {
     userID := c.Param("userId")
-    
-    // Delete user's feed cache
-    pattern := fmt.Sprintf("feed:%s:*", userID)
-    iter := fs.redis.Scan(context.Background(), 0, pattern, 0).Iterator()
-    
-    var keys []string
-    for iter.Next(context.Background()) {
-        keys = append(keys, iter.Val())
+    // Dropping every user's trending results is the same operation as
+    // DELETE /cache/trending, so it takes the same internal token
+    dropTrending := c.Query("trending") == "true"
+    if dropTrending && !fs.isInternalRequest(c) {
+        respondError(c, http.StatusForbidden, ErrCodeForbidden, "Internal access required")
+        return
     }
     
-    if len(keys) > 0 {
-        fs.redis.Del(context.Background(), keys...)
+    // Bounded by its own timeout: a scan over a large keyspace legitimately
+    // outlasts DB_OP_TIMEOUT, but must not run unbounded
+    ctx, cancel := context.WithTimeout(c.Request.Context(), fs.cacheInvalidateTimeout)
+    defer cancel()
+
+    // Delete user's feed cache
+    deleted, err := fs.cache.DeleteMatching(ctx, userFeedKeyPattern(userID))
+    if err != nil {
+        status, code := http.StatusInternalServerError, ErrCodeWriteFailed
+        if isTimeout(err) {
+            status, code = http.StatusGatewayTimeout, ErrCodeTimeout
+        }
+        respondErrorDetails(c, status, code, "Failed to invalidate cache", gin.H{"keys_deleted": deleted})
+        return
     }
-    
-    c.JSON(http.StatusOK, gin.H{
+
+    response := gin.H{
         "success": true,
         "message": "Cache invalidated",
-        "keys_deleted": len(keys),
-    })
+        "keys_deleted": deleted,
+    }
+    // ?trending=true also drops trending results, for callers reacting to
+    // an engagement change rather than a follow change
+    if dropTrending {
+        deleted, err := fs.cache.DeleteMatching(ctx, trendingKeyPattern)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to invalidate trending cache")
+            return
+        }
+        response["trending_keys_deleted"] = deleted
+    }
+
+    respondJSON(c, http.StatusOK, response)
 }
 
 func (fs *FeedService) HealthCheck(c *gin.Context) {
-    c.JSON(http.StatusOK, gin.H{
-        "status":    "healthy",
-        "service":   "crown-feed-service-go",
-        "timestamp": time.Now(),
-        "version":   "1.0.0",
+    dependencies, healthy := fs.checkDependencies(c.Request.Context())
+    status, code := "healthy", http.StatusOK
+    if !healthy {
+        status, code = "unhealthy", http.StatusServiceUnavailable
+    } else if fs.cacheDegraded() {
+        status = "degraded"
+    }
+
+    respondJSON(c, code, gin.H{
+        "status":       status,
+        "degraded":     fs.cacheDegraded(),
+        "dependencies": dependencies,
+        "service":      "crown-feed-service-go",
+        "timestamp":    time.Now(),
+        "version":      "1.0.0",
     })
 }
 
+// getEnvList reads a comma-separated list, skipping empty entries.
+func getEnvList(key string) []string {
+    var values []string
+    for _, value := range strings.Split(os.Getenv(key), ",") {
+        if value = strings.TrimSpace(value); value != "" {
+            values = append(values, value)
+        }
+    }
+    return values
+}
+
 func getEnv(key, defaultValue string) string {
     if value := os.Getenv(key); value != "" {
         return value
@@ -384,37 +1604,103 @@ func getEnv(key, defaultValue string) string {
     return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+    if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+        return value
+    }
+    return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+    if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+        return value
+    }
+    return defaultValue
+}
+
+// getEnvDuration reads a positive duration; unset, unparseable or
+// non-positive values fall back to the default, since a zero TTL would cache
+// forever and a zero interval can't drive a ticker.
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+    value := getEnv(key, "")
+    if value == "" {
+        return defaultValue
+    }
+    d, err := time.ParseDuration(value)
+    if err != nil || d <= 0 {
+        log.Printf("⚠️ Invalid %s %q, using %s", key, value, defaultValue)
+        return defaultValue
+    }
+    return d
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+    if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+        return value
+    }
+    return defaultValue
+}
+
 func main() {
+    // SIGINT/SIGTERM cancel ctx, which stops background jobs and starts the drain
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+
     // Initialize service
-    feedService := NewFeedService()
+    feedService, err := NewFeedService()
+    if err != nil {
+        log.Fatal("Failed to start feed service: ", err)
+    }
+    go feedService.runPrewarm(ctx)
+    go feedService.runServerClockSync(ctx)
+    go feedService.runCounterReconciliation(ctx)
+    go feedService.runViewFlush(ctx)
+    go feedService.runEmptyFeedSweeps(ctx)
+    go feedService.runRedisCheck(ctx)
     
     // Setup Gin router
-    r := gin.Default()
+    r := gin.New()
+    r.Use(gin.Logger(), RequestID(), feedService.metrics.RequestMetrics(), Recovery())
+    r.Use(BodyLimit(int64(getEnvInt("MAX_BODY_BYTES", 1<<20))))
+    if feedService.enableCompression {
+        r.Use(Gzip(feedService.compressionMinBytes))
+    }
+    if getEnvBool("ALLOW_PRETTY_JSON", gin.Mode() != gin.ReleaseMode) {
+        r.Use(PrettyJSON())
+    }
     
     // CORS middleware
     r.Use(cors.New(cors.Config{
         AllowAllOrigins:  true,
         AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-        AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-        ExposeHeaders:    []string{"Content-Length"},
+        AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-None-Match"},
+        ExposeHeaders:    []string{"Content-Length", "ETag", "Retry-After", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset"},
         AllowCredentials: true,
         MaxAge:          12 * time.Hour,
     }))
 
-    // Routes
-    api := r.Group("/api/v1")
-    {
-        api.GET("/health", feedService.HealthCheck)
-        api.POST("/feed", feedService.GetPersonalizedFeed)
-        api.GET("/trending", feedService.GetTrendingPosts)
-        api.DELETE("/cache/:userId", feedService.InvalidateCache)
-        api.GET("/ws", feedService.HandleWebSocket)
-    }
+    r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+    // Routes (see routes.go); the same table drives /openapi.json
+    routes := feedService.routes()
+    registerRoutes(r.Group("/api/v1"), routes, routeMiddleware{
+        Admin:     AdminAuth(feedService.adminToken),
+        Internal:  InternalAuth(feedService.internalToken),
+        RateLimit: feedService.RateLimit(),
+    })
+    r.GET("/openapi.json", OpenAPIHandler("/api/v1", routes))
 
     port := getEnv("FEED_SERVICE_PORT", "3002")
     log.Printf("🚀 Crown Feed Service (Go) starting on port %s", port)
-    
-    if err := r.Run(":" + port); err != nil {
-        log.Fatal("Failed to start server:", err)
-    }
+
+    server := &http.Server{Addr: ":" + port, Handler: r}
+    go func() {
+        if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+            log.Fatal("Failed to start server:", err)
+        }
+    }()
+
+    <-ctx.Done()
+    stop()
+    feedService.shutdown(getEnvDuration("SHUTDOWN_TIMEOUT", 15*time.Second), server)
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "net/http/httptest"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/alicebob/miniredis/v2"
+    "github.com/gin-gonic/gin"
+    "github.com/go-redis/redis/v8"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/writeconcern"
+
+    "crown-feed-service/cursor"
+)
+
+func init() {
+    gin.SetMode(gin.TestMode)
+}
+
+// The collectors register globally, so every test service shares one set.
+var (
+    testMetricsOnce sync.Once
+    testMetrics     *feedMetrics
+)
+
+// newTestService returns a FeedService over client (nil for tests that never
+// reach Mongo), an in-memory cache and a Redis that refuses connections, so
+// publishes fail fast and are only logged.
+func newTestService(t *testing.T, client *mongo.Client) *FeedService {
+    t.Helper()
+    testMetricsOnce.Do(func() { testMetrics = newFeedMetrics() })
+
+    rdb := redis.NewClient(&redis.Options{
+        Addr:        "127.0.0.1:1",
+        DialTimeout: 50 * time.Millisecond,
+        MaxRetries:  -1,
+    })
+    t.Cleanup(func() { rdb.Close() })
+
+    fs := &FeedService{
+        mongo:                  client,
+        redis:                  rdb,
+        cache:                  newMemoryCache(),
+        shuttingDown:           make(chan struct{}),
+        emptyFeedSweeps:        make(chan struct{}, 1),
+        postsWriteConcern:      writeconcern.New(writeconcern.W(1)),
+        opTimeout:              2 * time.Second,
+        feedCacheTTL:           time.Minute,
+        cacheInvalidateTimeout: time.Second,
+        postCacheTTL:           time.Minute,
+        trendingCacheTTL:       time.Minute,
+        trendingStaleTTL:       time.Hour,
+        trendingWeights:        loadTrendingWeights(),
+        cursors:                cursor.New([]byte("test-secret"), time.Hour),
+        metrics:                testMetrics,
+        maxAggregationResults:  100,
+        maxFeedLimit:           50,
+        maxPostMedia:           10,
+        shareDedupWindow:       time.Hour,
+        friendsCacheTTL:        time.Minute,
+        friendInlineMax:        5000,
+        blocksCacheTTL:         time.Minute,
+        userStatsTTL:           time.Minute,
+        wsMessageRate:          5,
+        wsMessageBurst:         10,
+        wsDedupeWindow:         256,
+        wsSendBuffer:           64,
+        wsOverflowPolicy:       "drop-oldest",
+        wsPubSubBuffer:         100,
+        wsPingInterval:         30 * time.Second,
+        wsPongTimeout:          60 * time.Second,
+    }
+    fs.feedInvalidations = newInvalidationCoalescer(0, time.Second, 4,
+        func(ctx context.Context, userID string) (int64, error) {
+            return fs.cache.DeleteMatching(ctx, userFeedKeyPattern(userID))
+        },
+    )
+    return fs
+}
+
+// serve runs one request through handler registered at route, as userID
+// when it is non-empty, and returns the recorded response.
+func serve(handler gin.HandlerFunc, method, route, target, userID string, body interface{}) *httptest.ResponseRecorder {
+    router := gin.New()
+    router.Handle(method, route, handler)
+
+    var reader *bytes.Reader
+    if body != nil {
+        data, _ := json.Marshal(body)
+        reader = bytes.NewReader(data)
+    } else {
+        reader = bytes.NewReader(nil)
+    }
+    req := httptest.NewRequest(method, target, reader)
+    if body != nil {
+        req.Header.Set("Content-Type", "application/json")
+    }
+    if userID != "" {
+        req.Header.Set("X-User-ID", userID)
+    }
+    rec := httptest.NewRecorder()
+    router.ServeHTTP(rec, req)
+    return rec
+}
+
+// decodeBody unmarshals a recorded JSON response into out.
+func decodeBody(t *testing.T, rec *httptest.ResponseRecorder, out interface{}) {
+    t.Helper()
+    if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+        t.Fatalf("decode %q: %v", rec.Body.String(), err)
+    }
+}
+
+// mockDoc converts v to the bson.D that mtest mock responses carry.
+func mockDoc(t *testing.T, v interface{}) bson.D {
+    t.Helper()
+    data, err := bson.Marshal(v)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var doc bson.D
+    if err := bson.Unmarshal(data, &doc); err != nil {
+        t.Fatal(err)
+    }
+    return doc
+}
+
+// useMiniredis points fs.redis at an in-process Redis for tests that need
+// pub/sub, streams or scripts, and returns the server to inspect.
+func useMiniredis(t *testing.T, fs *FeedService) *miniredis.Miniredis {
+    t.Helper()
+    server := miniredis.RunT(t)
+    fs.redis = redis.NewClient(&redis.Options{Addr: server.Addr()})
+    t.Cleanup(func() { fs.redis.Close() })
+    return server
+}
+
+func TestGetEnvDuration(t *testing.T) {
+    for value, want := range map[string]time.Duration{
+        "":     time.Minute,
+        "90s":  90 * time.Second,
+        "0":    time.Minute,
+        "-5s":  time.Minute,
+        "soon": time.Minute,
+    } {
+        t.Setenv("TEST_DURATION", value)
+        if got := getEnvDuration("TEST_DURATION", time.Minute); got != want {
+            t.Errorf("getEnvDuration(%q) = %s, want %s", value, got, want)
+        }
+    }
+}
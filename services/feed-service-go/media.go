@@ -0,0 +1,71 @@
+package main
+
+import (
+    "log"
+    "net/url"
+    "strings"
+)
+
+// parseCDNBase parses CDN_BASE_URL, returning nil (no rewriting) when it is
+// unset or not an absolute http(s) URL.
+func parseCDNBase(raw string) *url.URL {
+    if raw == "" {
+        return nil
+    }
+    base, err := url.Parse(raw)
+    if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+        log.Printf("⚠️ Ignoring invalid CDN_BASE_URL %q", raw)
+        return nil
+    }
+    base.Path = strings.TrimRight(base.Path, "/")
+    return base
+}
+
+// rewriteMediaURLs points each post's media URLs and thumbnails at the CDN.
+// It runs on the way out, after caching, so cached JSON keeps the storage
+// URLs and changing CDN_BASE_URL takes effect without a cache flush. Media
+// slices are replaced rather than edited because cached and shared feed
+// pages may still reference the originals.
+func (fs *FeedService) rewriteMediaURLs(posts []Post) {
+    if fs.cdnBase == nil {
+        return
+    }
+    for i := range posts {
+        if len(posts[i].Media) == 0 {
+            continue
+        }
+        media := make([]MediaItem, len(posts[i].Media))
+        for j, item := range posts[i].Media {
+            item.URL = fs.cdnURL(item.URL)
+            item.Thumbnail = fs.cdnURL(item.Thumbnail)
+            media[j] = item
+        }
+        posts[i].Media = media
+    }
+}
+
+// cdnURL swaps the origin of a storage URL (or prefixes a root-relative path)
+// for the CDN's. URLs already on the CDN host and non-http schemes are left
+// as they are.
+func (fs *FeedService) cdnURL(raw string) string {
+    if raw == "" {
+        return raw
+    }
+    u, err := url.Parse(raw)
+    if err != nil || u.Host == fs.cdnBase.Host {
+        return raw
+    }
+    if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+        return raw
+    }
+    if u.Host == "" && !strings.HasPrefix(u.Path, "/") {
+        return raw
+    }
+
+    u.Scheme = fs.cdnBase.Scheme
+    u.Host = fs.cdnBase.Host
+    u.User = nil
+    u.Path = fs.cdnBase.Path + u.Path
+    u.RawPath = ""
+    return u.String()
+}
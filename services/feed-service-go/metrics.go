@@ -0,0 +1,117 @@
+package main
+
+import (
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/prometheus/client_golang/prometheus"
+)
+
+// feedMetrics holds the Prometheus collectors exposed on /metrics.
+type feedMetrics struct {
+    aggregationResults *prometheus.HistogramVec
+    wsDroppedFrames    prometheus.Counter
+    wsSlowDisconnects  prometheus.Counter
+    prewarms           *prometheus.CounterVec
+    pubsubDropped      prometheus.Counter
+    counterCorrections *prometheus.CounterVec
+    cacheLookups       *prometheus.CounterVec
+    queryDuration      *prometheus.HistogramVec
+    wsActive           prometheus.Gauge
+    rateLimited        *prometheus.CounterVec
+    cacheDegraded      prometheus.Gauge
+    requestDuration    *prometheus.HistogramVec
+}
+
+func newFeedMetrics() *feedMetrics {
+    m := &feedMetrics{
+        aggregationResults: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Name:    "feed_aggregation_result_size",
+            Help:    "Number of documents returned by aggregation pipelines.",
+            Buckets: []float64{0, 5, 10, 20, 50, 100, 200, 500},
+        }, []string{"pipeline"}),
+        wsDroppedFrames: prometheus.NewCounter(prometheus.CounterOpts{
+            Name: "feed_ws_dropped_frames_total",
+            Help: "Frames dropped because a client's send buffer was full.",
+        }),
+        wsSlowDisconnects: prometheus.NewCounter(prometheus.CounterOpts{
+            Name: "feed_ws_slow_consumer_disconnects_total",
+            Help: "WebSocket clients disconnected for not keeping up.",
+        }),
+        prewarms: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Name: "feed_prewarm_total",
+            Help: "Feed pages rebuilt by the pre-warm job, by result.",
+        }, []string{"result"}),
+        pubsubDropped: prometheus.NewCounter(prometheus.CounterOpts{
+            Name: "feed_pubsub_dropped_total",
+            Help: "Redis pub/sub messages dropped before reaching a connection's send loop.",
+        }),
+        counterCorrections: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Name: "feed_counter_corrections_total",
+            Help: "Total absolute drift repaired by counter reconciliation, by counter.",
+        }, []string{"counter"}),
+        cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Name: "feed_cache_lookups_total",
+            Help: "Cache lookups by endpoint and result (hit or miss).",
+        }, []string{"endpoint", "result"}),
+        queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Name:    "feed_db_query_duration_seconds",
+            Help:    "Latency of the Mongo queries behind the feed and trending endpoints.",
+            Buckets: prometheus.DefBuckets,
+        }, []string{"query"}),
+        wsActive: prometheus.NewGauge(prometheus.GaugeOpts{
+            Name: "feed_ws_active_connections",
+            Help: "Currently open WebSocket connections.",
+        }),
+        rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+            Name: "feed_rate_limited_total",
+            Help: "Requests rejected by the rate limiter, by route.",
+        }, []string{"route"}),
+        cacheDegraded: prometheus.NewGauge(prometheus.GaugeOpts{
+            Name: "feed_cache_degraded",
+            Help: "1 while Redis is unreachable and the cache is bypassed, else 0.",
+        }),
+        requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+            Name:    "feed_http_request_duration_seconds",
+            Help:    "HTTP request latency by method, route and response status.",
+            Buckets: prometheus.DefBuckets,
+        }, []string{"method", "route", "status"}),
+    }
+
+    prometheus.MustRegister(m.aggregationResults, m.wsDroppedFrames, m.wsSlowDisconnects, m.prewarms, m.pubsubDropped,
+        m.counterCorrections, m.cacheLookups, m.queryDuration, m.wsActive, m.rateLimited, m.cacheDegraded,
+        m.requestDuration)
+    return m
+}
+
+// observeCacheLookup counts one cache lookup for endpoint.
+func (m *feedMetrics) observeCacheLookup(endpoint string, hit bool) {
+    result := "miss"
+    if hit {
+        result = "hit"
+    }
+    m.cacheLookups.WithLabelValues(endpoint, result).Inc()
+}
+
+// observeQuery records how long a query that began at start took; use as
+// defer fs.metrics.observeQuery("feed", time.Now()).
+func (m *feedMetrics) observeQuery(query string, start time.Time) {
+    m.queryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// RequestMetrics records each request's latency and final status. Install it
+// before Recovery so a recovered panic is observed as the 500 Recovery writes.
+// Unmatched paths share one route label to keep the series bounded.
+func (m *feedMetrics) RequestMetrics() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        start := time.Now()
+        c.Next()
+        route := c.FullPath()
+        if route == "" {
+            route = "unmatched"
+        }
+        m.requestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+            Observe(time.Since(start).Seconds())
+    }
+}
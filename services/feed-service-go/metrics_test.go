@@ -0,0 +1,48 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+    "github.com/prometheus/client_golang/prometheus"
+    dto "github.com/prometheus/client_model/go"
+)
+
+// requestCount returns how many requests were observed under the labels.
+func requestCount(t *testing.T, m *feedMetrics, method, route, status string) uint64 {
+    t.Helper()
+    var metric dto.Metric
+    observer := m.requestDuration.WithLabelValues(method, route, status)
+    if err := observer.(prometheus.Histogram).Write(&metric); err != nil {
+        t.Fatal(err)
+    }
+    return metric.GetHistogram().GetSampleCount()
+}
+
+func TestRequestMetricsRecordsRecoveredPanic(t *testing.T) {
+    fs := newTestService(t, nil)
+    router := gin.New()
+    router.Use(RequestID(), fs.metrics.RequestMetrics(), Recovery())
+    router.GET("/boom/:id", func(c *gin.Context) { panic("boom") })
+    router.GET("/fine", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+    panics := requestCount(t, fs.metrics, "GET", "/boom/:id", "500")
+    fine := requestCount(t, fs.metrics, "GET", "/fine", "204")
+    unmatched := requestCount(t, fs.metrics, "GET", "unmatched", "404")
+
+    for _, target := range []string{"/boom/1", "/boom/2", "/fine", "/nope"} {
+        router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+    }
+
+    if got := requestCount(t, fs.metrics, "GET", "/boom/:id", "500") - panics; got != 2 {
+        t.Errorf("recorded %d panicking requests as 500, want 2", got)
+    }
+    if got := requestCount(t, fs.metrics, "GET", "/fine", "204") - fine; got != 1 {
+        t.Errorf("recorded %d normal requests, want 1", got)
+    }
+    if got := requestCount(t, fs.metrics, "GET", "unmatched", "404") - unmatched; got != 1 {
+        t.Errorf("recorded %d unmatched requests, want 1", got)
+    }
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+    "crypto/rand"
+    "crypto/subtle"
+    "encoding/hex"
+    "errors"
+    "log"
+    "net/http"
+    "runtime/debug"
+
+    "github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// RequestID propagates the caller's X-Request-ID or assigns a fresh one, so
+// logs and error responses can be correlated.
+func RequestID() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        requestID := c.GetHeader(requestIDHeader)
+        if requestID == "" {
+            buf := make([]byte, 8)
+            rand.Read(buf)
+            requestID = hex.EncodeToString(buf)
+        }
+        c.Set("requestId", requestID)
+        c.Header(requestIDHeader, requestID)
+        c.Next()
+    }
+}
+
+// Recovery turns handler panics into the JSON error envelope instead of Gin's
+// empty 500. The panic is recovered inside this middleware, so anything
+// registered before it (logging, metrics) still observes a normal 500.
+func Recovery() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        defer func() {
+            if err := recover(); err != nil {
+                log.Printf("level=error msg=\"handler panic\" request_id=%s method=%s path=%s error=%q stack=%q",
+                    c.GetString("requestId"), c.Request.Method, c.Request.URL.Path, err, debug.Stack())
+
+                abortWithError(c, http.StatusInternalServerError, ErrCodeInternal, "Internal server error")
+            }
+        }()
+        c.Next()
+    }
+}
+
+// AdminAuth admits requests whose X-Admin-Token matches token. With no token
+// configured the admin endpoints are disabled outright.
+func AdminAuth(token string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !tokenMatches(token, c.GetHeader("X-Admin-Token")) {
+            abortWithError(c, http.StatusForbidden, ErrCodeForbidden, "Admin access required")
+            return
+        }
+        c.Next()
+    }
+}
+
+// InternalAuth admits requests from our own services, identified by an
+// X-Internal-Token matching token. With no token configured the internal
+// endpoints are disabled outright.
+func InternalAuth(token string) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if !tokenMatches(token, c.GetHeader("X-Internal-Token")) {
+            abortWithError(c, http.StatusForbidden, ErrCodeForbidden, "Internal access required")
+            return
+        }
+        c.Next()
+    }
+}
+
+// tokenMatches compares a supplied credential with the configured token in
+// constant time. An unconfigured token matches nothing.
+func tokenMatches(token, supplied string) bool {
+    return token != "" && subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) == 1
+}
+
+// isInternalRequest reports whether c carries the internal service token, for
+// handlers where only some parameters are restricted to our own services.
+func (fs *FeedService) isInternalRequest(c *gin.Context) bool {
+    return tokenMatches(fs.internalToken, c.GetHeader("X-Internal-Token"))
+}
+
+// BodyLimit caps request bodies at maxBytes (MAX_BODY_BYTES); zero or less
+// disables it. A declared Content-Length over the cap is refused before the
+// handler runs; bodies that only turn out too long while being read fail in
+// bindJSON instead. Either way the client gets a 413.
+func BodyLimit(maxBytes int64) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if maxBytes <= 0 || c.Request.Body == nil {
+            c.Next()
+            return
+        }
+        if c.Request.ContentLength > maxBytes {
+            abortWithError(c, http.StatusRequestEntityTooLarge, ErrCodeBodyTooLarge, "Request body too large")
+            return
+        }
+        c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes)
+        c.Next()
+    }
+}
+
+// bindJSON decodes the request body into dst, writing a 413 for a body over
+// BodyLimit's cap and a 400 for anything else that fails to bind.
+func bindJSON(c *gin.Context, dst interface{}) bool {
+    err := c.ShouldBindJSON(dst)
+    if err == nil {
+        return true
+    }
+    var tooLarge *http.MaxBytesError
+    if errors.As(err, &tooLarge) {
+        respondError(c, http.StatusRequestEntityTooLarge, ErrCodeBodyTooLarge, "Request body too large")
+        return false
+    }
+    respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid request")
+    return false
+}
+
+// PrettyJSON lets callers ask for indented responses with ?pretty=true or an
+// X-Pretty-JSON: true header. It is installed only when pretty output is
+// allowed (outside release mode by default).
+func PrettyJSON() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if c.Query("pretty") == "true" || c.GetHeader("X-Pretty-JSON") == "true" {
+            c.Set("prettyJSON", true)
+        }
+        c.Next()
+    }
+}
+
+// respondJSON writes a JSON response, indented when the request opted in.
+func respondJSON(c *gin.Context, status int, body interface{}) {
+    if c.GetBool("prettyJSON") {
+        c.IndentedJSON(status, body)
+        return
+    }
+    c.JSON(status, body)
+}
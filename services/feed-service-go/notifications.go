@@ -0,0 +1,74 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "github.com/go-redis/redis/v8"
+)
+
+func unreadCountKey(userID string) string {
+    return fmt.Sprintf("notifications:unread:%s", userID)
+}
+
+// requestUserID returns the caller's user ID, taken from the X-User-ID header
+// set by the gateway and falling back to the userId query parameter.
+func requestUserID(c *gin.Context) string {
+    if userID := c.GetHeader("X-User-ID"); userID != "" {
+        return userID
+    }
+    return c.Query("userId")
+}
+
+// incrementUnread bumps a user's unread notification counter. Producers of
+// notification events (mentions, reactions, comments) call this alongside
+// publishing so the badge count stays O(1) to read.
+func (fs *FeedService) incrementUnread(ctx context.Context, userID string) error {
+    return fs.redis.Incr(ctx, unreadCountKey(userID)).Err()
+}
+
+func (fs *FeedService) GetUnreadCount(c *gin.Context) {
+    userID := requestUserID(c)
+    if userID == "" {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    count, err := fs.redis.Get(ctx, unreadCountKey(userID)).Int64()
+    if err != nil && err != redis.Nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch unread count")
+        return
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":     true,
+        "unreadCount": count,
+    })
+}
+
+// MarkNotificationsRead advances the read mark by resetting the counter.
+func (fs *FeedService) MarkNotificationsRead(c *gin.Context) {
+    userID := requestUserID(c)
+    if userID == "" {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    if err := fs.redis.Del(ctx, unreadCountKey(userID)).Err(); err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to mark notifications read")
+        return
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":     true,
+        "unreadCount": 0,
+    })
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+    "net/http"
+    "reflect"
+    "strconv"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// buildOpenAPI generates an OpenAPI 3 document from the route table,
+// reflecting request/response types into component schemas via their json tags.
+func buildOpenAPI(basePath string, routes []routeSpec) gin.H {
+    schemas := gin.H{}
+    paths := gin.H{}
+
+    for _, route := range routes {
+        path, pathParams := openAPIPath(basePath + route.Path)
+
+        var params []gin.H
+        for _, name := range pathParams {
+            params = append(params, gin.H{
+                "name": name, "in": "path", "required": true,
+                "schema": gin.H{"type": "string"},
+            })
+        }
+        for _, q := range route.Query {
+            param := gin.H{
+                "name": q.Name, "in": "query", "required": q.Required,
+                "schema": gin.H{"type": q.Type},
+            }
+            if q.Description != "" {
+                param["description"] = q.Description
+            }
+            params = append(params, param)
+        }
+
+        successSchema := gin.H{"type": "object"}
+        if route.Response != nil {
+            successSchema = schemaFor(reflect.TypeOf(route.Response), schemas)
+        }
+        responses := gin.H{
+            "200": gin.H{
+                "description": "Success",
+                "content":     gin.H{"application/json": gin.H{"schema": successSchema}},
+            },
+        }
+        for _, status := range route.Statuses {
+            responses[strconv.Itoa(status)] = errorResponseSpec(status, schemas)
+        }
+
+        op := gin.H{
+            "summary":     route.Summary,
+            "operationId": operationID(route),
+            "responses":   responses,
+        }
+        if len(params) > 0 {
+            op["parameters"] = params
+        }
+        if route.Body != nil {
+            // BodyLimit applies to every route, but only these read a body
+            responses[strconv.Itoa(http.StatusRequestEntityTooLarge)] = errorResponseSpec(http.StatusRequestEntityTooLarge, schemas)
+            op["requestBody"] = gin.H{
+                "required": true,
+                "content": gin.H{"application/json": gin.H{
+                    "schema": schemaFor(reflect.TypeOf(route.Body), schemas),
+                }},
+            }
+        }
+        if route.Auth {
+            op["security"] = []gin.H{{"gatewayUser": []string{}}}
+        }
+        if route.Admin {
+            op["security"] = []gin.H{{"adminToken": []string{}}}
+        }
+        if route.Internal {
+            op["security"] = []gin.H{{"internalToken": []string{}}}
+        }
+
+        item, _ := paths[path].(gin.H)
+        if item == nil {
+            item = gin.H{}
+            paths[path] = item
+        }
+        item[strings.ToLower(route.Method)] = op
+    }
+
+    return gin.H{
+        "openapi": "3.0.3",
+        "info": gin.H{
+            "title":   "Crown Feed Service",
+            "version": "1.0.0",
+        },
+        "paths": paths,
+        "components": gin.H{
+            "schemas": schemas,
+            "securitySchemes": gin.H{
+                "gatewayUser": gin.H{"type": "apiKey", "in": "header", "name": "X-User-ID"},
+                "adminToken":  gin.H{"type": "apiKey", "in": "header", "name": "X-Admin-Token"},
+                "internalToken": gin.H{"type": "apiKey", "in": "header", "name": "X-Internal-Token"},
+            },
+        },
+    }
+}
+
+// OpenAPIHandler serves the generated document; it is built once at startup.
+func OpenAPIHandler(basePath string, routes []routeSpec) gin.HandlerFunc {
+    spec := buildOpenAPI(basePath, routes)
+    return func(c *gin.Context) {
+        respondJSON(c, http.StatusOK, spec)
+    }
+}
+
+// errorResponseSpec documents a non-success status. Error statuses carry an
+// ErrorResponse body; the WebSocket's 101 has none.
+func errorResponseSpec(status int, schemas gin.H) gin.H {
+    response := gin.H{"description": http.StatusText(status)}
+    if status >= http.StatusBadRequest {
+        response["content"] = gin.H{"application/json": gin.H{
+            "schema": schemaFor(reflect.TypeOf(ErrorResponse{}), schemas),
+        }}
+    }
+    return response
+}
+
+// openAPIPath converts Gin's :param segments to {param} and lists them.
+func openAPIPath(path string) (string, []string) {
+    var params []string
+    segments := strings.Split(path, "/")
+    for i, segment := range segments {
+        if strings.HasPrefix(segment, ":") {
+            params = append(params, segment[1:])
+            segments[i] = "{" + segment[1:] + "}"
+        }
+    }
+    return strings.Join(segments, "/"), params
+}
+
+func operationID(route routeSpec) string {
+    id := strings.ToLower(route.Method)
+    for _, segment := range strings.Split(route.Path, "/") {
+        segment = strings.TrimPrefix(segment, ":")
+        for _, word := range strings.Split(segment, "-") {
+            if word != "" {
+                id += strings.ToUpper(word[:1]) + word[1:]
+            }
+        }
+    }
+    return id
+}
+
+var (
+    timeType     = reflect.TypeOf(time.Time{})
+    objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// schemaFor returns the schema for t, registering named structs as components.
+func schemaFor(t reflect.Type, schemas gin.H) gin.H {
+    switch t {
+    case timeType:
+        return gin.H{"type": "string", "format": "date-time"}
+    case objectIDType:
+        return gin.H{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+    }
+
+    switch t.Kind() {
+    case reflect.Ptr:
+        return schemaFor(t.Elem(), schemas)
+    case reflect.Bool:
+        return gin.H{"type": "boolean"}
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        return gin.H{"type": "integer"}
+    case reflect.Float32, reflect.Float64:
+        return gin.H{"type": "number"}
+    case reflect.String:
+        return gin.H{"type": "string"}
+    case reflect.Slice, reflect.Array:
+        return gin.H{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+    case reflect.Map:
+        return gin.H{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+    case reflect.Struct:
+        if t.Name() == "" {
+            return structSchema(t, schemas)
+        }
+        if _, done := schemas[t.Name()]; !done {
+            schemas[t.Name()] = gin.H{} // placeholder guards recursive types
+            schemas[t.Name()] = structSchema(t, schemas)
+        }
+        return gin.H{"$ref": "#/components/schemas/" + t.Name()}
+    default:
+        return gin.H{}
+    }
+}
+
+func structSchema(t reflect.Type, schemas gin.H) gin.H {
+    properties := gin.H{}
+    for i := 0; i < t.NumField(); i++ {
+        field := t.Field(i)
+        if !field.IsExported() {
+            continue
+        }
+        name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+        if name == "-" {
+            continue
+        }
+        if name == "" {
+            name = field.Name
+        }
+        properties[name] = schemaFor(field.Type, schemas)
+    }
+    return gin.H{"type": "object", "properties": properties}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+    "encoding/json"
+    "regexp"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+)
+
+var openAPIParamTypes = map[string]bool{"string": true, "integer": true, "number": true, "boolean": true, "array": true}
+
+// collectRefs gathers every $ref under v.
+func collectRefs(v interface{}, refs map[string]bool) {
+    switch v := v.(type) {
+    case map[string]interface{}:
+        for key, value := range v {
+            if ref, ok := value.(string); ok && key == "$ref" {
+                refs[ref] = true
+            }
+            collectRefs(value, refs)
+        }
+    case []interface{}:
+        for _, value := range v {
+            collectRefs(value, refs)
+        }
+    }
+}
+
+func TestOpenAPICoversRegisteredRoutes(t *testing.T) {
+    fs := newTestService(t, nil)
+    routes := fs.routes()
+
+    router := gin.New()
+    pass := func(c *gin.Context) { c.Next() }
+    registerRoutes(router.Group("/api/v1"), routes, routeMiddleware{Admin: pass, Internal: pass, RateLimit: pass})
+
+    data, err := json.Marshal(buildOpenAPI("/api/v1", routes))
+    if err != nil {
+        t.Fatalf("spec does not serialize: %v", err)
+    }
+    var spec struct {
+        OpenAPI    string                                       `json:"openapi"`
+        Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+        Components struct {
+            Schemas map[string]interface{} `json:"schemas"`
+        } `json:"components"`
+    }
+    if err := json.Unmarshal(data, &spec); err != nil {
+        t.Fatal(err)
+    }
+    if !strings.HasPrefix(spec.OpenAPI, "3.") {
+        t.Fatalf("openapi version %q", spec.OpenAPI)
+    }
+
+    registered := router.Routes()
+    if len(registered) != len(routes) {
+        t.Fatalf("%d routes registered from %d specs", len(registered), len(routes))
+    }
+    operationIDs := map[string]string{}
+    statusPattern := regexp.MustCompile(`^[1-5][0-9]{2}$`)
+    for _, route := range registered {
+        path, pathParams := openAPIPath(route.Path)
+        name := route.Method + " " + route.Path
+        op, ok := spec.Paths[path][strings.ToLower(route.Method)]
+        if !ok {
+            t.Errorf("%s is registered but missing from the spec", name)
+            continue
+        }
+
+        if summary, _ := op["summary"].(string); summary == "" {
+            t.Errorf("%s has no summary", name)
+        }
+        id, _ := op["operationId"].(string)
+        if other, dup := operationIDs[id]; dup || id == "" {
+            t.Errorf("%s: operationId %q empty or shared with %s", name, id, other)
+        }
+        operationIDs[id] = name
+
+        responses, _ := op["responses"].(map[string]interface{})
+        if _, ok := responses["200"]; !ok {
+            t.Errorf("%s documents no 200 response", name)
+        }
+        for status, response := range responses {
+            description, _ := response.(map[string]interface{})["description"].(string)
+            if !statusPattern.MatchString(status) || description == "" {
+                t.Errorf("%s: response %q is not a described status code", name, status)
+            }
+        }
+
+        seen := map[string]bool{}
+        declaredPath := map[string]bool{}
+        params, _ := op["parameters"].([]interface{})
+        for _, raw := range params {
+            param := raw.(map[string]interface{})
+            pname, _ := param["name"].(string)
+            in, _ := param["in"].(string)
+            ptype, _ := param["schema"].(map[string]interface{})["type"].(string)
+            if pname == "" || (in != "path" && in != "query") || !openAPIParamTypes[ptype] {
+                t.Errorf("%s: invalid parameter %v", name, param)
+            }
+            if seen[in+":"+pname] {
+                t.Errorf("%s: parameter %s declared twice", name, pname)
+            }
+            seen[in+":"+pname] = true
+            if in == "path" {
+                if required, _ := param["required"].(bool); !required {
+                    t.Errorf("%s: path parameter %s must be required", name, pname)
+                }
+                declaredPath[pname] = true
+            }
+        }
+        for _, pname := range pathParams {
+            if !declaredPath[pname] {
+                t.Errorf("%s: path parameter %s undeclared", name, pname)
+            }
+        }
+        if len(declaredPath) != len(pathParams) {
+            t.Errorf("%s declares path parameters not in its path", name)
+        }
+    }
+
+    refs := map[string]bool{}
+    collectRefs(spec.Paths, refs)
+    collectRefs(spec.Components.Schemas, refs)
+    for ref := range refs {
+        schema := strings.TrimPrefix(ref, "#/components/schemas/")
+        if _, ok := spec.Components.Schemas[schema]; !ok || schema == ref {
+            t.Errorf("$ref %s does not resolve", ref)
+        }
+    }
+}
+
+func TestOpenAPIPath(t *testing.T) {
+    path, params := openAPIPath("/api/v1/posts/:id/comments/:commentId")
+    if path != "/api/v1/posts/{id}/comments/{commentId}" || strings.Join(params, ",") != "id,commentId" {
+        t.Fatalf("openAPIPath = %q, %v", path, params)
+    }
+}
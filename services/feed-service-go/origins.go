@@ -0,0 +1,52 @@
+package main
+
+import (
+    "log"
+    "net/http"
+    "net/url"
+    "strings"
+)
+
+// originAllowlist holds WS_ALLOWED_ORIGINS entries, each a scheme and host
+// such as "https://crown.social" or, for any subdomain, "https://*.crown.social".
+// An empty list allows every origin, which is only meant for development.
+type originAllowlist []string
+
+func loadOriginAllowlist() originAllowlist {
+    origins := originAllowlist(getEnvList("WS_ALLOWED_ORIGINS"))
+    for i, origin := range origins {
+        origins[i] = strings.ToLower(strings.TrimRight(origin, "/"))
+    }
+    if len(origins) == 0 {
+        log.Printf("⚠️ WS_ALLOWED_ORIGINS is empty, accepting WebSocket upgrades from any origin")
+    }
+    return origins
+}
+
+// Allows reports whether a request may be upgraded. Requests without an
+// Origin header come from non-browser clients, which cross-site hijacking
+// cannot drive, and are allowed.
+func (a originAllowlist) Allows(r *http.Request) bool {
+    origin := r.Header.Get("Origin")
+    if len(a) == 0 || origin == "" {
+        return true
+    }
+    u, err := url.Parse(strings.ToLower(origin))
+    if err != nil || u.Host == "" {
+        return false
+    }
+
+    for _, allowed := range a {
+        scheme, host, ok := strings.Cut(allowed, "://")
+        if !ok || scheme != u.Scheme {
+            continue
+        }
+        if host == u.Host {
+            return true
+        }
+        if suffix, wildcard := strings.CutPrefix(host, "*."); wildcard && strings.HasSuffix(u.Host, "."+suffix) {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "sort"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "crown-feed-service/cursor"
+)
+
+// parsePageQuery reads the page and limit query parameters of GET endpoints
+// into req, leaving zero for applyPageDefaults when they are absent.
+func parsePageQuery(c *gin.Context, req *FeedRequest) bool {
+    var err error
+    if req.Page, err = strconv.Atoi(c.DefaultQuery("page", "0")); err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "page must be an integer")
+        return false
+    }
+    if req.Limit, err = strconv.Atoi(c.DefaultQuery("limit", "0")); err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "limit must be an integer")
+        return false
+    }
+    return true
+}
+
+// applyPageDefaults fills in page 1 / limit 10 and clamps the limit to
+// MAX_FEED_LIMIT, responding 400 and returning false for negative values.
+func (fs *FeedService) applyPageDefaults(c *gin.Context, req *FeedRequest) bool {
+    if req.Page < 0 || req.Limit < 0 {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "page and limit must not be negative")
+        return false
+    }
+    if req.Page == 0 {
+        req.Page = 1
+    }
+    if req.Limit == 0 {
+        req.Limit = 10
+    }
+    if req.Limit > fs.maxFeedLimit {
+        req.Limit = fs.maxFeedLimit
+    }
+    return true
+}
+
+// applySeenIDs validates req.SeenIDs into the sorted, deduplicated req.Seen,
+// responding 400 and returning false for a malformed ID or a list longer than
+// MAX_SEEN_IDS.
+func (fs *FeedService) applySeenIDs(c *gin.Context, req *FeedRequest) bool {
+    if len(req.SeenIDs) == 0 {
+        return true
+    }
+    if len(req.SeenIDs) > fs.maxSeenIDs {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("At most %d seenIds", fs.maxSeenIDs))
+        return false
+    }
+
+    seen := make(map[primitive.ObjectID]bool, len(req.SeenIDs))
+    req.Seen = make([]primitive.ObjectID, 0, len(req.SeenIDs))
+    for _, id := range req.SeenIDs {
+        oid, err := primitive.ObjectIDFromHex(id)
+        if err != nil {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("invalid seen id %q", id))
+            return false
+        }
+        if !seen[oid] {
+            seen[oid] = true
+            req.Seen = append(req.Seen, oid)
+        }
+    }
+    sort.Slice(req.Seen, func(i, j int) bool { return req.Seen[i].Hex() < req.Seen[j].Hex() })
+    return true
+}
+
+// applyFeedCursor decodes req.Cursor into req.After, responding 400 and
+// returning false when the token is forged, malformed or expired.
+func (fs *FeedService) applyFeedCursor(c *gin.Context, req *FeedRequest) bool {
+    if req.Cursor == "" {
+        return true
+    }
+    fields, err := fs.cursors.Decode(req.Cursor)
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid or expired cursor")
+        return false
+    }
+    req.After = &fields
+    return true
+}
+
+// afterFilter selects posts strictly older than the cursor position in
+// (createdAt desc, _id desc) order; _id breaks ties between posts created in
+// the same instant.
+func afterFilter(after cursor.Fields) bson.M {
+    return bson.M{"$or": []bson.M{
+        {"createdAt": bson.M{"$lt": after.CreatedAt}},
+        {"createdAt": after.CreatedAt, "_id": bson.M{"$lt": after.ID}},
+    }}
+}
+
+// isOrganic reports whether a post came from the feed query itself rather
+// than backfill, exploration, promotion or pin hoisting.
+func isOrganic(post Post) bool {
+    return !post.Backfilled && post.Reason == ""
+}
+
+// feedPage is a page of posts together with whether the query had more.
+// It is what feed and tag pages cache, so a cache hit can answer hasMore
+// without guessing from the page size. Feed pages also carry their ETag, so
+// a conditional request is answered from the cached entry alone.
+type feedPage struct {
+    Posts   []Post `json:"posts"`
+    HasMore bool   `json:"hasMore"`
+    ETag    string `json:"etag,omitempty"`
+}
+
+// trimPage takes the rows of a query run with limit+1 and keeps the first
+// limit; the extra row, if present, only establishes that there is more.
+func trimPage(posts []Post, limit int) feedPage {
+    if posts == nil {
+        posts = []Post{}
+    }
+    if len(posts) > limit {
+        return feedPage{Posts: posts[:limit], HasMore: true}
+    }
+    return feedPage{Posts: posts}
+}
+
+// nextFeedCursor points after the oldest organic post on the page. The minimum
+// is used rather than the last element because ranking may reorder a page,
+// and injected posts are ignored because they sit outside the feed's order.
+func (fs *FeedService) nextFeedCursor(posts []Post) string {
+    var oldest *Post
+    for i := range posts {
+        post := &posts[i]
+        if !isOrganic(*post) {
+            continue
+        }
+        if oldest == nil || post.CreatedAt.Before(oldest.CreatedAt) ||
+            (post.CreatedAt.Equal(oldest.CreatedAt) && post.ID.Hex() < oldest.ID.Hex()) {
+            oldest = post
+        }
+    }
+    if oldest == nil {
+        return ""
+    }
+    return fs.cursors.Encode(cursor.Fields{CreatedAt: oldest.CreatedAt, ID: oldest.ID})
+}
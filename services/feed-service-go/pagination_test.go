@@ -0,0 +1,55 @@
+package main
+
+import (
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNextFeedCursorIgnoresHoistedPin(t *testing.T) {
+    fs := newTestService(t, nil)
+    viewer := primitive.NewObjectID()
+    base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+    // A full page of recent posts, newest first, and a pin a month older
+    // than all of them
+    page := make([]Post, 10)
+    for i := range page {
+        page[i] = Post{ID: primitive.NewObjectID(), Author: primitive.NewObjectID(), CreatedAt: base.Add(-time.Duration(i) * time.Hour)}
+    }
+    pin := &Post{ID: primitive.NewObjectID(), Author: viewer, Pinned: true, CreatedAt: base.AddDate(0, -1, 0)}
+
+    posts := hoistPinned(page, pin)
+    if posts[0].ID != pin.ID || posts[0].Reason != ReasonPinned {
+        t.Fatalf("pin not hoisted and tagged: %+v", posts[0])
+    }
+    if again, rest := splitPinned(posts, viewer.Hex()); again == nil || len(rest) != len(page) {
+        t.Fatal("hoisted pin not split back off")
+    }
+
+    fields, err := fs.cursors.Decode(fs.nextFeedCursor(posts))
+    if err != nil {
+        t.Fatal(err)
+    }
+    oldest := page[len(page)-1]
+    if fields.ID != oldest.ID || !fields.CreatedAt.Equal(oldest.CreatedAt) {
+        t.Fatalf("cursor at %s %v, want the oldest organic post %s %v", fields.ID.Hex(), fields.CreatedAt, oldest.ID.Hex(), oldest.CreatedAt)
+    }
+}
+
+func TestNextFeedCursorKeepsOthersPinnedPosts(t *testing.T) {
+    fs := newTestService(t, nil)
+    base := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+    newer := Post{ID: primitive.NewObjectID(), CreatedAt: base}
+    // Another author's pin reached the page through the feed query itself
+    theirPin := Post{ID: primitive.NewObjectID(), Pinned: true, CreatedAt: base.Add(-time.Hour)}
+
+    fields, err := fs.cursors.Decode(fs.nextFeedCursor([]Post{newer, theirPin}))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if fields.ID != theirPin.ID {
+        t.Fatalf("cursor at %s, want the organic pinned post %s", fields.ID.Hex(), theirPin.ID.Hex())
+    }
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+    "context"
+    "log"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReasonPinned annotates the viewer's pinned post hoisted onto their first
+// page. It sits outside the feed's order, so it must not set the cursor.
+const ReasonPinned = "pinned"
+
+// pinnedPostIndex allows one pinned post per author, so two pins racing each
+// other cannot both land.
+var pinnedPostIndex = mongo.IndexModel{
+    Keys: bson.D{{Key: "author", Value: 1}},
+    Options: options.Index().SetName("posts_author_pinned").SetUnique(true).
+        SetPartialFilterExpression(bson.M{"pinned": true}),
+}
+
+// pinnedPost returns the user's live pinned post, or nil if they have none.
+func (fs *FeedService) pinnedPost(ctx context.Context, userID primitive.ObjectID) (*Post, error) {
+    var post Post
+    err := fs.mongo.Database("crown-social").Collection("posts").
+        FindOne(ctx, bson.M{"author": userID, "pinned": true, "isActive": true, "deletedAt": nil}).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    return &post, nil
+}
+
+// splitPinned takes the viewer's pinned post off the front of a page that
+// fetchFeedFromDB hoisted it onto, so ranking cannot move it.
+func splitPinned(posts []Post, userID string) (*Post, []Post) {
+    if len(posts) > 0 && posts[0].Pinned && posts[0].Author.Hex() == userID {
+        pinned := posts[0]
+        return &pinned, posts[1:]
+    }
+    return nil, posts
+}
+
+// hoistPinned puts pinned first, tagged ReasonPinned, dropping any copy
+// injection brought back in.
+func hoistPinned(posts []Post, pinned *Post) []Post {
+    if pinned == nil {
+        return posts
+    }
+    lead := *pinned
+    lead.Reason = ReasonPinned
+    hoisted := make([]Post, 0, len(posts)+1)
+    hoisted = append(hoisted, lead)
+    for _, post := range posts {
+        if post.ID != pinned.ID {
+            hoisted = append(hoisted, post)
+        }
+    }
+    return hoisted
+}
+
+// PinPost pins one of the caller's posts to the top of their own feed. A user
+// has at most one pinned post, so pinning another replaces it.
+func (fs *FeedService) PinPost(c *gin.Context) {
+    authorID, postID, ok := parsePinParams(c)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    var post Post
+    err := fs.mongo.Database("crown-social").Collection("posts").
+        FindOne(ctx, bson.M{"_id": postID, "isActive": true, "deletedAt": nil}).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+        return
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to pin post")
+        return
+    }
+    if post.Author != authorID {
+        respondError(c, http.StatusForbidden, ErrCodeForbidden, "Only the author can pin this post")
+        return
+    }
+
+    response := gin.H{"success": true, "postId": postID.Hex(), "pinned": true}
+    if !post.Pinned {
+        var previous Post
+        err = fs.postsWriteCollection().FindOneAndUpdate(ctx,
+            bson.M{"author": authorID, "pinned": true},
+            bson.M{"$unset": bson.M{"pinned": ""}},
+        ).Decode(&previous)
+        if err != nil && err != mongo.ErrNoDocuments {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to pin post")
+            return
+        }
+        hadPin := err == nil
+
+        _, err = fs.postsWriteCollection().UpdateOne(ctx,
+            bson.M{"_id": postID, "author": authorID},
+            bson.M{"$set": bson.M{"pinned": true}},
+        )
+        if err != nil {
+            // Put the old pin back rather than leave the user with none
+            if hadPin {
+                fs.restorePin(previous.ID)
+            }
+            if mongo.IsDuplicateKeyError(err) {
+                respondError(c, http.StatusConflict, ErrCodeConflict, "Another post was pinned at the same time")
+                return
+            }
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to pin post")
+            return
+        }
+        if hadPin {
+            fs.invalidatePost(ctx, previous.ID)
+            response["unpinnedPostId"] = previous.ID.Hex()
+        }
+        fs.pinsChanged(ctx, authorID, postID)
+    }
+
+    respondJSON(c, http.StatusOK, response)
+}
+
+func (fs *FeedService) UnpinPost(c *gin.Context) {
+    authorID, postID, ok := parsePinParams(c)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    result, err := fs.postsWriteCollection().UpdateOne(ctx,
+        bson.M{"_id": postID, "author": authorID, "pinned": true},
+        bson.M{"$unset": bson.M{"pinned": ""}},
+    )
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to unpin post")
+        return
+    }
+    if result.MatchedCount == 0 {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not pinned")
+        return
+    }
+    fs.pinsChanged(ctx, authorID, postID)
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "postId":  postID.Hex(),
+        "pinned":  false,
+    })
+}
+
+// restorePin re-pins a post PinPost unpinned before failing to pin its
+// replacement. It runs on its own deadline, since the request's may be what
+// expired. If a concurrent pin took the slot meanwhile the unique index
+// rejects the restore, and that pin stands.
+func (fs *FeedService) restorePin(postID primitive.ObjectID) {
+    ctx, cancel := fs.opContext(context.Background())
+    defer cancel()
+    _, err := fs.postsWriteCollection().UpdateOne(ctx,
+        bson.M{"_id": postID, "isActive": true, "deletedAt": nil},
+        bson.M{"$set": bson.M{"pinned": true}},
+    )
+    if err != nil && !mongo.IsDuplicateKeyError(err) {
+        log.Printf("Failed to restore pin on post %s: %v", postID.Hex(), err)
+    }
+}
+
+func parsePinParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+    authorID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return authorID, primitive.NilObjectID, false
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return authorID, postID, false
+    }
+    return authorID, postID, true
+}
+
+// pinsChanged drops the author's feed pages, whose first post may change, and
+// the post's cached copy. Other viewers' feeds keep the old flag until their
+// pages expire; the pin only reorders the author's own feed.
+func (fs *FeedService) pinsChanged(ctx context.Context, authorID, postID primitive.ObjectID) {
+    fs.invalidateUserFeeds(ctx, authorID.Hex())
+    fs.invalidatePost(ctx, postID)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestPinPostRestoresPreviousPin(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    author := primitive.NewObjectID()
+    created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+    for name, failure := range map[string]bson.D{
+        "pin race":    mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key"}),
+        "write error": mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutting down"}),
+    } {
+        mt.Run(name, func(mt *mtest.T) {
+            fs := newTestService(t, mt.Client)
+            post := editablePost(author, created)
+            previous := editablePost(author, created.Add(-time.Hour))
+            previous.Pinned = true
+
+            mt.AddMockResponses(
+                mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, post)),
+                mtest.CreateSuccessResponse(bson.E{Key: "value", Value: mockDoc(t, previous)}),
+                failure,
+                mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+            )
+            rec := serve(fs.PinPost, http.MethodPost, "/posts/:id/pin", "/posts/"+post.ID.Hex()+"/pin", author.Hex(), nil)
+            if rec.Code < 400 {
+                t.Fatalf("status = %d, want a failure: %s", rec.Code, rec.Body)
+            }
+
+            var updates []bson.Raw
+            for _, event := range mt.GetAllStartedEvents() {
+                if event.CommandName == "update" {
+                    updates = append(updates, event.Command.Lookup("updates").Array().Index(0).Value().Document())
+                }
+            }
+            if len(updates) != 2 {
+                t.Fatalf("%d updates sent, want the pin and the restore", len(updates))
+            }
+            restored := updates[1].Lookup("q", "_id").ObjectID()
+            pinned := updates[1].Lookup("u", "$set", "pinned").Boolean()
+            if restored != previous.ID || !pinned {
+                t.Fatalf("restore targeted %s (pinned %v), want %s", restored.Hex(), pinned, previous.ID.Hex())
+            }
+        })
+    }
+}
+
+func TestBuildFeedPinOnlyPageIsNotEmpty(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("pin with no other posts", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        fs.emptyFeedTTL = time.Hour
+        user := primitive.NewObjectID()
+        pin := editablePost(user, time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+        pin.Pinned = true
+
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "crown-social.blocks", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.close_friends", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, pin)),
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch),
+        )
+        req := FeedRequest{UserID: user.Hex(), Page: 1, Limit: 10}
+        page, err := fs.buildFeed(context.Background(), req)
+        if err != nil {
+            mt.Fatal(err)
+        }
+        if len(page.Posts) != 1 || page.Posts[0].ID != pin.ID {
+            mt.Fatalf("page = %+v, want just the pin", page.Posts)
+        }
+        if _, err := fs.cache.Get(context.Background(), emptyFeedMarkerPrefix+feedCacheKey(req)); err != ErrCacheMiss {
+            mt.Fatal("a page holding the pin was cached as an empty feed")
+        }
+    })
+}
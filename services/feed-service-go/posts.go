@@ -0,0 +1,678 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+    "go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+// parseWriteConcern turns MONGO_WRITE_CONCERN ("majority" or a numeric w) and
+// the journal flag into a write concern.
+//
+// "majority" waits until most replica set members have the write, so an
+// acknowledged post survives a primary failover, at the cost of replication
+// latency on every write. w=1 acknowledges as soon as the primary applies it:
+// faster, but a failover can roll back recent posts. Journaling additionally
+// waits for the on-disk journal.
+func parseWriteConcern(spec string, journal bool) (*writeconcern.WriteConcern, error) {
+    wc := &writeconcern.WriteConcern{Journal: &journal}
+    switch spec {
+    case "", "majority":
+        wc.W = "majority"
+    default:
+        w, err := strconv.Atoi(spec)
+        if err != nil || w < 0 {
+            return nil, fmt.Errorf("invalid MONGO_WRITE_CONCERN %q", spec)
+        }
+        wc.W = w
+    }
+    return wc, nil
+}
+
+// postsWriteCollection returns the posts collection configured with the write
+// concern every post mutation (create, edit, delete, moderation) must use.
+func (fs *FeedService) postsWriteCollection() *mongo.Collection {
+    return fs.mongo.Database("crown-social").Collection("posts",
+        options.Collection().SetWriteConcern(fs.postsWriteConcern))
+}
+
+const maxPostLength = 5000
+
+// Post types accepted on create, matching the main app's Post model.
+var postTypes = []string{"text", "image", "video", "link", "poll"}
+
+// Media types accepted on create.
+var mediaTypes = []string{"image", "video", "gif"}
+
+// validateMedia checks each attachment has an allowed type, an absolute
+// http(s) URL and, for videos, a thumbnail. The error names the first failing
+// index so the client can point at the attachment.
+func validateMedia(media []MediaItem) error {
+    for i, item := range media {
+        if !containsString(mediaTypes, item.Type) {
+            return fmt.Errorf("media[%d]: type must be one of %s", i, strings.Join(mediaTypes, ", "))
+        }
+        if !isHTTPURL(item.URL) {
+            return fmt.Errorf("media[%d]: url must be an absolute http(s) URL", i)
+        }
+        if item.Type == "video" && item.Thumbnail == "" {
+            return fmt.Errorf("media[%d]: video requires a thumbnail", i)
+        }
+        if item.Thumbnail != "" && !isHTTPURL(item.Thumbnail) {
+            return fmt.Errorf("media[%d]: thumbnail must be an absolute http(s) URL", i)
+        }
+    }
+    return nil
+}
+
+// validatePostBody applies the checks shared by create and edit to a post's
+// content and attachments.
+func (fs *FeedService) validatePostBody(content string, media []MediaItem) error {
+    // Counted in runes, so the limit means the same for every script
+    length := utf8.RuneCountInString(strings.TrimSpace(content))
+    if length == 0 {
+        return fmt.Errorf("Content is required")
+    }
+    if length > maxPostLength {
+        return fmt.Errorf("Content is %d characters; the limit is %d", length, maxPostLength)
+    }
+    if len(media) > fs.maxPostMedia {
+        return fmt.Errorf("At most %d media items", fs.maxPostMedia)
+    }
+    return validateMedia(media)
+}
+
+// normalizeTags normalizes and dedupes client-supplied tags, dropping empties.
+func normalizeTags(raw []string) []string {
+    tags := make([]string, 0, len(raw))
+    for _, tag := range raw {
+        if tag = normalizeTag(tag); tag != "" && !containsString(tags, tag) {
+            tags = append(tags, tag)
+        }
+    }
+    return tags
+}
+
+func isHTTPURL(raw string) bool {
+    u, err := url.Parse(raw)
+    return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+type CreatePostRequest struct {
+    Content       string      `json:"content"`
+    ContentFormat string      `json:"contentFormat,omitempty"`
+    Type          string      `json:"type,omitempty"`
+    Visibility    string      `json:"visibility,omitempty"`
+    Media         []MediaItem `json:"media,omitempty"`
+    Tags          []string    `json:"tags,omitempty"`
+}
+
+// CreatePost inserts a post for the caller, invalidates the feeds it now
+// appears in and pushes it to the recipients' live feeds.
+func (fs *FeedService) CreatePost(c *gin.Context) {
+    authorID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+
+    var req CreatePostRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    post, ok := fs.draftPost(c, authorID, req)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    if fs.isDuplicateContent(ctx, authorID.Hex(), post.Type, post.Content) {
+        respondError(c, http.StatusConflict, ErrCodeConflict, "You already posted this recently")
+        return
+    }
+
+    verified, err := fs.authorVerified(ctx, authorID)
+    if err != nil {
+        fs.forgetContent(ctx, authorID.Hex(), post.Content)
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to create post")
+        return
+    }
+
+    now := fs.now()
+    post.ID = primitive.NewObjectID()
+    post.AuthorVerified = verified
+    post.CreatedAt, post.UpdatedAt = now, now
+    if _, err := fs.postsWriteCollection().InsertOne(ctx, post); err != nil {
+        // Let the client retry the same content
+        fs.forgetContent(ctx, authorID.Hex(), post.Content)
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to create post")
+        return
+    }
+    fs.announcePost(ctx, post)
+
+    respondJSON(c, http.StatusCreated, gin.H{
+        "success": true,
+        "post":    post,
+    })
+}
+
+// draftPost validates a create request and builds the post it describes,
+// with defaults applied and tags normalized, writing a 400 when the request
+// is invalid. ID, AuthorVerified and the timestamps are left to the caller.
+func (fs *FeedService) draftPost(c *gin.Context, authorID primitive.ObjectID, req CreatePostRequest) (Post, bool) {
+    if err := fs.validatePostBody(req.Content, req.Media); err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+        return Post{}, false
+    }
+    format, ok := normalizeContentFormat(req.ContentFormat)
+    if !ok {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "contentFormat must be plain or markdown")
+        return Post{}, false
+    }
+    if req.Type == "" {
+        req.Type = "text"
+    }
+    if !containsString(postTypes, req.Type) {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post type")
+        return Post{}, false
+    }
+    if req.Visibility == "" {
+        req.Visibility = VisibilityFriends
+    }
+    switch req.Visibility {
+    case VisibilityPublic, VisibilityFriends, VisibilityCloseFriends, VisibilityPrivate:
+    default:
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid visibility")
+        return Post{}, false
+    }
+
+    media := req.Media
+    if media == nil {
+        media = []MediaItem{}
+    }
+    return Post{
+        Author:        authorID,
+        Content:       req.Content,
+        ContentFormat: format,
+        Type:          req.Type,
+        Visibility:    req.Visibility,
+        Media:         media,
+        Tags:          normalizeTags(req.Tags),
+        Reactions:     map[string]int{}, // a null field would reject $inc on reactions.<type>
+        IsActive:      true,
+    }, true
+}
+
+// PreviewPost runs a draft through the same validation and normalization as
+// CreatePost and returns the post as a feed would show it, with contentHtml
+// rendered, without storing anything or recording the content for duplicate
+// detection. The preview's id is zero. The service does no moderation, mention
+// resolution or media signing on create, so there are none to preview.
+func (fs *FeedService) PreviewPost(c *gin.Context) {
+    authorID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+
+    var req CreatePostRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    post, ok := fs.draftPost(c, authorID, req)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    post.AuthorVerified, err = fs.authorVerified(ctx, authorID)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to preview post")
+        return
+    }
+    now := fs.now()
+    post.CreatedAt, post.UpdatedAt = now, now
+    if post.ContentHTML, err = renderContentHTML(post); err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Content could not be rendered")
+        return
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "post":    post,
+    })
+}
+
+// authorVerified reads the verified badge from the author's user document, to
+// be denormalized onto a new post. A missing user reads as unverified.
+func (fs *FeedService) authorVerified(ctx context.Context, authorID primitive.ObjectID) (bool, error) {
+    var user struct {
+        Verified bool `bson:"verified"`
+    }
+    err := fs.mongo.Database("crown-social").Collection("users").
+        FindOne(ctx, bson.M{"_id": authorID}, options.FindOne().SetProjection(bson.M{"verified": 1})).Decode(&user)
+    if err == mongo.ErrNoDocuments {
+        return false, nil
+    }
+    return user.Verified, err
+}
+
+// announcePost runs after a post is inserted: it invalidates the feeds the
+// post now appears in and pushes it to the recipients' live feeds.
+func (fs *FeedService) announcePost(ctx context.Context, post Post) {
+    recipients, err := fs.postRecipients(ctx, post)
+    if err != nil {
+        // The post exists; readers will see it once their caches expire
+        log.Printf("Failed to resolve recipients for post %s: %v", post.ID.Hex(), err)
+    }
+
+    fs.invalidateUserFeeds(ctx, post.Author.Hex())
+    fs.queueFeedInvalidations(recipients...)
+    fs.invalidateStats(ctx, post.Author)
+    if post.Visibility == VisibilityPublic {
+        // Cached empty feeds may now have something to show
+        fs.invalidateEmptyFeeds()
+    }
+    if len(recipients) > 0 {
+        if err := fs.publishEvent(ctx, recipients, "new_post", gin.H{"post": post}); err != nil {
+            log.Printf("Failed to publish new_post event: %v", err)
+        }
+    }
+}
+
+func postCacheKey(postID primitive.ObjectID) string {
+    return "post:" + postID.Hex()
+}
+
+// invalidatePost drops a post's cached copy after anything that changes it.
+func (fs *FeedService) invalidatePost(ctx context.Context, postID primitive.ObjectID) {
+    fs.cache.Del(ctx, postCacheKey(postID))
+}
+
+// GetPost serves one post for deep links and share previews. The document is
+// cached for POST_CACHE_TTL regardless of viewer, and visibility is checked on
+// every request. Unlike the embedded post lookups, a post the caller may not
+// see is a 403 rather than a 404, so clients can prompt to sign in or
+// befriend the author.
+func (fs *FeedService) GetPost(c *gin.Context) {
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    var post Post
+    cacheKey := postCacheKey(postID)
+    data, err := fs.cache.Get(ctx, cacheKey)
+    cacheHit := err == nil && json.Unmarshal(data, &post) == nil
+    fs.metrics.observeCacheLookup("post", cacheHit)
+    if !cacheHit {
+        err := fs.mongo.Database("crown-social").Collection("posts").
+            FindOne(ctx, bson.M{"_id": postID, "isActive": true, "deletedAt": nil}).Decode(&post)
+        if err == mongo.ErrNoDocuments {
+            respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+            return
+        }
+        if err != nil {
+            respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch post")
+            return
+        }
+        if postJSON, err := json.Marshal(post); err == nil {
+            fs.cache.Set(ctx, cacheKey, postJSON, fs.postCacheTTL)
+        }
+    }
+
+    viewer, _ := primitive.ObjectIDFromHex(requestUserID(c))
+    rel, err := fs.resolveRelationship(ctx, viewer)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch post")
+        return
+    }
+    if !canView(post, rel) {
+        respondError(c, http.StatusForbidden, ErrCodeForbidden, "Not allowed to view this post")
+        return
+    }
+
+    posts := []Post{post}
+    fs.rewriteMediaURLs(posts)
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":  true,
+        "post":     posts[0],
+        "cacheHit": cacheHit,
+    })
+}
+
+// maxBatchPosts caps POST /posts/batch, which inlines the IDs into one $in.
+const maxBatchPosts = 100
+
+type BatchPostsRequest struct {
+    IDs []string `json:"ids"`
+}
+
+// GetPostsBatch fetches up to maxBatchPosts posts in one query, for services
+// rendering many previews at once. Posts come back in request order; ones that
+// are missing, deleted or hidden from the caller are left out and listed in
+// "missing", without saying which of those applies.
+func (fs *FeedService) GetPostsBatch(c *gin.Context) {
+    var req BatchPostsRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    if len(req.IDs) == 0 {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "ids is required")
+        return
+    }
+    if len(req.IDs) > maxBatchPosts {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("At most %d ids allowed", maxBatchPosts))
+        return
+    }
+
+    ids := make([]primitive.ObjectID, 0, len(req.IDs))
+    var invalid []string
+    for _, raw := range req.IDs {
+        id, err := primitive.ObjectIDFromHex(raw)
+        if err != nil {
+            invalid = append(invalid, raw)
+            continue
+        }
+        if !containsID(ids, id) {
+            ids = append(ids, id)
+        }
+    }
+    if len(invalid) > 0 {
+        respondErrorDetails(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post ids", gin.H{"invalidIds": invalid})
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    viewer, _ := primitive.ObjectIDFromHex(requestUserID(c))
+    rel, err := fs.resolveRelationship(ctx, viewer)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch posts")
+        return
+    }
+    found, err := findPosts(ctx, fs.mongo.Database("crown-social").Collection("posts"),
+        bson.M{"_id": bson.M{"$in": ids}, "isActive": true, "deletedAt": nil}, options.Find())
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch posts")
+        return
+    }
+    byID := make(map[primitive.ObjectID]Post, len(found))
+    for _, post := range found {
+        if canView(post, rel) {
+            byID[post.ID] = post
+        }
+    }
+
+    posts := make([]Post, 0, len(byID))
+    missing := []string{}
+    for _, id := range ids {
+        if post, ok := byID[id]; ok {
+            posts = append(posts, post)
+        } else {
+            missing = append(missing, id.Hex())
+        }
+    }
+    fs.rewriteMediaURLs(posts)
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "posts":   posts,
+        "missing": missing,
+    })
+}
+
+type UpdatePostRequest struct {
+    Content string      `json:"content"`
+    Media   []MediaItem `json:"media"`
+    Tags    []string    `json:"tags"`
+    // UpdatedAt is the post's updatedAt as the client last saw it; the edit
+    // applies only if nobody has changed the post since
+    UpdatedAt *time.Time `json:"updatedAt"`
+}
+
+// UpdatePost replaces the content, tags and media of one of the caller's
+// posts and marks it edited. Everything else, visibility included, is fixed at
+// creation. Readers with the post on screen get a post_updated event.
+//
+// Edits are optimistic: the write is conditional on the stored updatedAt
+// still matching the client's, so of two edits made from the same version one
+// wins and the other gets 409 with the current post to merge against.
+func (fs *FeedService) UpdatePost(c *gin.Context) {
+    authorID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    var req UpdatePostRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    if err := fs.validatePostBody(req.Content, req.Media); err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, err.Error())
+        return
+    }
+    if req.UpdatedAt == nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "updatedAt is required")
+        return
+    }
+    // Mongo keeps milliseconds, which is also what responses carry
+    expected := req.UpdatedAt.Truncate(time.Millisecond)
+    media := req.Media
+    if media == nil {
+        media = []MediaItem{}
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    var previous Post
+    err = fs.mongo.Database("crown-social").Collection("posts").
+        FindOne(ctx, bson.M{"_id": postID, "isActive": true, "deletedAt": nil}).Decode(&previous)
+    if err == mongo.ErrNoDocuments {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+        return
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update post")
+        return
+    }
+    if previous.Author != authorID {
+        respondError(c, http.StatusForbidden, ErrCodeForbidden, "Only the author can edit this post")
+        return
+    }
+    if !previous.UpdatedAt.Equal(expected) {
+        respondEditConflict(c, previous)
+        return
+    }
+
+    // The new updatedAt must differ from the one just matched, or a second
+    // edit from the same version within the millisecond would also apply
+    now := fs.now().Truncate(time.Millisecond)
+    if !now.After(expected) {
+        now = expected.Add(time.Millisecond)
+    }
+    var post Post
+    err = fs.postsWriteCollection().FindOneAndUpdate(ctx,
+        bson.M{"_id": postID, "author": authorID, "isActive": true, "deletedAt": nil, "updatedAt": expected},
+        bson.M{"$set": bson.M{
+            "content":   req.Content,
+            "media":     media,
+            "tags":      normalizeTags(req.Tags),
+            "edited":    true,
+            "editedAt":  now,
+            "updatedAt": now,
+        }},
+        options.FindOneAndUpdate().SetReturnDocument(options.After),
+    ).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        // Another edit or a delete landed between the read and the write
+        var current Post
+        err = fs.mongo.Database("crown-social").Collection("posts").
+            FindOne(ctx, bson.M{"_id": postID, "isActive": true, "deletedAt": nil}).Decode(&current)
+        if err == mongo.ErrNoDocuments {
+            respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+            return
+        }
+        if err != nil {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update post")
+            return
+        }
+        respondEditConflict(c, current)
+        return
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update post")
+        return
+    }
+
+    recipients, err := fs.postRecipients(ctx, post)
+    if err != nil {
+        log.Printf("Failed to resolve recipients for post %s: %v", postID.Hex(), err)
+    }
+    fs.invalidateUserFeeds(ctx, authorID.Hex())
+    fs.queueFeedInvalidations(recipients...)
+    fs.invalidatePost(ctx, postID)
+    if post.Visibility == VisibilityPublic {
+        // Tag pages hold public posts only; cover tags both added and removed
+        for _, tag := range append(previous.Tags, post.Tags...) {
+            fs.cache.DeleteMatching(ctx, tagFeedKeyPattern(tag))
+        }
+    }
+    if err := fs.publishEvent(ctx, append(recipients, authorID.Hex()), "post_updated", gin.H{"post": post}); err != nil {
+        log.Printf("Failed to publish post_updated event: %v", err)
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "post":    post,
+    })
+}
+
+// respondEditConflict answers an edit made from an outdated version with the
+// post as it now stands.
+func respondEditConflict(c *gin.Context, current Post) {
+    respondErrorDetails(c, http.StatusConflict, ErrCodeConflict, "Post was changed since updatedAt", gin.H{"post": current})
+}
+
+// DeletePost soft-deletes one of the caller's posts, keeping the document with
+// a deletedAt timestamp for the audit trail, and drops the cached pages that
+// may still show it.
+func (fs *FeedService) DeletePost(c *gin.Context) {
+    authorID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    var post Post
+    err = fs.mongo.Database("crown-social").Collection("posts").
+        FindOne(ctx, bson.M{"_id": postID, "isActive": true, "deletedAt": nil}).Decode(&post)
+    if err == mongo.ErrNoDocuments {
+        respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+        return
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to delete post")
+        return
+    }
+    if post.Author != authorID {
+        respondError(c, http.StatusForbidden, ErrCodeForbidden, "Only the author can delete this post")
+        return
+    }
+
+    now := fs.now()
+    _, err = fs.postsWriteCollection().UpdateOne(ctx,
+        bson.M{"_id": postID, "author": authorID},
+        bson.M{"$set": bson.M{"isActive": false, "deletedAt": now, "updatedAt": now}},
+    )
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to delete post")
+        return
+    }
+
+    recipients, err := fs.postRecipients(ctx, post)
+    if err != nil {
+        log.Printf("Failed to resolve recipients for post %s: %v", postID.Hex(), err)
+    }
+    fs.invalidateUserFeeds(ctx, authorID.Hex())
+    fs.queueFeedInvalidations(recipients...)
+    fs.invalidatePost(ctx, postID)
+    fs.invalidateStats(ctx, authorID)
+    if post.Visibility == VisibilityPublic || post.Visibility == VisibilityFriends {
+        // Trending may rank it; stale fallbacks are left to expire
+        fs.cache.DeleteMatching(ctx, trendingKeyPattern)
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success": true,
+        "postId":  postID.Hex(),
+    })
+}
+
+// postRecipients is who gets a new post pushed live: the author's friends,
+// narrowed to the close-friends list for close_friends posts. Private posts
+// reach nobody else. Public posts go to friends only; everyone else picks
+// them up on their next feed build.
+func (fs *FeedService) postRecipients(ctx context.Context, post Post) ([]string, error) {
+    var ids []primitive.ObjectID
+    switch post.Visibility {
+    case VisibilityPublic, VisibilityFriends:
+        friends, err := fs.fetchFriendIDs(ctx, post.Author)
+        if err != nil {
+            return nil, err
+        }
+        ids = friends
+    case VisibilityCloseFriends:
+        var list closeFriendsList
+        err := fs.mongo.Database("crown-social").Collection("close_friends").
+            FindOne(ctx, bson.M{"_id": post.Author}).Decode(&list)
+        if err != nil && err != mongo.ErrNoDocuments {
+            return nil, err
+        }
+        ids = list.Members
+    }
+
+    recipients := make([]string, 0, len(ids))
+    for _, id := range ids {
+        recipients = append(recipients, id.Hex())
+    }
+    return recipients, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+    "context"
+    "net/http"
+    "os"
+    "sync"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func editablePost(author primitive.ObjectID, updatedAt time.Time) Post {
+    return Post{
+        ID:         primitive.NewObjectID(),
+        Author:     author,
+        Content:    "first draft",
+        Type:       "text",
+        Visibility: VisibilityPrivate,
+        Media:      []MediaItem{},
+        Tags:       []string{},
+        Reactions:  map[string]int{},
+        IsActive:   true,
+        CreatedAt:  updatedAt,
+        UpdatedAt:  updatedAt,
+    }
+}
+
+type editConflictBody struct {
+    Code    string `json:"code"`
+    Details struct {
+        Post Post `json:"post"`
+    } `json:"details"`
+}
+
+func TestUpdatePostRequiresUpdatedAt(t *testing.T) {
+    fs := newTestService(t, nil)
+    author := primitive.NewObjectID()
+    rec := serve(fs.UpdatePost, http.MethodPut, "/posts/:id", "/posts/"+primitive.NewObjectID().Hex(), author.Hex(),
+        UpdatePostRequest{Content: "fixed typo"})
+    if rec.Code != http.StatusBadRequest {
+        t.Fatalf("status = %d, want 400: %s", rec.Code, rec.Body)
+    }
+}
+
+func TestUpdatePostConflicts(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    seen := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+    author := primitive.NewObjectID()
+
+    mt.Run("stale version is rejected before writing", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        stored := editablePost(author, seen.Add(time.Second))
+        stored.Content = "edited elsewhere"
+        mt.AddMockResponses(mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, stored)))
+
+        rec := serve(fs.UpdatePost, http.MethodPut, "/posts/:id", "/posts/"+stored.ID.Hex(), author.Hex(),
+            UpdatePostRequest{Content: "fixed typo", UpdatedAt: &seen})
+        if rec.Code != http.StatusConflict {
+            t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body)
+        }
+        var body editConflictBody
+        decodeBody(t, rec, &body)
+        if body.Code != ErrCodeConflict || body.Details.Post.Content != "edited elsewhere" {
+            t.Fatalf("conflict body = %+v, want CONFLICT with the current post", body)
+        }
+        for _, event := range mt.GetAllStartedEvents() {
+            if event.CommandName == "findAndModify" {
+                t.Fatal("stale edit reached the write")
+            }
+        }
+    })
+
+    mt.Run("edit losing the race gets the winner's version", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        stored := editablePost(author, seen)
+        winner := stored
+        winner.Content = "the other device's edit"
+        winner.UpdatedAt = seen.Add(time.Second)
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, stored)),
+            mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, winner)),
+        )
+
+        rec := serve(fs.UpdatePost, http.MethodPut, "/posts/:id", "/posts/"+stored.ID.Hex(), author.Hex(),
+            UpdatePostRequest{Content: "fixed typo", UpdatedAt: &seen})
+        if rec.Code != http.StatusConflict {
+            t.Fatalf("status = %d, want 409: %s", rec.Code, rec.Body)
+        }
+        var body editConflictBody
+        decodeBody(t, rec, &body)
+        if body.Details.Post.Content != winner.Content {
+            t.Fatalf("conflict carried %q, want the winning edit", body.Details.Post.Content)
+        }
+
+        var query bson.Raw
+        for _, event := range mt.GetAllStartedEvents() {
+            if event.CommandName == "findAndModify" {
+                query = event.Command.Lookup("query").Document()
+            }
+        }
+        if query == nil {
+            t.Fatal("no findAndModify sent")
+        }
+        if got := query.Lookup("updatedAt").Time(); !got.Equal(seen) {
+            t.Fatalf("write filtered on updatedAt %s, want %s", got, seen)
+        }
+    })
+
+    mt.Run("edit of a post deleted meanwhile is a 404", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        stored := editablePost(author, seen)
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, stored)),
+            mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch),
+        )
+
+        rec := serve(fs.UpdatePost, http.MethodPut, "/posts/:id", "/posts/"+stored.ID.Hex(), author.Hex(),
+            UpdatePostRequest{Content: "fixed typo", UpdatedAt: &seen})
+        if rec.Code != http.StatusNotFound {
+            t.Fatalf("status = %d, want 404: %s", rec.Code, rec.Body)
+        }
+    })
+}
+
+// TestUpdatePostConcurrentEdits races two edits made from the same version
+// against a real server; exactly one may apply. Set MONGO_TEST_URI to run it.
+func TestUpdatePostConcurrentEdits(t *testing.T) {
+    uri := os.Getenv("MONGO_TEST_URI")
+    if uri == "" {
+        t.Skip("MONGO_TEST_URI not set")
+    }
+    ctx := context.Background()
+    client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer client.Disconnect(ctx)
+
+    fs := newTestService(t, client)
+    author := primitive.NewObjectID()
+    seen := time.Now().UTC().Truncate(time.Millisecond)
+    post := editablePost(author, seen)
+    posts := client.Database("crown-social").Collection("posts")
+    if _, err := posts.InsertOne(ctx, post); err != nil {
+        t.Fatal(err)
+    }
+    defer posts.DeleteOne(ctx, bson.M{"_id": post.ID})
+
+    contents := []string{"edit from the phone", "edit from the laptop"}
+    codes := make([]int, len(contents))
+    var wg sync.WaitGroup
+    for i, content := range contents {
+        wg.Add(1)
+        go func(i int, content string) {
+            defer wg.Done()
+            rec := serve(fs.UpdatePost, http.MethodPut, "/posts/:id", "/posts/"+post.ID.Hex(), author.Hex(),
+                UpdatePostRequest{Content: content, UpdatedAt: &seen})
+            codes[i] = rec.Code
+        }(i, content)
+    }
+    wg.Wait()
+
+    won := -1
+    for i, code := range codes {
+        switch code {
+        case http.StatusOK:
+            if won >= 0 {
+                t.Fatalf("both edits applied: %v", codes)
+            }
+            won = i
+        case http.StatusConflict:
+        default:
+            t.Fatalf("unexpected statuses %v", codes)
+        }
+    }
+    if won < 0 {
+        t.Fatalf("neither edit applied: %v", codes)
+    }
+
+    var stored Post
+    if err := posts.FindOne(ctx, bson.M{"_id": post.ID}).Decode(&stored); err != nil {
+        t.Fatal(err)
+    }
+    if stored.Content != contents[won] {
+        t.Fatalf("stored content %q, want the winner's %q", stored.Content, contents[won])
+    }
+}
+
+func TestPreviewPost(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("returns the normalized post without storing it", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        server := useMiniredis(t, fs)
+        fs.duplicates = DuplicateConfig{Enabled: true, Window: time.Minute}
+        author := primitive.NewObjectID()
+        mt.AddMockResponses(mtest.CreateCursorResponse(0, "crown-social.users", mtest.FirstBatch,
+            bson.D{{Key: "_id", Value: author}, {Key: "verified", Value: true}}))
+
+        rec := serve(fs.PreviewPost, http.MethodPost, "/posts/preview", "/posts/preview", author.Hex(), CreatePostRequest{
+            Content:       "**Launch** day",
+            ContentFormat: "markdown",
+            Tags:          []string{"#Launch", "launch", " "},
+        })
+        if rec.Code != http.StatusOK {
+            t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+        }
+        var body struct {
+            Post Post `json:"post"`
+        }
+        decodeBody(t, rec, &body)
+        post := body.Post
+        if !post.ID.IsZero() || post.Author != author || !post.AuthorVerified {
+            t.Errorf("preview identity = id %s author %s verified %v", post.ID.Hex(), post.Author.Hex(), post.AuthorVerified)
+        }
+        if post.Type != "text" || post.Visibility != VisibilityFriends || len(post.Tags) != 1 {
+            t.Errorf("defaults not applied: type %q visibility %q tags %v", post.Type, post.Visibility, post.Tags)
+        }
+        if post.ContentHTML != "<p><strong>Launch</strong> day</p>\n" {
+            t.Errorf("contentHtml = %q", post.ContentHTML)
+        }
+
+        for _, event := range mt.GetAllStartedEvents() {
+            if event.CommandName != "find" {
+                t.Errorf("preview sent %s", event.CommandName)
+            }
+        }
+        if keys := server.Keys(); len(keys) != 0 {
+            t.Errorf("preview recorded %v", keys)
+        }
+    })
+}
+
+func TestPreviewPostRejectsInvalidDraft(t *testing.T) {
+    fs := newTestService(t, nil)
+    author := primitive.NewObjectID().Hex()
+    for name, req := range map[string]CreatePostRequest{
+        "empty content": {Content: "  "},
+        "bad format":    {Content: "hi", ContentFormat: "html"},
+        "bad type":      {Content: "hi", Type: "story"},
+        "bad media":     {Content: "hi", Media: []MediaItem{{Type: "image", URL: "ftp://x"}}},
+    } {
+        rec := serve(fs.PreviewPost, http.MethodPost, "/posts/preview", "/posts/preview", author, req)
+        if rec.Code != http.StatusBadRequest {
+            t.Errorf("%s: status = %d, want 400", name, rec.Code)
+        }
+    }
+    rec := serve(fs.PreviewPost, http.MethodPost, "/posts/preview", "/posts/preview", "", CreatePostRequest{Content: "hi"})
+    if rec.Code != http.StatusUnauthorized {
+        t.Errorf("anonymous preview: status = %d, want 401", rec.Code)
+    }
+}
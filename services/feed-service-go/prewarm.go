@@ -0,0 +1,169 @@
+package main
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "fmt"
+    "log"
+    "net/http"
+    "strings"
+    "sync"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// acquireLeaderLock makes this replica the leader for a periodic job until
+// ttl expires. Only the holder of the lock runs the job for that tick.
+func (fs *FeedService) acquireLeaderLock(ctx context.Context, job string, ttl time.Duration) bool {
+    token := make([]byte, 8)
+    rand.Read(token)
+    ok, err := fs.redis.SetNX(ctx, "leader:"+job, hex.EncodeToString(token), ttl).Result()
+    return err == nil && ok
+}
+
+// runPrewarm periodically rebuilds page 1 of the configured users' feeds
+// (PREWARM_USERS) so they never expire into a cold cache. PREWARM_INTERVAL
+// should stay below the feed cache TTL. The job is leader-locked so only one
+// replica warms per interval.
+func (fs *FeedService) runPrewarm(ctx context.Context) {
+    var users []string
+    for _, userID := range strings.Split(getEnv("PREWARM_USERS", ""), ",") {
+        if userID = strings.TrimSpace(userID); userID != "" {
+            users = append(users, userID)
+        }
+    }
+    if len(users) == 0 {
+        return
+    }
+
+    interval := getEnvDuration("PREWARM_INTERVAL", 4*time.Minute)
+    log.Printf("Feed pre-warming enabled for %d users every %s", len(users), interval)
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        if fs.acquireLeaderLock(ctx, "prewarm", interval) {
+            fs.prewarmFeeds(users)
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// WarmCacheRequest lists the users whose feed page POST /cache/warm builds.
+type WarmCacheRequest struct {
+    UserIDs []string `json:"userIds"`
+    Page    int      `json:"page"`
+    Limit   int      `json:"limit"`
+}
+
+type WarmCacheResult struct {
+    UserID  string `json:"userId"`
+    Success bool   `json:"success"`
+    Error   string `json:"error,omitempty"`
+}
+
+type WarmCacheResponse struct {
+    Success bool              `json:"success"`
+    Warmed  int               `json:"warmed"`
+    Failed  int               `json:"failed"`
+    Results []WarmCacheResult `json:"results"`
+}
+
+// WarmCache builds one feed page for each listed user ahead of a traffic
+// spike, through buildFeedShared so the page lands under the key the feed
+// handlers read and a live miss on it shares the build. At most CACHE_WARM_CONCURRENCY builds run at once and
+// CACHE_WARM_MAX_USERS bounds one request.
+func (fs *FeedService) WarmCache(c *gin.Context) {
+    var body WarmCacheRequest
+    if !bindJSON(c, &body) {
+        return
+    }
+    if len(body.UserIDs) == 0 {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "userIds is required")
+        return
+    }
+    if len(body.UserIDs) > fs.cacheWarmMaxUsers {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("At most %d users per request", fs.cacheWarmMaxUsers))
+        return
+    }
+    template := FeedRequest{Page: body.Page, Limit: body.Limit}
+    if !fs.applyPageDefaults(c, &template) {
+        return
+    }
+    if !fs.feedPageCacheable(template) {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Only pages up to %d are cached", fs.maxCachedPages))
+        return
+    }
+
+    workers := fs.cacheWarmConcurrency
+    if workers < 1 {
+        workers = 1
+    }
+    results := make([]WarmCacheResult, len(body.UserIDs))
+    jobs := make(chan int)
+    var wg sync.WaitGroup
+    for w := 0; w < workers && w < len(body.UserIDs); w++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range jobs {
+                req := template
+                req.UserID = body.UserIDs[i]
+                results[i] = fs.warmFeed(c.Request.Context(), req)
+            }
+        }()
+    }
+    for i := range body.UserIDs {
+        jobs <- i
+    }
+    close(jobs)
+    wg.Wait()
+
+    response := WarmCacheResponse{Success: true, Results: results}
+    for _, result := range results {
+        if result.Success {
+            response.Warmed++
+        } else {
+            response.Failed++
+        }
+    }
+    respondJSON(c, http.StatusOK, response)
+}
+
+func (fs *FeedService) warmFeed(ctx context.Context, req FeedRequest) WarmCacheResult {
+    if _, err := primitive.ObjectIDFromHex(req.UserID); err != nil {
+        return WarmCacheResult{UserID: req.UserID, Error: "invalid user id"}
+    }
+    buildCtx, cancel := fs.opContext(ctx)
+    defer cancel()
+    if _, err := fs.buildFeedShared(buildCtx, req); err != nil {
+        log.Printf("Cache warm failed for user %s: %v", req.UserID, err)
+        fs.metrics.prewarms.WithLabelValues("error").Inc()
+        return WarmCacheResult{UserID: req.UserID, Error: err.Error()}
+    }
+    fs.metrics.prewarms.WithLabelValues("ok").Inc()
+    return WarmCacheResult{UserID: req.UserID, Success: true}
+}
+
+func (fs *FeedService) prewarmFeeds(users []string) {
+    for _, userID := range users {
+        buildCtx, cancel := fs.opContext(context.Background())
+        _, err := fs.buildFeedShared(buildCtx, FeedRequest{UserID: userID, Page: 1, Limit: 10})
+        cancel()
+        if err != nil {
+            log.Printf("Pre-warm failed for user %s: %v", userID, err)
+            fs.metrics.prewarms.WithLabelValues("error").Inc()
+            continue
+        }
+        fs.metrics.prewarms.WithLabelValues("ok").Inc()
+    }
+}
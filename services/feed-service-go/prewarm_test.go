@@ -0,0 +1,64 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+)
+
+// holdBuild occupies the singleflight slot for req's cache key until release
+// is closed, returning page to every caller that joins it.
+func holdBuild(fs *FeedService, req FeedRequest, page feedPage) (release chan struct{}) {
+    release = make(chan struct{})
+    started := make(chan struct{})
+    go fs.feedBuilds.Do(feedCacheKey(req), func() (interface{}, error) {
+        close(started)
+        <-release
+        return page, nil
+    })
+    <-started
+    return release
+}
+
+func TestPrewarmJoinsInFlightBuild(t *testing.T) {
+    // A nil Mongo client: prewarmFeeds must not start a build of its own
+    fs := newTestService(t, nil)
+    req := FeedRequest{UserID: "652f1c2ab1e4a0d3c1a9e001", Page: 1, Limit: 10}
+    release := holdBuild(fs, req, feedPage{Posts: []Post{}})
+
+    done := make(chan struct{})
+    go func() {
+        fs.prewarmFeeds([]string{req.UserID})
+        close(done)
+    }()
+    select {
+    case <-done:
+        t.Fatal("prewarm finished without waiting for the in-flight build")
+    case <-time.After(50 * time.Millisecond):
+    }
+    close(release)
+    select {
+    case <-done:
+    case <-time.After(time.Second):
+        t.Fatal("prewarm did not finish once the build completed")
+    }
+}
+
+func TestWarmFeedJoinsInFlightBuild(t *testing.T) {
+    fs := newTestService(t, nil)
+    req := FeedRequest{UserID: "652f1c2ab1e4a0d3c1a9e002", Page: 1, Limit: 10}
+    release := holdBuild(fs, req, feedPage{Posts: []Post{}})
+
+    results := make(chan WarmCacheResult)
+    go func() { results <- fs.warmFeed(context.Background(), req) }()
+    time.Sleep(20 * time.Millisecond)
+    close(release)
+    select {
+    case result := <-results:
+        if !result.Success {
+            t.Fatalf("warm failed: %s", result.Error)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("warm did not finish once the build completed")
+    }
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "log"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    // ReasonPromoted annotates paid placements injected into the feed
+    ReasonPromoted = "promoted"
+
+    promotedCacheKey = "promoted:active"
+    promotedCacheTTL = time.Minute
+    maxPromotedPool  = 50
+)
+
+// activePromotedPosts returns public posts whose promotion window is open.
+// The set is small and shared by every viewer, so it is cached briefly rather
+// than queried per request.
+func (fs *FeedService) activePromotedPosts(ctx context.Context) ([]Post, error) {
+    if data, err := fs.cache.Get(ctx, promotedCacheKey); err == nil {
+        var posts []Post
+        if json.Unmarshal(data, &posts) == nil {
+            return posts, nil
+        }
+    }
+
+    collection := fs.mongo.Database("crown-social").Collection("posts")
+    // Shared by every viewer, so only what a stranger could see qualifies
+    filter := visibilityFilter(viewerRelationship{})
+    filter["isActive"] = true
+    filter["promotedUntil"] = bson.M{"$gt": fs.now()}
+    opts := options.Find().
+        SetSort(bson.D{{Key: "promotedUntil", Value: 1}}).
+        SetLimit(maxPromotedPool)
+
+    cursor, err := collection.Find(ctx, filter, opts)
+    if err != nil {
+        return nil, err
+    }
+    defer cursor.Close(ctx)
+
+    var posts []Post
+    if err := cursor.All(ctx, &posts); err != nil {
+        return nil, err
+    }
+
+    postsJSON, _ := json.Marshal(posts)
+    fs.cache.Set(ctx, promotedCacheKey, postsJSON, promotedCacheTTL)
+    return posts, nil
+}
+
+// injectPromoted places promoted posts after every FEED_PROMOTED_EVERY organic
+// posts, at most FEED_PROMOTED_MAX_PER_PAGE per page. Promotions already on
+// the page organically are not repeated, and successive pages rotate through
+// the pool.
+func (fs *FeedService) injectPromoted(ctx context.Context, posts []Post, page int) []Post {
+    if fs.promotedEvery <= 0 || fs.promotedMaxPerPage <= 0 || len(posts) == 0 {
+        return posts
+    }
+
+    pool, err := fs.activePromotedPosts(ctx)
+    if err != nil {
+        log.Printf("Failed to load promoted posts: %v", err)
+        return posts
+    }
+    if len(pool) == 0 {
+        return posts
+    }
+
+    seen := make(map[primitive.ObjectID]bool, len(posts))
+    for _, post := range posts {
+        seen[post.ID] = true
+    }
+
+    var picks []Post
+    start := (page - 1) * fs.promotedMaxPerPage
+    for i := 0; i < len(pool) && len(picks) < fs.promotedMaxPerPage; i++ {
+        candidate := pool[(start+i)%len(pool)]
+        if seen[candidate.ID] {
+            continue
+        }
+        seen[candidate.ID] = true
+        candidate.Reason = ReasonPromoted
+        picks = append(picks, candidate)
+    }
+
+    result := make([]Post, 0, len(posts)+len(picks))
+    for i, post := range posts {
+        result = append(result, post)
+        if (i+1)%fs.promotedEvery == 0 && len(picks) > 0 {
+            result = append(result, picks[0])
+            picks = picks[1:]
+        }
+    }
+    return result
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+    "regexp"
+    "unicode"
+)
+
+// QualityConfig controls the minimum-quality filter applied to "For You"
+// feed candidates. The chronological following feed is never filtered.
+type QualityConfig struct {
+    Enabled          bool
+    MinContentLength int // letters/digits left after stripping links and emoji
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+`)
+
+// passesQuality reports whether a post carries enough substance to be shown
+// in a ranked feed. Posts with media or tags are kept even with short text;
+// otherwise empty, emoji-only and link-only bodies are rejected.
+func passesQuality(p Post, cfg QualityConfig) bool {
+    if !cfg.Enabled {
+        return true
+    }
+    // A share stands on the post it references, even with no comment
+    if len(p.Media) > 0 || len(p.Tags) > 0 || p.SharedPost != nil {
+        return true
+    }
+    n := meaningfulLength(p.Content)
+    return n > 0 && n >= cfg.MinContentLength
+}
+
+// meaningfulLength counts letters and digits once links are removed, so emoji,
+// punctuation and bare URLs contribute nothing.
+func meaningfulLength(content string) int {
+    count := 0
+    for _, r := range linkPattern.ReplaceAllString(content, "") {
+        if unicode.IsLetter(r) || unicode.IsDigit(r) {
+            count++
+        }
+    }
+    return count
+}
+
+func filterByQuality(posts []Post, cfg QualityConfig) []Post {
+    if !cfg.Enabled {
+        return posts
+    }
+    filtered := posts[:0]
+    for _, p := range posts {
+        if passesQuality(p, cfg) {
+            filtered = append(filtered, p)
+        }
+    }
+    return filtered
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+    "testing"
+
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPassesQuality(t *testing.T) {
+    on := QualityConfig{Enabled: true, MinContentLength: 10}
+    shared := primitive.NewObjectID()
+
+    cases := []struct {
+        name string
+        post Post
+        cfg  QualityConfig
+        want bool
+    }{
+        {"disabled keeps empty posts", Post{}, QualityConfig{MinContentLength: 10}, true},
+        {"empty content", Post{Content: ""}, on, false},
+        {"whitespace only", Post{Content: "   \n\t "}, on, false},
+        {"below threshold", Post{Content: "too short"}, on, false},
+        {"exactly at threshold", Post{Content: "abcdefghij"}, on, true},
+        {"above threshold", Post{Content: "long enough to keep"}, on, true},
+        {"punctuation does not count", Post{Content: "wow!!! ....... ???"}, on, false},
+        {"emoji only", Post{Content: "🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥"}, on, false},
+        {"link only", Post{Content: "https://example.com/a-very-long-path"}, on, false},
+        {"www link only", Post{Content: "www.example.com/article"}, on, false},
+        {"link plus enough text", Post{Content: "read this writeup https://example.com"}, on, true},
+        {"digits count", Post{Content: "2026 01 02 03"}, on, true},
+        {"non-Latin letters count", Post{Content: "こんにちは世界こんにちは"}, on, true},
+        {"media exempts short text", Post{Content: "", Media: []MediaItem{{Type: "image"}}}, on, true},
+        {"tags exempt short text", Post{Content: "hi", Tags: []string{"golang"}}, on, true},
+        {"share exempts empty comment", Post{Type: postTypeShare, SharedPost: &shared}, on, true},
+        {"zero threshold still rejects empty", Post{Content: "🔥"}, QualityConfig{Enabled: true}, false},
+        {"zero threshold keeps any text", Post{Content: "ok"}, QualityConfig{Enabled: true}, true},
+    }
+    for _, tc := range cases {
+        t.Run(tc.name, func(t *testing.T) {
+            if got := passesQuality(tc.post, tc.cfg); got != tc.want {
+                t.Errorf("passesQuality = %v, want %v", got, tc.want)
+            }
+        })
+    }
+}
+
+func TestFilterByQualityKeepsOrder(t *testing.T) {
+    cfg := QualityConfig{Enabled: true, MinContentLength: 5}
+    posts := []Post{{Content: "first keeper"}, {Content: "no"}, {Content: "second keeper"}}
+    kept := filterByQuality(posts, cfg)
+    if len(kept) != 2 || kept[0].Content != "first keeper" || kept[1].Content != "second keeper" {
+        t.Fatalf("kept %v", kept)
+    }
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+    "net/http"
+    "net/http/httptest"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+)
+
+// limitedServe sends one request from u1 through RateLimit and returns the
+// status.
+func limitedServe(fs *FeedService) int {
+    router := gin.New()
+    router.GET("/limited", fs.RateLimit(), func(c *gin.Context) { c.Status(http.StatusOK) })
+    rec := httptest.NewRecorder()
+    req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+    req.Header.Set("X-User-ID", "u1")
+    router.ServeHTTP(rec, req)
+    return rec.Code
+}
+
+func TestRateLimitRejectsOverLimit(t *testing.T) {
+    fs := newTestService(t, nil)
+    useMiniredis(t, fs)
+    fs.rateLimit = 2
+
+    for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+        if got := limitedServe(fs); got != want {
+            t.Fatalf("request %d: status %d, want %d", i+1, got, want)
+        }
+    }
+}
+
+func TestRateLimitSkipsDegradedCache(t *testing.T) {
+    fs := newTestService(t, nil)
+    server := useMiniredis(t, fs)
+    fs.rateLimit = 1
+    fs.cacheState = &degradableCache{backend: fs.cache}
+    fs.cacheState.degraded.Store(true)
+
+    for i := 0; i < 3; i++ {
+        if got := limitedServe(fs); got != http.StatusOK {
+            t.Fatalf("request %d while degraded: status %d", i+1, got)
+        }
+    }
+    if keys := server.Keys(); len(keys) != 0 {
+        t.Fatalf("degraded rate limit touched Redis: %v", keys)
+    }
+}
+
+func TestRateLimitFailsOpenWithinDeadline(t *testing.T) {
+    fs := newTestService(t, nil)
+    fs.rateLimit = 1
+    fs.opTimeout = 200 * time.Millisecond
+
+    start := time.Now()
+    for i := 0; i < 2; i++ {
+        if got := limitedServe(fs); got != http.StatusOK {
+            t.Fatalf("request %d with Redis down: status %d", i+1, got)
+        }
+    }
+    if elapsed := time.Since(start); elapsed > time.Second {
+        t.Fatalf("fail-open took %v", elapsed)
+    }
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+    "context"
+    "log"
+    "net/http"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Reaction types accepted by POST /posts/:id/react.
+var reactionTypes = []string{"like", "love", "haha", "wow", "sad", "angry"}
+
+// The main app's embedded likes schema calls "haha" "laugh"; entries keep its
+// name so documents it loads still validate.
+func likeEntryType(reaction string) string {
+    if reaction == "haha" {
+        return "laugh"
+    }
+    return reaction
+}
+
+func reactionFromEntry(entryType string) string {
+    switch entryType {
+    case "laugh":
+        return "haha"
+    case "":
+        return "like"
+    }
+    return entryType
+}
+
+// likeEntryMatch matches the user's likes entry only while it still has the
+// type read earlier, so concurrent changes from the same user cannot double
+// count. Entries written before reactions have no type and mean "like".
+func likeEntryMatch(userID primitive.ObjectID, entryType string) bson.M {
+    match := bson.M{"user": userID, "type": entryType}
+    if entryType == "" {
+        match["type"] = bson.M{"$in": []interface{}{nil, ""}}
+    }
+    return bson.M{"$elemMatch": match}
+}
+
+// floorDecrement is a pipeline expression for field-1, floored at zero.
+// Counters can sit below the likes array (posts liked before per-type
+// counters existed have none), so a plain $inc could go negative.
+func floorDecrement(field string) bson.M {
+    return bson.M{"$max": []interface{}{0, bson.M{"$subtract": []interface{}{bson.M{"$ifNull": []interface{}{field, 0}}, 1}}}}
+}
+
+type ReactRequest struct {
+    Type string `json:"type"`
+}
+
+// postReactions is the slice of a post document the reaction handlers read
+// back. Likes holds at most the caller's own entry.
+type postReactions struct {
+    LikesCount int            `bson:"likesCount"`
+    Reactions  map[string]int `bson:"reactions"`
+    Likes      []struct {
+        Type string `bson:"type"`
+    } `bson:"likes"`
+}
+
+var reactionProjection = bson.M{"likesCount": 1, "reactions": 1}
+
+func (fs *FeedService) LikePost(c *gin.Context) {
+    fs.react(c, "like")
+}
+
+func (fs *FeedService) UnlikePost(c *gin.Context) {
+    fs.unreact(c)
+}
+
+// ReactToPost sets the caller's reaction to a post, replacing any earlier one.
+func (fs *FeedService) ReactToPost(c *gin.Context) {
+    var req ReactRequest
+    if !bindJSON(c, &req) {
+        return
+    }
+    reaction := strings.ToLower(req.Type)
+    if !containsString(reactionTypes, reaction) {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "type must be one of " + strings.Join(reactionTypes, ", "))
+        return
+    }
+    fs.react(c, reaction)
+}
+
+// react records the caller's reaction in the post's embedded likes, the same
+// array the main app writes and counter reconciliation recounts. Each user has
+// one entry: a first reaction bumps likesCount (the total across types) and
+// the type's reactions counter, a different reaction moves the count between
+// types, and repeating the current one changes nothing.
+func (fs *FeedService) react(c *gin.Context, reaction string) {
+    userID, postID, post, ok := fs.reactionTarget(c)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    opts := options.FindOneAndUpdate().
+        SetReturnDocument(options.After).
+        SetProjection(reactionProjection)
+
+    var result postReactions
+    err := fs.postsWriteCollection().FindOneAndUpdate(ctx,
+        bson.M{"_id": postID, "isActive": true, "likes.user": bson.M{"$ne": userID}},
+        bson.M{
+            "$push": bson.M{"likes": bson.M{"user": userID, "type": likeEntryType(reaction), "createdAt": fs.now()}},
+            "$inc":  bson.M{"likesCount": 1, "reactions." + reaction: 1},
+        },
+        opts,
+    ).Decode(&result)
+    first := err == nil
+    if err == mongo.ErrNoDocuments {
+        // Already reacted; switch types if needed
+        var current postReactions
+        current, err = fs.currentReaction(ctx, postID, userID)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update reaction")
+            return
+        }
+        previous := reactionFromEntry(current.Likes[0].Type)
+        if previous == reaction {
+            fs.respondReaction(c, postID, reaction, current)
+            return
+        }
+        update := []bson.M{{"$set": bson.M{
+            "likes": bson.M{"$map": bson.M{
+                "input": "$likes",
+                "in": bson.M{"$cond": []interface{}{
+                    bson.M{"$eq": []interface{}{"$$this.user", userID}},
+                    bson.M{"$mergeObjects": []interface{}{"$$this", bson.M{"type": likeEntryType(reaction)}}},
+                    "$$this",
+                }},
+            }},
+            "reactions." + reaction: bson.M{"$add": []interface{}{bson.M{"$ifNull": []interface{}{"$reactions." + reaction, 0}}, 1}},
+            "reactions." + previous: floorDecrement("$reactions." + previous),
+        }}}
+        err = fs.postsWriteCollection().FindOneAndUpdate(ctx,
+            bson.M{"_id": postID, "likes": likeEntryMatch(userID, current.Likes[0].Type)},
+            update, opts,
+        ).Decode(&result)
+        if err == mongo.ErrNoDocuments {
+            // Raced with another change from the same user; report what won
+            current, err = fs.currentReaction(ctx, postID, userID)
+            if err != nil {
+                respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update reaction")
+                return
+            }
+            fs.respondReaction(c, postID, reactionFromEntry(current.Likes[0].Type), current)
+            return
+        }
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update reaction")
+        return
+    }
+
+    if first && post.Author != userID {
+        fs.incrementUnread(ctx, post.Author.Hex())
+    }
+    fs.invalidatePost(ctx, postID)
+    fs.invalidateStats(ctx, post.Author)
+    fs.publishReactions(ctx, post, result)
+    fs.respondReaction(c, postID, reaction, result)
+}
+
+// unreact removes the caller's reaction of whatever type.
+func (fs *FeedService) unreact(c *gin.Context) {
+    userID, postID, post, ok := fs.reactionTarget(c)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    current, err := fs.currentReaction(ctx, postID, userID)
+    if err == mongo.ErrNoDocuments {
+        // Nothing to remove
+        current, err = fs.postReactionCounts(ctx, postID)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update reaction")
+            return
+        }
+        fs.respondReaction(c, postID, "", current)
+        return
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update reaction")
+        return
+    }
+    entryType := current.Likes[0].Type
+    reaction := reactionFromEntry(entryType)
+
+    // Pipeline form so the counters can use floorDecrement
+    update := []bson.M{{"$set": bson.M{
+        "likes": bson.M{"$filter": bson.M{
+            "input": "$likes",
+            "cond":  bson.M{"$ne": []interface{}{"$$this.user", userID}},
+        }},
+        "likesCount":            floorDecrement("$likesCount"),
+        "reactions." + reaction: floorDecrement("$reactions." + reaction),
+    }}}
+    opts := options.FindOneAndUpdate().
+        SetReturnDocument(options.After).
+        SetProjection(reactionProjection)
+
+    var result postReactions
+    err = fs.postsWriteCollection().FindOneAndUpdate(ctx,
+        bson.M{"_id": postID, "likes": likeEntryMatch(userID, entryType)},
+        update, opts,
+    ).Decode(&result)
+    if err == mongo.ErrNoDocuments {
+        // Removed or changed concurrently; the other request published
+        result, err = fs.postReactionCounts(ctx, postID)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update reaction")
+            return
+        }
+        fs.respondReaction(c, postID, "", result)
+        return
+    }
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update reaction")
+        return
+    }
+
+    fs.invalidatePost(ctx, postID)
+    fs.invalidateStats(ctx, post.Author)
+    fs.publishReactions(ctx, post, result)
+    fs.respondReaction(c, postID, "", result)
+}
+
+// reactionTarget parses the caller and post, writing the error response and
+// returning false when either is invalid or the post is not visible.
+func (fs *FeedService) reactionTarget(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, Post, bool) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return userID, userID, Post{}, false
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return userID, postID, Post{}, false
+    }
+    post, ok := fs.loadVisiblePost(c, postID)
+    return userID, postID, post, ok
+}
+
+// currentReaction reads the post's counts and the caller's likes entry,
+// returning mongo.ErrNoDocuments when the caller has not reacted.
+func (fs *FeedService) currentReaction(ctx context.Context, postID, userID primitive.ObjectID) (postReactions, error) {
+    var result postReactions
+    opts := options.FindOne().SetProjection(bson.M{
+        "likesCount": 1,
+        "reactions":  1,
+        "likes":      bson.M{"$elemMatch": bson.M{"user": userID}},
+    })
+    err := fs.mongo.Database("crown-social").Collection("posts").
+        FindOne(ctx, bson.M{"_id": postID, "likes.user": userID}, opts).Decode(&result)
+    if err == nil && len(result.Likes) == 0 {
+        err = mongo.ErrNoDocuments
+    }
+    return result, err
+}
+
+func (fs *FeedService) postReactionCounts(ctx context.Context, postID primitive.ObjectID) (postReactions, error) {
+    var result postReactions
+    opts := options.FindOne().SetProjection(reactionProjection)
+    err := fs.mongo.Database("crown-social").Collection("posts").
+        FindOne(ctx, bson.M{"_id": postID}, opts).Decode(&result)
+    return result, err
+}
+
+// publishReactions pushes the new counts to the author and everyone the post
+// was delivered to, so open feeds update live.
+func (fs *FeedService) publishReactions(ctx context.Context, post Post, counts postReactions) {
+    recipients, err := fs.postRecipients(ctx, post)
+    if err != nil {
+        log.Printf("Failed to resolve recipients for post %s: %v", post.ID.Hex(), err)
+    }
+    recipients = append(recipients, post.Author.Hex())
+
+    event := gin.H{"postId": post.ID.Hex(), "likesCount": counts.LikesCount, "reactions": counts.Reactions}
+    if err := fs.publishEvent(ctx, recipients, "like_update", event); err != nil {
+        log.Printf("Failed to publish like_update event: %v", err)
+    }
+}
+
+func (fs *FeedService) respondReaction(c *gin.Context, postID primitive.ObjectID, reaction string, counts postReactions) {
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":    true,
+        "postId":     postID.Hex(),
+        "liked":      reaction != "",
+        "reaction":   reaction,
+        "likesCount": counts.LikesCount,
+        "reactions":  counts.Reactions,
+    })
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+    "context"
+    "log"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reconcileBatchSize bounds how many posts are recounted per bulk write.
+const reconcileBatchSize = 500
+
+// runCounterReconciliation periodically recomputes engagement counters for
+// recently engaged posts and repairs any drift. likesCount is recounted from
+// the post's embedded likes and commentsCount from active documents in
+// comments. sharesCount has no source of truth to recount from and is left
+// alone. COUNTER_RECONCILE_INTERVAL enables the job; COUNTER_RECONCILE_WINDOW
+// sets how far back "recently engaged" reaches.
+func (fs *FeedService) runCounterReconciliation(ctx context.Context) {
+    interval := getEnvDuration("COUNTER_RECONCILE_INTERVAL", 0)
+    if interval <= 0 {
+        return
+    }
+    window := getEnvDuration("COUNTER_RECONCILE_WINDOW", 24*time.Hour)
+    log.Printf("Counter reconciliation enabled every %s over the last %s", interval, window)
+
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+
+    for {
+        if fs.acquireLeaderLock(ctx, "reconcile-counters", interval) {
+            if err := fs.reconcileCounters(ctx, fs.now().Add(-window)); err != nil {
+                log.Printf("Counter reconciliation failed: %v", err)
+            }
+        }
+
+        select {
+        case <-ctx.Done():
+            return
+        case <-ticker.C:
+        }
+    }
+}
+
+// postCounters is a post's stored counters next to its recounted likes.
+type postCounters struct {
+    ID            primitive.ObjectID `bson:"_id"`
+    LikesCount    int                `bson:"likesCount"`
+    CommentsCount int                `bson:"commentsCount"`
+    ActualLikes   int                `bson:"actualLikes"`
+}
+
+// reconcileCounters recounts posts created, liked or commented on since the
+// given time. Likes and comments never touch a post's updatedAt, so that
+// field can't scope the scan.
+func (fs *FeedService) reconcileCounters(ctx context.Context, since time.Time) error {
+    commented, err := fs.commentsCollection().Distinct(ctx, "post", bson.M{"createdAt": bson.M{"$gte": since}})
+    if err != nil {
+        return err
+    }
+    if commented == nil {
+        commented = []interface{}{}
+    }
+
+    posts := fs.mongo.Database("crown-social").Collection("posts")
+    cursor, err := posts.Aggregate(ctx, []bson.M{
+        {"$match": bson.M{"isActive": true, "$or": []bson.M{
+            {"createdAt": bson.M{"$gte": since}},
+            {"likes.createdAt": bson.M{"$gte": since}},
+            {"_id": bson.M{"$in": commented}},
+        }}},
+        {"$project": bson.M{
+            "likesCount":    1,
+            "commentsCount": 1,
+            "actualLikes":   bson.M{"$size": bson.M{"$ifNull": []interface{}{"$likes", []interface{}{}}}},
+        }},
+    })
+    if err != nil {
+        return err
+    }
+    defer cursor.Close(ctx)
+
+    var scanned, corrected int
+    batch := make([]postCounters, 0, reconcileBatchSize)
+    flush := func() error {
+        n, err := fs.repairCounters(ctx, batch)
+        scanned += len(batch)
+        corrected += n
+        batch = batch[:0]
+        return err
+    }
+
+    for cursor.Next(ctx) {
+        var pc postCounters
+        if err := cursor.Decode(&pc); err != nil {
+            return err
+        }
+        if batch = append(batch, pc); len(batch) == reconcileBatchSize {
+            if err := flush(); err != nil {
+                return err
+            }
+        }
+    }
+    if err := cursor.Err(); err != nil {
+        return err
+    }
+    if len(batch) > 0 {
+        if err := flush(); err != nil {
+            return err
+        }
+    }
+
+    log.Printf("Counter reconciliation: %d posts scanned, %d corrected", scanned, corrected)
+    return nil
+}
+
+// repairCounters recounts comments for a batch and writes back every post
+// whose stored counters drifted. It returns how many posts were corrected.
+func (fs *FeedService) repairCounters(ctx context.Context, batch []postCounters) (int, error) {
+    ids := make([]primitive.ObjectID, len(batch))
+    for i, pc := range batch {
+        ids[i] = pc.ID
+    }
+
+    cursor, err := fs.commentsCollection().Aggregate(ctx, []bson.M{
+        {"$match": bson.M{"post": bson.M{"$in": ids}, "isActive": true}},
+        {"$group": bson.M{"_id": "$post", "count": bson.M{"$sum": 1}}},
+    })
+    if err != nil {
+        return 0, err
+    }
+    var counts []struct {
+        ID    primitive.ObjectID `bson:"_id"`
+        Count int                `bson:"count"`
+    }
+    if err := cursor.All(ctx, &counts); err != nil {
+        return 0, err
+    }
+    actualComments := make(map[primitive.ObjectID]int, len(counts))
+    for _, c := range counts {
+        actualComments[c.ID] = c.Count
+    }
+
+    var models []mongo.WriteModel
+    for _, pc := range batch {
+        set := bson.M{}
+        if delta := pc.ActualLikes - pc.LikesCount; delta != 0 {
+            set["likesCount"] = pc.ActualLikes
+            fs.metrics.counterCorrections.WithLabelValues("likesCount").Add(float64(abs(delta)))
+        }
+        if delta := actualComments[pc.ID] - pc.CommentsCount; delta != 0 {
+            set["commentsCount"] = actualComments[pc.ID]
+            fs.metrics.counterCorrections.WithLabelValues("commentsCount").Add(float64(abs(delta)))
+        }
+        if len(set) > 0 {
+            models = append(models, mongo.NewUpdateOneModel().
+                SetFilter(bson.M{"_id": pc.ID}).
+                SetUpdate(bson.M{"$set": set}))
+        }
+    }
+    if len(models) == 0 {
+        return 0, nil
+    }
+
+    _, err = fs.postsWriteCollection().BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+    return len(models), err
+}
+
+func abs(n int) int {
+    if n < 0 {
+        return -n
+    }
+    return n
+}
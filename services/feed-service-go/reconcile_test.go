@@ -0,0 +1,57 @@
+package main
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestReconcileCountersScopesByEngagement(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("recently commented post", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        commented := primitive.NewObjectID()
+        since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+        mt.AddMockResponses(
+            mtest.CreateSuccessResponse(bson.E{Key: "values", Value: bson.A{commented}}),
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch),
+        )
+        if err := fs.reconcileCounters(context.Background(), since); err != nil {
+            mt.Fatal(err)
+        }
+
+        var match bson.Raw
+        for _, event := range mt.GetAllStartedEvents() {
+            if event.CommandName == "aggregate" {
+                match = event.Command.Lookup("pipeline").Array().Index(0).Value().Document().Lookup("$match").Document()
+            }
+        }
+        if match == nil {
+            mt.Fatal("no candidate scan was sent")
+        }
+        if _, err := match.LookupErr("updatedAt"); err == nil {
+            mt.Fatal("candidates are still scoped by updatedAt")
+        }
+        branches, _ := match.Lookup("$or").Array().Values()
+        var byLike, byComment bool
+        for _, branch := range branches {
+            doc := branch.Document()
+            if _, err := doc.LookupErr("likes.createdAt"); err == nil {
+                byLike = true
+            }
+            if ids, err := doc.LookupErr("_id", "$in"); err == nil && ids.Array().Index(0).Value().ObjectID() == commented {
+                byComment = true
+            }
+        }
+        if !byLike || !byComment {
+            mt.Fatalf("candidate scope %s misses recent likes or comments", match)
+        }
+    })
+}
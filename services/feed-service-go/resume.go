@@ -0,0 +1,106 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+
+    "github.com/go-redis/redis/v8"
+)
+
+// Every event published to a user is also appended to their capped stream,
+// so a reconnecting client can fetch what it missed while offline. Live
+// delivery stays on pub/sub; the stream is only read on connect.
+const (
+    feedStreamMaxLen = 500
+    backlogPageSize  = 20
+)
+
+func userFeedStreamKey(userID string) string {
+    return "user_feed_stream:" + userID
+}
+
+// missedEvents returns, oldest first, the events published to userID after
+// lastEventID, at most WS_BACKLOG_MAX of them. truncated is set when
+// lastEventID is no longer in the window, meaning older events were lost.
+func (fs *FeedService) missedEvents(ctx context.Context, userID, lastEventID string) (events []string, truncated bool, err error) {
+    entries, err := fs.redis.XRevRangeN(ctx, userFeedStreamKey(userID), "+", "-", int64(fs.wsBacklogMax)).Result()
+    if err != nil {
+        return nil, false, err
+    }
+
+    truncated = true
+    for _, entry := range entries {
+        if id, _ := entry.Values["id"].(string); id == lastEventID {
+            truncated = false
+            break
+        }
+        if event, ok := entry.Values["event"].(string); ok {
+            events = append(events, event)
+        }
+    }
+
+    // XREVRANGE is newest first
+    for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+        events[i], events[j] = events[j], events[i]
+    }
+    return events, truncated, nil
+}
+
+// appendFeedStream queues the stream writes for one event on pipe.
+func (fs *FeedService) appendFeedStream(ctx context.Context, pipe redis.Pipeliner, userID, eventID string, payload []byte) {
+    key := userFeedStreamKey(userID)
+    pipe.XAdd(ctx, &redis.XAddArgs{
+        Stream: key,
+        MaxLen: feedStreamMaxLen,
+        Approx: true,
+        Values: map[string]interface{}{"id": eventID, "event": payload},
+    })
+    pipe.Expire(ctx, key, fs.wsStreamTTL)
+}
+
+// backlogPage is the frame a missed-event backlog is delivered in. The client
+// asks for each following page with {"type":"backlog_next"}, or skips the
+// rest with {"type":"backlog_skip"}; live events start once the backlog is
+// done.
+type backlogPage struct {
+    Type      string            `json:"type"`
+    Page      int               `json:"page"`
+    HasMore   bool              `json:"hasMore"`
+    Truncated bool              `json:"truncated,omitempty"`
+    Events    []json.RawMessage `json:"events"`
+}
+
+// backlog pages through missed events for one connection.
+type backlog struct {
+    events    []string
+    truncated bool
+    page      int
+}
+
+func (b *backlog) pending() bool {
+    return b != nil && len(b.events) > 0
+}
+
+// next renders the next page and advances past it.
+func (b *backlog) next() string {
+    n := backlogPageSize
+    if n > len(b.events) {
+        n = len(b.events)
+    }
+    b.page++
+
+    page := backlogPage{
+        Type:      "backlog_page",
+        Page:      b.page,
+        HasMore:   len(b.events) > n,
+        Truncated: b.truncated && b.page == 1,
+        Events:    make([]json.RawMessage, n),
+    }
+    for i, event := range b.events[:n] {
+        page.Events[i] = json.RawMessage(event)
+    }
+    b.events = b.events[n:]
+
+    frame, _ := json.Marshal(page)
+    return string(frame)
+}
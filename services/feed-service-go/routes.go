@@ -0,0 +1,400 @@
+package main
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+)
+
+// routeSpec describes one /api/v1 route. The same table registers handlers
+// with Gin and generates the OpenAPI document, so the two cannot drift.
+type routeSpec struct {
+    Method   string
+    Path     string // Gin syntax, e.g. /cache/:userId
+    Handler  gin.HandlerFunc
+    Summary  string
+    Auth     bool // caller identified by the gateway's X-User-ID header
+    Admin    bool // requires X-Admin-Token
+    Internal bool // requires X-Internal-Token (service-to-service)
+    Limited  bool // subject to the per-user rate limit
+    Query    []paramSpec
+    Body     interface{} // zero value of the request body type, if any
+    Response interface{} // zero value of the success body type; nil for ad-hoc objects
+    Statuses []int       // non-success status codes the handler may return
+}
+
+type paramSpec struct {
+    Name        string
+    Type        string // OpenAPI primitive type
+    Description string
+    Required    bool
+}
+
+func (fs *FeedService) routes() []routeSpec {
+    return []routeSpec{
+        {
+            Method: http.MethodGet, Path: "/health", Handler: fs.HealthCheck,
+            Summary:  "Service health, pinging Mongo and Redis",
+            Statuses: []int{http.StatusServiceUnavailable},
+        },
+        {
+            Method: http.MethodGet, Path: "/ready", Handler: fs.Ready,
+            Summary:  "Readiness probe",
+            Statuses: []int{http.StatusServiceUnavailable},
+        },
+        {
+            Method: http.MethodGet, Path: "/live", Handler: fs.Live,
+            Summary: "Liveness probe",
+        },
+        {
+            Method: http.MethodGet, Path: "/feed", Handler: fs.GetFeed,
+            Summary: "Personalized feed page for the authenticated caller",
+            Auth:    true,
+            Limited: true,
+            Query: []paramSpec{
+                {Name: "page", Type: "integer"},
+                {Name: "limit", Type: "integer", Description: "Clamped to MAX_FEED_LIMIT"},
+                {Name: "cursor", Type: "string", Description: "The previous page's nextCursor"},
+                {Name: "mode", Type: "string", Description: "personalized (default), following or tags"},
+                {Name: "includeTotal", Type: "boolean", Description: "Add pagination.total, the number of posts in the feed"},
+                {Name: "render", Type: "string", Description: "html adds sanitized contentHtml to each post"},
+            },
+            Response: FeedResponse{},
+            Statuses: []int{http.StatusNotModified, http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/feed/updates", Handler: fs.GetFeedUpdates,
+            Summary: "Feed posts created after since, oldest first, for polling clients",
+            Auth:    true,
+            Limited: true,
+            Query: []paramSpec{
+                {Name: "since", Type: "string", Description: "RFC 3339 timestamp, usually the previous response's nextSince", Required: true},
+                {Name: "limit", Type: "integer", Description: "Clamped to MAX_FEED_LIMIT"},
+            },
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/feed", Handler: fs.GetPersonalizedFeed,
+            Summary:  "Personalized feed page",
+            Limited:  true,
+            Query: []paramSpec{
+                {Name: "render", Type: "string", Description: "html adds sanitized contentHtml to each post"},
+            },
+            Body:     FeedRequest{},
+            Response: FeedResponse{},
+            Statuses: []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/feed/authors", Handler: fs.GetAuthorsFeed,
+            Summary:  "Feed restricted to a set of authors",
+            Limited:  true,
+            Body:     AuthorsFeedRequest{},
+            Response: FeedResponse{},
+            Statuses: []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/trending", Handler: fs.GetTrendingPosts,
+            Summary: "Trending posts, less authors the caller has blocked",
+            Limited: true,
+            Query: []paramSpec{
+                {Name: "timeframe", Type: "string", Description: "24h, 7d or 30d"},
+                {Name: "limit", Type: "integer", Description: "Maximum posts, clamped to MAX_FEED_LIMIT and MAX_AGGREGATION_RESULTS"},
+                {Name: "page", Type: "integer", Description: "Best-effort, since scores shift between requests; pages end at MAX_AGGREGATION_RESULTS"},
+                {Name: "cursor", Type: "string", Description: "The previous page's nextCursor; takes precedence over page"},
+                {Name: "author_verified", Type: "boolean", Description: "Only posts from verified authors"},
+            },
+            Statuses: []int{http.StatusNotModified, http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/users/:userId/stats", Handler: fs.GetUserStats,
+            Summary:  "Post count and engagement totals over a user's live posts, for profiles",
+            Limited:  true,
+            Response: UserStats{},
+            Statuses: []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/tags/:tag", Handler: fs.GetTagFeed,
+            Summary: "Public posts carrying a hashtag, newest first",
+            Query: []paramSpec{
+                {Name: "page", Type: "integer"},
+                {Name: "limit", Type: "integer", Description: "Clamped to MAX_FEED_LIMIT"},
+                {Name: "cursor", Type: "string", Description: "The previous page's nextCursor"},
+            },
+            Response: FeedResponse{},
+            Statuses: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/search", Handler: fs.SearchPosts,
+            Summary: "Full-text search over public posts, best matches first",
+            Limited: true,
+            Query: []paramSpec{
+                {Name: "q", Type: "string", Required: true, Description: "At most 100 characters"},
+                {Name: "page", Type: "integer"},
+                {Name: "limit", Type: "integer", Description: "Clamped to MAX_FEED_LIMIT"},
+            },
+            Statuses: []int{http.StatusBadRequest, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/discover", Handler: fs.GetDiscover,
+            Summary: "Recent public posts in a per-session stable random order",
+            Query: []paramSpec{
+                {Name: "session", Type: "string", Required: true, Description: "Client session id; the same id yields the same order"},
+                {Name: "page", Type: "integer"},
+                {Name: "limit", Type: "integer"},
+            },
+            Statuses: []int{http.StatusBadRequest, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodDelete, Path: "/cache/:userId", Handler: fs.InvalidateCache,
+            Summary: "Invalidate a user's feed cache",
+            Query: []paramSpec{
+                {Name: "trending", Type: "boolean", Description: "Also drop all cached trending results; requires X-Internal-Token"},
+            },
+            Statuses: []int{http.StatusForbidden, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodDelete, Path: "/cache/trending", Handler: fs.InvalidateTrending,
+            Summary:  "Drop all cached trending results",
+            Internal: true,
+            Statuses: []int{http.StatusForbidden, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/cache/warm", Handler: fs.WarmCache,
+            Summary:  "Build and cache feed pages for a list of users",
+            Internal: true,
+            Body:     WarmCacheRequest{},
+            Response: WarmCacheResponse{},
+            Statuses: []int{http.StatusBadRequest, http.StatusForbidden},
+        },
+        {
+            Method: http.MethodPost, Path: "/cache/invalidate-users", Handler: fs.InvalidateUsers,
+            Summary:  "Drop cached feed pages for many users, merging repeat requests for a user",
+            Internal: true,
+            Body:     InvalidateUsersRequest{},
+            Response: InvalidateUsersResponse{},
+            Statuses: []int{http.StatusBadRequest, http.StatusForbidden},
+        },
+        {
+            Method: http.MethodGet, Path: "/admin/users/:id/cache-stats", Handler: fs.GetCacheStats,
+            Summary:  "A user's cached feed entries, for support debugging",
+            Admin:    true,
+            Response: CacheStats{},
+            Statuses: []int{http.StatusForbidden, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodDelete, Path: "/admin/posts/:id", Handler: fs.PurgePost,
+            Summary:  "Remove a post from Mongo and every cache, for moderation; X-Admin-ID names the acting admin in the audit log",
+            Admin:    true,
+            Statuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/ws", Handler: fs.HandleWebSocket,
+            Summary: "Live feed updates over WebSocket; {\"action\":\"subscribe\",\"channels\":[...]} adds the caller's notifications channel",
+            Query: []paramSpec{
+                {Name: "userId", Type: "string", Required: true},
+                {Name: "lastEventId", Type: "string", Description: "Replay events published after this one as backlog_page frames"},
+            },
+            Statuses: []int{http.StatusSwitchingProtocols, http.StatusForbidden},
+        },
+        {
+            Method: http.MethodPut, Path: "/close-friends", Handler: fs.UpdateCloseFriends,
+            Summary:  "Replace the caller's close-friends list",
+            Auth:     true,
+            Body:     CloseFriendsRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts", Handler: fs.CreatePost,
+            Summary:  "Create a post and push it to recipients' live feeds",
+            Auth:     true,
+            Body:     CreatePostRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/preview", Handler: fs.PreviewPost,
+            Summary:  "Validate a draft and return the post as it would appear in a feed, without saving it",
+            Auth:     true,
+            Body:     CreatePostRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/batch", Handler: fs.GetPostsBatch,
+            Summary:  "Up to 100 posts by id, in request order, less any missing or hidden",
+            Body:     BatchPostsRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/posts/:id", Handler: fs.GetPost,
+            Summary:  "One post, for deep links and share previews",
+            Statuses: []int{http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPut, Path: "/posts/:id", Handler: fs.UpdatePost,
+            Summary:  "Edit the content, tags and media of one of the caller's posts, if unchanged since updatedAt",
+            Auth:     true,
+            Body:     UpdatePostRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodDelete, Path: "/posts/:id", Handler: fs.DeletePost,
+            Summary:  "Soft-delete one of the caller's posts",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/like", Handler: fs.LikePost,
+            Summary:  "Like a post (a \"like\" reaction) and push the new counts live",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/react", Handler: fs.ReactToPost,
+            Summary:  "Set the caller's reaction to a post",
+            Auth:     true,
+            Body:     ReactRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodDelete, Path: "/posts/:id/like", Handler: fs.UnlikePost,
+            Summary:  "Remove the caller's like or reaction from a post",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/share", Handler: fs.SharePost,
+            Summary:  "Count a share of a post, optionally reposting it, and push the new count live",
+            Auth:     true,
+            Body:     SharePostRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/pin", Handler: fs.PinPost,
+            Summary:  "Pin one of the caller's posts to the top of their feed, replacing any earlier pin",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodDelete, Path: "/posts/:id/pin", Handler: fs.UnpinPost,
+            Summary:  "Unpin the caller's pinned post",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/posts/:id/comments", Handler: fs.GetComments,
+            Summary: "Thread summary, or replies to one comment with parentId",
+            Query: []paramSpec{
+                {Name: "parentId", Type: "string", Description: "Return replies to this comment"},
+                {Name: "page", Type: "integer"},
+                {Name: "limit", Type: "integer"},
+            },
+            Statuses: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/comments", Handler: fs.CreateComment,
+            Summary:  "Comment on a post or reply to a comment",
+            Auth:     true,
+            Body:     CreateCommentRequest{},
+            Statuses: []int{http.StatusCreated, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/save", Handler: fs.SavePost,
+            Summary:  "Bookmark a post",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusConflict, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodDelete, Path: "/posts/:id/save", Handler: fs.UnsavePost,
+            Summary:  "Remove a bookmark",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodGet, Path: "/saved", Handler: fs.GetSavedPosts,
+            Summary: "The caller's saved posts, most recently saved first",
+            Auth:    true,
+            Query: []paramSpec{
+                {Name: "page", Type: "integer"},
+                {Name: "limit", Type: "integer", Description: "Clamped to MAX_FEED_LIMIT"},
+            },
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/view", Handler: fs.RecordView,
+            Summary:  "Count the caller's view of a post, once per dedup window",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/posts/:id/dwell", Handler: fs.RecordDwell,
+            Summary:  "Report how long a post was on screen",
+            Auth:     true,
+            Body:     DwellRequest{},
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodGet, Path: "/posts/:id/html", Handler: fs.GetPostHTML,
+            Summary:  "A post's content rendered as sanitized HTML",
+            Response: RenderedPost{},
+            Statuses: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodPost, Path: "/blocks/:authorId", Handler: fs.BlockAuthor,
+            Summary:  "Hide an author's posts from the caller's feed and trending",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodDelete, Path: "/blocks/:authorId", Handler: fs.UnblockAuthor,
+            Summary:  "Unblock an author",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusGatewayTimeout},
+        },
+        {
+            Method: http.MethodPost, Path: "/tags/:tag/follow", Handler: fs.FollowTag,
+            Summary:  "Follow a hashtag",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodDelete, Path: "/tags/:tag/follow", Handler: fs.UnfollowTag,
+            Summary:  "Unfollow a hashtag",
+            Auth:     true,
+            Statuses: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodGet, Path: "/notifications/unread-count", Handler: fs.GetUnreadCount,
+            Summary:  "Unread notification count",
+            Auth:     true,
+            Statuses: []int{http.StatusUnauthorized, http.StatusInternalServerError},
+        },
+        {
+            Method: http.MethodPost, Path: "/notifications/read", Handler: fs.MarkNotificationsRead,
+            Summary:  "Mark all notifications read",
+            Auth:     true,
+            Statuses: []int{http.StatusUnauthorized, http.StatusInternalServerError},
+        },
+    }
+}
+
+// routeMiddleware holds the handlers that routeSpec flags opt routes into.
+type routeMiddleware struct {
+    Admin     gin.HandlerFunc
+    Internal  gin.HandlerFunc
+    RateLimit gin.HandlerFunc
+}
+
+func registerRoutes(group *gin.RouterGroup, routes []routeSpec, mw routeMiddleware) {
+    for _, route := range routes {
+        var handlers []gin.HandlerFunc
+        if route.Admin {
+            handlers = append(handlers, mw.Admin)
+        }
+        if route.Internal {
+            handlers = append(handlers, mw.Internal)
+        }
+        if route.Limited {
+            handlers = append(handlers, mw.RateLimit)
+        }
+        group.Handle(route.Method, route.Path, append(handlers, route.Handler)...)
+    }
+}
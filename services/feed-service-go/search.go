@@ -0,0 +1,143 @@
+package main
+
+import (
+    "context"
+    "crypto/sha1"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "net/http"
+    "regexp"
+    "strings"
+    "time"
+    "unicode/utf8"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    maxSearchQueryLength = 100
+
+    // MongoDB's IndexNotFound, returned for $text without a text index
+    errCodeIndexNotFound = 27
+)
+
+// contentTextIndex backs GET /search. A collection may have only one text
+// index, so it is created on its own: if the main app already defines a
+// different one this fails without holding back the other post indexes.
+var contentTextIndex = mongo.IndexModel{
+    Keys:    bson.D{{Key: "content", Value: "text"}},
+    Options: options.Index().SetName("feed_content_text"),
+}
+
+// normalizeSearchQuery lowercases and collapses whitespace, so queries that
+// differ only in case or spacing share a cache entry.
+func normalizeSearchQuery(q string) string {
+    return strings.Join(strings.Fields(strings.ToLower(q)), " ")
+}
+
+func searchCacheKey(q string, req FeedRequest) string {
+    sum := sha1.Sum([]byte(q))
+    return fmt.Sprintf("search:%s:page:%d:limit:%d", hex.EncodeToString(sum[:])[:16], req.Page, req.Limit)
+}
+
+// SearchPosts full-text searches active public posts, best matches first.
+// Results are the same for every caller and cached for SEARCH_CACHE_TTL.
+func (fs *FeedService) SearchPosts(c *gin.Context) {
+    q := normalizeSearchQuery(c.Query("q"))
+    if q == "" {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "q is required")
+        return
+    }
+    if utf8.RuneCountInString(q) > maxSearchQueryLength {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("q must be at most %d characters", maxSearchQueryLength))
+        return
+    }
+
+    var req FeedRequest
+    if !parsePageQuery(c, &req) || !fs.applyPageDefaults(c, &req) {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    cacheKey := searchCacheKey(q, req)
+    var page feedPage
+    cacheHit := false
+    if data, err := fs.cache.Get(ctx, cacheKey); err == nil && json.Unmarshal(data, &page) == nil {
+        cacheHit = true
+    }
+    fs.metrics.observeCacheLookup("search", cacheHit)
+
+    if !cacheHit {
+        var err error
+        page, err = fs.searchPostsInDB(ctx, q, req)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to search posts")
+            return
+        }
+        pageJSON, _ := json.Marshal(page)
+        fs.cache.Set(ctx, cacheKey, pageJSON, fs.searchCacheTTL)
+    }
+
+    fs.rewriteMediaURLs(page.Posts)
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":  true,
+        "query":    q,
+        "posts":    page.Posts,
+        "cacheHit": cacheHit,
+        "pagination": gin.H{
+            "page":    req.Page,
+            "limit":   req.Limit,
+            "hasMore": page.HasMore,
+        },
+    })
+}
+
+// searchPostsInDB ranks by text score, falling back to a case-insensitive
+// substring match, newest first, while the text index does not exist yet.
+func (fs *FeedService) searchPostsInDB(ctx context.Context, q string, req FeedRequest) (feedPage, error) {
+    defer fs.metrics.observeQuery("search", time.Now())
+    collection := fs.mongo.Database("crown-social").Collection("posts")
+
+    // Shared by every caller, so only what a stranger could see qualifies
+    filter := visibilityFilter(viewerRelationship{})
+    filter["isActive"] = true
+    filter["deletedAt"] = nil
+    filter["$text"] = bson.M{"$search": q}
+
+    score := bson.M{"$meta": "textScore"}
+    opts := options.Find().
+        SetProjection(bson.M{"score": score}).
+        SetSort(bson.D{{Key: "score", Value: score}, {Key: "createdAt", Value: -1}}).
+        SetSkip(int64((req.Page - 1) * req.Limit)).
+        SetLimit(int64(req.Limit + 1))
+
+    posts, err := findPosts(ctx, collection, filter, opts)
+    var serverErr mongo.ServerError
+    if err == nil {
+        return trimPage(posts, req.Limit), nil
+    }
+    if !errors.As(err, &serverErr) || !serverErr.HasErrorCode(errCodeIndexNotFound) {
+        return feedPage{}, err
+    }
+
+    log.Printf("⚠️ No text index on posts, falling back to regex search")
+    delete(filter, "$text")
+    filter["content"] = bson.M{"$regex": regexp.QuoteMeta(q), "$options": "i"}
+    opts = options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).
+        SetSkip(int64((req.Page - 1) * req.Limit)).
+        SetLimit(int64(req.Limit + 1))
+    posts, err = findPosts(ctx, collection, filter, opts)
+    if err != nil {
+        return feedPage{}, err
+    }
+    return trimPage(posts, req.Limit), nil
+}
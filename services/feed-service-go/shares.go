@@ -0,0 +1,206 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "unicode/utf8"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const postTypeShare = "share"
+
+// sharedKey marks that a user's share of a post was already counted.
+func sharedKey(postID, userID string) string {
+    return fmt.Sprintf("shared:%s:%s", postID, userID)
+}
+
+// SharePostRequest is the optional body of POST /posts/:id/share. With Repost
+// set, a share post referencing the original is created on the caller's
+// timeline, carrying Content as its comment.
+type SharePostRequest struct {
+    Repost     bool   `json:"repost,omitempty"`
+    Content    string `json:"content,omitempty"`
+    Visibility string `json:"visibility,omitempty"`
+}
+
+// SharePost counts the caller's share of a post, once per SHARE_DEDUP_WINDOW
+// so repeated shares do not inflate sharesCount, and pushes the new count
+// live. Trending reads sharesCount from the posts themselves, so it reflects
+// the share once its cached results expire.
+func (fs *FeedService) SharePost(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    var req SharePostRequest
+    if c.Request.ContentLength != 0 && !bindJSON(c, &req) {
+        return
+    }
+    if req.Repost {
+        if utf8.RuneCountInString(req.Content) > maxPostLength {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("Content is %d characters; the limit is %d", utf8.RuneCountInString(req.Content), maxPostLength))
+            return
+        }
+        if req.Visibility == "" {
+            req.Visibility = VisibilityPublic
+        }
+        switch req.Visibility {
+        case VisibilityPublic, VisibilityFriends, VisibilityCloseFriends, VisibilityPrivate:
+        default:
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid visibility")
+            return
+        }
+    }
+
+    original, ok := fs.loadVisiblePost(c, postID)
+    if !ok {
+        return
+    }
+    if req.Repost && original.Visibility != VisibilityPublic {
+        // A repost reaches the sharer's audience, which the original's may not cover
+        respondError(c, http.StatusForbidden, ErrCodeForbidden, "Only public posts can be reposted")
+        return
+    }
+    if original.SharedPost != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Share the original post instead")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    counted, err := fs.redis.SetNX(ctx, sharedKey(postID.Hex(), userID.Hex()), 1, fs.shareDedupWindow).Result()
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to share post")
+        return
+    }
+    if !counted {
+        if req.Repost {
+            respondError(c, http.StatusConflict, ErrCodeConflict, "You already shared this post recently")
+            return
+        }
+        respondJSON(c, http.StatusOK, gin.H{
+            "success":     true,
+            "postId":      postID.Hex(),
+            "counted":     false,
+            "sharesCount": original.SharesCount,
+        })
+        return
+    }
+
+    // Looked up before counting, so a failure only has the key to undo
+    var verified bool
+    if req.Repost {
+        if verified, err = fs.authorVerified(ctx, userID); err != nil {
+            fs.undoShare(c, postID, userID, false)
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to create share post")
+            return
+        }
+    }
+
+    var updated struct {
+        SharesCount int `bson:"sharesCount"`
+    }
+    err = fs.postsWriteCollection().FindOneAndUpdate(ctx,
+        bson.M{"_id": postID, "isActive": true, "deletedAt": nil},
+        bson.M{"$inc": bson.M{"sharesCount": 1}},
+        options.FindOneAndUpdate().
+            SetReturnDocument(options.After).
+            SetProjection(bson.M{"sharesCount": 1}),
+    ).Decode(&updated)
+    if err != nil {
+        fs.undoShare(c, postID, userID, false)
+        if err == mongo.ErrNoDocuments {
+            respondError(c, http.StatusNotFound, ErrCodeNotFound, "Post not found")
+            return
+        }
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to share post")
+        return
+    }
+
+    response := gin.H{
+        "success":     true,
+        "postId":      postID.Hex(),
+        "counted":     true,
+        "sharesCount": updated.SharesCount,
+    }
+    var share Post
+    if req.Repost {
+        now := fs.now()
+        share = Post{
+            ID:             primitive.NewObjectID(),
+            Author:         userID,
+            Content:        req.Content,
+            Type:           postTypeShare,
+            Visibility:     req.Visibility,
+            Media:          []MediaItem{},
+            Tags:           []string{},
+            Reactions:      map[string]int{},
+            SharedPost:     &postID,
+            AuthorVerified: verified,
+            IsActive:       true,
+            CreatedAt:      now,
+            UpdatedAt:      now,
+        }
+        if _, err := fs.postsWriteCollection().InsertOne(ctx, share); err != nil {
+            // Uncount the share too, or the retry would be refused as a repeat
+            fs.undoShare(c, postID, userID, true)
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to create share post")
+            return
+        }
+        response["post"] = share
+    }
+
+    fs.invalidatePost(ctx, postID)
+    fs.invalidateStats(ctx, original.Author)
+    fs.publishShares(ctx, original, updated.SharesCount)
+    if req.Repost {
+        fs.announcePost(ctx, share)
+    }
+
+    respondJSON(c, http.StatusOK, response)
+}
+
+// undoShare rolls back a share that failed part way: it clears the dedupe key
+// so the caller can retry and, with uncount, reverses the sharesCount
+// increment. It runs on a fresh deadline, since ctx may be what just expired.
+func (fs *FeedService) undoShare(c *gin.Context, postID, userID primitive.ObjectID, uncount bool) {
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    fs.redis.Del(ctx, sharedKey(postID.Hex(), userID.Hex()))
+    if uncount {
+        if _, err := fs.postsWriteCollection().UpdateOne(ctx,
+            bson.M{"_id": postID}, bson.M{"$inc": bson.M{"sharesCount": -1}}); err != nil {
+            log.Printf("Failed to uncount share of post %s: %v", postID.Hex(), err)
+        }
+    }
+}
+
+// publishShares pushes a post's new share count to its author and everyone
+// it was delivered to, as publishReactions does for likes.
+func (fs *FeedService) publishShares(ctx context.Context, post Post, sharesCount int) {
+    recipients, err := fs.postRecipients(ctx, post)
+    if err != nil {
+        log.Printf("Failed to resolve recipients for post %s: %v", post.ID.Hex(), err)
+    }
+    recipients = append(recipients, post.Author.Hex())
+
+    event := gin.H{"postId": post.ID.Hex(), "sharesCount": sharesCount}
+    if err := fs.publishEvent(ctx, recipients, "share_update", event); err != nil {
+        log.Printf("Failed to publish share_update event: %v", err)
+    }
+}
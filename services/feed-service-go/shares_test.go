@@ -0,0 +1,98 @@
+package main
+
+import (
+    "net/http"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestSharePostDeletedMeanwhile(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("share of a post deleted after loading is not counted", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        server := useMiniredis(t, fs)
+        sharer := primitive.NewObjectID()
+        post := editablePost(primitive.NewObjectID(), time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+        post.Visibility = VisibilityPublic
+
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, post)),
+            mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.close_friends", mtest.FirstBatch),
+            mtest.CreateSuccessResponse(bson.E{Key: "value", Value: nil}),
+        )
+        rec := serve(fs.SharePost, http.MethodPost, "/posts/:id/share", "/posts/"+post.ID.Hex()+"/share", sharer.Hex(), nil)
+        if rec.Code != http.StatusNotFound {
+            t.Fatalf("status = %d, want 404: %s", rec.Code, rec.Body)
+        }
+
+        var filter bson.Raw
+        for _, event := range mt.GetAllStartedEvents() {
+            if event.CommandName == "findAndModify" {
+                filter = event.Command.Lookup("query").Document()
+            }
+        }
+        if filter == nil {
+            t.Fatal("no findAndModify sent")
+        }
+        if active, ok := filter.Lookup("isActive").BooleanOK(); !ok || !active {
+            t.Errorf("filter %v does not require isActive", filter)
+        }
+        if deleted, err := filter.LookupErr("deletedAt"); err != nil || deleted.Type != bson.TypeNull {
+            t.Errorf("filter %v does not require deletedAt: null", filter)
+        }
+        if server.Exists(sharedKey(post.ID.Hex(), sharer.Hex())) {
+            t.Error("dedupe key kept for an uncounted share")
+        }
+    })
+}
+
+func TestSharePostRepostFailureRollsBack(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    mt.Run("failed repost uncounts the share", func(mt *mtest.T) {
+        fs := newTestService(t, mt.Client)
+        server := useMiniredis(t, fs)
+        sharer := primitive.NewObjectID()
+        post := editablePost(primitive.NewObjectID(), time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+        post.Visibility = VisibilityPublic
+
+        mt.AddMockResponses(
+            mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, mockDoc(t, post)),
+            mtest.CreateCursorResponse(0, "crown-social.friends", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.close_friends", mtest.FirstBatch),
+            mtest.CreateCursorResponse(0, "crown-social.users", mtest.FirstBatch),
+            mtest.CreateSuccessResponse(bson.E{Key: "value", Value: bson.D{{Key: "_id", Value: post.ID}, {Key: "sharesCount", Value: 1}}}),
+            mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 91, Message: "shutting down"}),
+            mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+        )
+        rec := serve(fs.SharePost, http.MethodPost, "/posts/:id/share", "/posts/"+post.ID.Hex()+"/share", sharer.Hex(),
+            SharePostRequest{Repost: true, Content: "look"})
+        if rec.Code != http.StatusInternalServerError {
+            mt.Fatalf("status = %d, want 500: %s", rec.Code, rec.Body)
+        }
+
+        var uncounted bool
+        for _, event := range mt.GetAllStartedEvents() {
+            if event.CommandName != "update" {
+                continue
+            }
+            update := event.Command.Lookup("updates").Array().Index(0).Value().Document()
+            uncounted = update.Lookup("u", "$inc", "sharesCount").Int32() == -1 &&
+                update.Lookup("q", "_id").ObjectID() == post.ID
+        }
+        if !uncounted {
+            mt.Error("sharesCount increment not reversed")
+        }
+        if server.Exists(sharedKey(post.ID.Hex(), sharer.Hex())) {
+            mt.Error("dedupe key kept, so the retry would be refused")
+        }
+    })
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+    "context"
+    "log"
+    "net/http"
+    "time"
+
+    "github.com/gorilla/websocket"
+)
+
+// closeWebSocket sends a close frame before the connection is torn down, so
+// clients can tell a deliberate close from a network failure.
+func (fs *FeedService) closeWebSocket(conn *websocket.Conn, code int, reason string) {
+    closeMsg := websocket.FormatCloseMessage(code, reason)
+    conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+}
+
+// shutdown stops accepting requests, lets in-flight ones finish, closes open
+// WebSockets with CloseGoingAway and then releases the Mongo and Redis
+// clients, all within timeout.
+func (fs *FeedService) shutdown(timeout time.Duration, server *http.Server) {
+    log.Printf("Shutting down, draining for up to %s", timeout)
+    ctx, cancel := context.WithTimeout(context.Background(), timeout)
+    defer cancel()
+
+    close(fs.shuttingDown)
+    if err := server.Shutdown(ctx); err != nil {
+        log.Printf("HTTP drain incomplete: %v", err)
+    }
+
+    wsDrained := make(chan struct{})
+    go func() {
+        fs.wsConns.Wait()
+        close(wsDrained)
+    }()
+    select {
+    case <-wsDrained:
+    case <-ctx.Done():
+        log.Printf("WebSocket drain incomplete, closing remaining connections")
+    }
+
+    if err := fs.mongo.Disconnect(ctx); err != nil {
+        log.Printf("Mongo disconnect failed: %v", err)
+    }
+    if err := fs.redis.Close(); err != nil {
+        log.Printf("Redis close failed: %v", err)
+    }
+    log.Printf("Shutdown complete")
+}
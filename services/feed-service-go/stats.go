@@ -0,0 +1,112 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UserStats is the response of GET /users/:userId/stats.
+type UserStats struct {
+    Success       bool   `json:"success"`
+    UserID        string `json:"userId"`
+    PostsCount    int64  `json:"postsCount"`
+    LikesCount    int64  `json:"likesCount"`
+    CommentsCount int64  `json:"commentsCount"`
+    SharesCount   int64  `json:"sharesCount"`
+    ViewsCount    int64  `json:"viewsCount"`
+    CacheHit      bool   `json:"cacheHit"`
+}
+
+func statsCacheKey(userID primitive.ObjectID) string {
+    return "stats:" + userID.Hex()
+}
+
+// invalidateStats drops an author's cached stats. Creating, deleting and
+// reacting to posts call it; comment and view counts catch up within
+// USER_STATS_CACHE_TTL.
+func (fs *FeedService) invalidateStats(ctx context.Context, authorID primitive.ObjectID) {
+    fs.cache.Del(ctx, statsCacheKey(authorID))
+}
+
+// GetUserStats sums the engagement counters over an author's live posts for
+// the profile page. An author with no posts gets zeros, not a 404.
+func (fs *FeedService) GetUserStats(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(c.Param("userId"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid user id")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    key := statsCacheKey(userID)
+    if cached, err := fs.cache.Get(ctx, key); err == nil {
+        var stats UserStats
+        if json.Unmarshal(cached, &stats) == nil {
+            fs.metrics.observeCacheLookup("user_stats", true)
+            stats.CacheHit = true
+            respondJSON(c, http.StatusOK, stats)
+            return
+        }
+    }
+    fs.metrics.observeCacheLookup("user_stats", false)
+
+    stats, err := fs.aggregateUserStats(ctx, userID)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFetchFailed, "Failed to fetch user stats")
+        return
+    }
+    if data, err := json.Marshal(stats); err == nil {
+        fs.cache.Set(ctx, key, data, fs.userStatsTTL)
+    }
+
+    respondJSON(c, http.StatusOK, stats)
+}
+
+func (fs *FeedService) aggregateUserStats(ctx context.Context, userID primitive.ObjectID) (UserStats, error) {
+    defer fs.metrics.observeQuery("user_stats", time.Now())
+
+    pipeline := []bson.M{
+        {"$match": bson.M{"author": userID, "isActive": true, "deletedAt": nil}},
+        {"$group": bson.M{
+            "_id":           nil,
+            "postsCount":    bson.M{"$sum": 1},
+            "likesCount":    bson.M{"$sum": "$likesCount"},
+            "commentsCount": bson.M{"$sum": "$commentsCount"},
+            "sharesCount":   bson.M{"$sum": "$sharesCount"},
+            "viewsCount":    bson.M{"$sum": "$viewsCount"},
+        }},
+    }
+    cursor, err := fs.mongo.Database("crown-social").Collection("posts").Aggregate(ctx, pipeline)
+    if err != nil {
+        return UserStats{}, err
+    }
+    var totals []struct {
+        PostsCount    int64 `bson:"postsCount"`
+        LikesCount    int64 `bson:"likesCount"`
+        CommentsCount int64 `bson:"commentsCount"`
+        SharesCount   int64 `bson:"sharesCount"`
+        ViewsCount    int64 `bson:"viewsCount"`
+    }
+    if err := cursor.All(ctx, &totals); err != nil {
+        return UserStats{}, err
+    }
+
+    // $group emits nothing when no post matched, which is all zeros
+    stats := UserStats{Success: true, UserID: userID.Hex()}
+    if len(totals) > 0 {
+        stats.PostsCount = totals[0].PostsCount
+        stats.LikesCount = totals[0].LikesCount
+        stats.CommentsCount = totals[0].CommentsCount
+        stats.SharesCount = totals[0].SharesCount
+        stats.ViewsCount = totals[0].ViewsCount
+    }
+    return stats, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+    "context"
+    "crypto/sha1"
+    "encoding/hex"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FeedModeTags limits the feed to posts carrying a hashtag the user follows.
+const FeedModeTags = "tags"
+
+// followedTags is one document per user in the followed_tags collection.
+type followedTags struct {
+    User      primitive.ObjectID `bson:"_id"`
+    Tags      []string           `bson:"tags"`
+    UpdatedAt time.Time          `bson:"updatedAt"`
+}
+
+// normalizeTag lowercases a hashtag and strips a leading '#', so "#Crown" and
+// "crown" are the same tag.
+func normalizeTag(tag string) string {
+    return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+func (fs *FeedService) followedTagsCollection() *mongo.Collection {
+    return fs.mongo.Database("crown-social").Collection("followed_tags")
+}
+
+// fetchFollowedTags returns the user's followed tags, sorted.
+func (fs *FeedService) fetchFollowedTags(ctx context.Context, userID primitive.ObjectID) ([]string, error) {
+    var doc followedTags
+    err := fs.followedTagsCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&doc)
+    if err == mongo.ErrNoDocuments {
+        return nil, nil
+    }
+    if err != nil {
+        return nil, err
+    }
+    sort.Strings(doc.Tags)
+    return doc.Tags, nil
+}
+
+// tagSetHash identifies a followed-tag set in feed cache keys, so following
+// or unfollowing a tag naturally misses the old cached pages.
+func tagSetHash(tags []string) string {
+    sum := sha1.Sum([]byte(strings.Join(tags, ",")))
+    return hex.EncodeToString(sum[:])[:12]
+}
+
+func containsString(values []string, value string) bool {
+    for _, v := range values {
+        if v == value {
+            return true
+        }
+    }
+    return false
+}
+
+func (fs *FeedService) FollowTag(c *gin.Context) {
+    fs.updateFollowedTag(c, true)
+}
+
+func (fs *FeedService) UnfollowTag(c *gin.Context) {
+    fs.updateFollowedTag(c, false)
+}
+
+func (fs *FeedService) updateFollowedTag(c *gin.Context, follow bool) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    tag := normalizeTag(c.Param("tag"))
+    if tag == "" {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tag")
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    update := bson.M{
+        "$pull": bson.M{"tags": tag},
+        "$set":  bson.M{"updatedAt": time.Now()},
+    }
+    if follow {
+        current, err := fs.fetchFollowedTags(ctx, userID)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update followed tags")
+            return
+        }
+        if len(current) >= fs.maxFollowedTags && !containsString(current, tag) {
+            respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, fmt.Sprintf("At most %d followed tags allowed", fs.maxFollowedTags))
+            return
+        }
+        update = bson.M{
+            "$addToSet": bson.M{"tags": tag},
+            "$set":      bson.M{"updatedAt": time.Now()},
+        }
+    }
+
+    _, err = fs.followedTagsCollection().UpdateOne(ctx, bson.M{"_id": userID}, update, options.Update().SetUpsert(true))
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to update followed tags")
+        return
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":   true,
+        "tag":       tag,
+        "following": follow,
+    })
+}
+
+// tagFeedKeyPattern matches every cached page of one tag's feed.
+func tagFeedKeyPattern(tag string) string {
+    return fmt.Sprintf("tag:%s:*", tag)
+}
+
+// tagFeedCacheKey is one cached page of a tag's public feed.
+func tagFeedCacheKey(tag string, req FeedRequest) string {
+    key := fmt.Sprintf("tag:%s:page:%d:limit:%d", tag, req.Page, req.Limit)
+    if req.After != nil {
+        key += fmt.Sprintf(":after:%d:%s", req.After.CreatedAt.UnixNano(), req.After.ID.Hex())
+    }
+    return key
+}
+
+// GetTagFeed serves active public posts carrying a hashtag, newest first, with
+// the same page/cursor pagination as the main feed. Pages are the same for
+// every viewer, so they are cached per tag rather than per user.
+func (fs *FeedService) GetTagFeed(c *gin.Context) {
+    tag := normalizeTag(c.Param("tag"))
+    if tag == "" {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid tag")
+        return
+    }
+
+    req := FeedRequest{Cursor: c.Query("cursor")}
+    if !parsePageQuery(c, &req) || !fs.applyPageDefaults(c, &req) || !fs.applyFeedCursor(c, &req) {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    cacheKey := tagFeedCacheKey(tag, req)
+    if data, err := fs.cache.Get(ctx, cacheKey); err == nil {
+        var page feedPage
+        if json.Unmarshal(data, &page) == nil {
+            fs.metrics.observeCacheLookup("tag", true)
+            fs.rewriteMediaURLs(page.Posts)
+            respondJSON(c, http.StatusOK, fs.newFeedResponse(req, feedResult{Posts: page.Posts, HasMore: page.HasMore, CacheHit: true}))
+            return
+        }
+    }
+    fs.metrics.observeCacheLookup("tag", false)
+
+    page, err := fs.fetchTagFeedFromDB(ctx, tag, req)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch tag feed")
+        return
+    }
+    pageJSON, _ := json.Marshal(page)
+    fs.cache.Set(ctx, cacheKey, pageJSON, fs.feedCacheTTL)
+
+    fs.rewriteMediaURLs(page.Posts)
+    respondJSON(c, http.StatusOK, fs.newFeedResponse(req, feedResult{Posts: page.Posts, HasMore: page.HasMore}))
+}
+
+func (fs *FeedService) fetchTagFeedFromDB(ctx context.Context, tag string, req FeedRequest) (feedPage, error) {
+    defer fs.metrics.observeQuery("tag", time.Now())
+    collection := fs.mongo.Database("crown-social").Collection("posts")
+
+    // Shared by every viewer, so only what a stranger could see qualifies
+    filter := visibilityFilter(viewerRelationship{})
+    filter["isActive"] = true
+    filter["deletedAt"] = nil
+    filter["tags"] = tag
+    return findPostsPage(ctx, collection, filter, req)
+}
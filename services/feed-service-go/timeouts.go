@@ -0,0 +1,21 @@
+package main
+
+import (
+    "context"
+    "errors"
+
+    "go.mongodb.org/mongo-driver/mongo"
+)
+
+// opContext derives the deadline (DB_OP_TIMEOUT) for the Mongo and Redis work
+// done on behalf of one request or job. Pass the Gin request context so a
+// client disconnect also cancels the work.
+func (fs *FeedService) opContext(parent context.Context) (context.Context, context.CancelFunc) {
+    return context.WithTimeout(parent, fs.opTimeout)
+}
+
+// isTimeout reports whether err comes from an exceeded deadline, whether
+// surfaced by the context or wrapped by the Mongo driver.
+func isTimeout(err error) bool {
+    return errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+    "net/http"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+// TestHandlersTimeOut checks that request handlers run their Mongo and Redis
+// work under DB_OP_TIMEOUT and answer 504 when it expires.
+func TestHandlersTimeOut(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    postPath := "/posts/" + primitive.NewObjectID().Hex()
+    tests := []struct {
+        name    string
+        handler func(fs *FeedService) gin.HandlerFunc
+        method  string
+        route   string
+        target  string
+        body    interface{}
+    }{
+        {"comments", func(fs *FeedService) gin.HandlerFunc { return fs.GetComments }, http.MethodGet, "/posts/:id/comments", postPath + "/comments", nil},
+        {"create comment", func(fs *FeedService) gin.HandlerFunc { return fs.CreateComment }, http.MethodPost, "/posts/:id/comments", postPath + "/comments", CreateCommentRequest{Content: "hi"}},
+        {"post html", func(fs *FeedService) gin.HandlerFunc { return fs.GetPostHTML }, http.MethodGet, "/posts/:id/html", postPath + "/html", nil},
+        {"dwell", func(fs *FeedService) gin.HandlerFunc { return fs.RecordDwell }, http.MethodPost, "/posts/:id/dwell", postPath + "/dwell", DwellRequest{Ms: 1000}},
+        {"follow tag", func(fs *FeedService) gin.HandlerFunc { return fs.FollowTag }, http.MethodPost, "/tags/:tag/follow", "/tags/go/follow", nil},
+        {"close friends", func(fs *FeedService) gin.HandlerFunc { return fs.UpdateCloseFriends }, http.MethodPut, "/close-friends", "/close-friends", CloseFriendsRequest{}},
+        {"unread count", func(fs *FeedService) gin.HandlerFunc { return fs.GetUnreadCount }, http.MethodGet, "/notifications/unread-count", "/notifications/unread-count", nil},
+        {"mark read", func(fs *FeedService) gin.HandlerFunc { return fs.MarkNotificationsRead }, http.MethodPost, "/notifications/read", "/notifications/read", nil},
+    }
+    for _, tt := range tests {
+        mt.Run(tt.name, func(mt *mtest.T) {
+            fs := newTestService(t, mt.Client)
+            useMiniredis(t, fs)
+            fs.opTimeout = time.Nanosecond
+            fs.dwellMinMs, fs.dwellMaxMs = 1, 60000
+
+            rec := serve(tt.handler(fs), tt.method, tt.route, tt.target, primitive.NewObjectID().Hex(), tt.body)
+            if rec.Code != http.StatusGatewayTimeout {
+                mt.Fatalf("status = %d, want 504: %s", rec.Code, rec.Body)
+            }
+        })
+    }
+}
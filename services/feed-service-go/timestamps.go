@@ -0,0 +1,91 @@
+package main
+
+import (
+    "encoding/json"
+    "time"
+)
+
+// jsonTimeLayout is RFC 3339 with exactly three fractional digits, the
+// precision Mongo stores. Fixed width keeps timestamps comparable as strings.
+const jsonTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// jsonTime serializes a time in UTC with jsonTimeLayout. Go's default output
+// carries whatever zone and precision the value happens to have, so a post
+// fresh from fs.now() and the same post read back from Mongo or the cache
+// used to render differently.
+type jsonTime time.Time
+
+func (t jsonTime) MarshalJSON() ([]byte, error) {
+    return json.Marshal(time.Time(t).UTC().Format(jsonTimeLayout))
+}
+
+func (t *jsonTime) UnmarshalJSON(data []byte) error {
+    return (*time.Time)(t).UnmarshalJSON(data)
+}
+
+// optionalJSONTime is jsonTime for pointer fields, keeping nil as nil so
+// omitempty still applies.
+func optionalJSONTime(t *time.Time) *jsonTime {
+    if t == nil {
+        return nil
+    }
+    converted := jsonTime(*t)
+    return &converted
+}
+
+// The MarshalJSON methods below shadow each time field with its jsonTime
+// form; encoding/json prefers the shallower field of the same name.
+
+func (p Post) MarshalJSON() ([]byte, error) {
+    type plain Post
+    return json.Marshal(struct {
+        plain
+        CreatedAt     jsonTime  `json:"createdAt"`
+        UpdatedAt     jsonTime  `json:"updatedAt"`
+        PromotedUntil *jsonTime `json:"promotedUntil,omitempty"`
+        EditedAt      *jsonTime `json:"editedAt,omitempty"`
+        DeletedAt     *jsonTime `json:"deletedAt,omitempty"`
+    }{
+        plain:         plain(p),
+        CreatedAt:     jsonTime(p.CreatedAt),
+        UpdatedAt:     jsonTime(p.UpdatedAt),
+        PromotedUntil: optionalJSONTime(p.PromotedUntil),
+        EditedAt:      optionalJSONTime(p.EditedAt),
+        DeletedAt:     optionalJSONTime(p.DeletedAt),
+    })
+}
+
+func (c Comment) MarshalJSON() ([]byte, error) {
+    type plain Comment
+    return json.Marshal(struct {
+        plain
+        CreatedAt jsonTime `json:"createdAt"`
+        UpdatedAt jsonTime `json:"updatedAt"`
+    }{
+        plain:     plain(c),
+        CreatedAt: jsonTime(c.CreatedAt),
+        UpdatedAt: jsonTime(c.UpdatedAt),
+    })
+}
+
+func (b Bookmark) MarshalJSON() ([]byte, error) {
+    type plain Bookmark
+    return json.Marshal(struct {
+        plain
+        CreatedAt jsonTime `json:"createdAt"`
+    }{
+        plain:     plain(b),
+        CreatedAt: jsonTime(b.CreatedAt),
+    })
+}
+
+func (e FeedEvent) MarshalJSON() ([]byte, error) {
+    type plain FeedEvent
+    return json.Marshal(struct {
+        plain
+        Timestamp jsonTime `json:"timestamp"`
+    }{
+        plain:     plain(e),
+        Timestamp: jsonTime(e.Timestamp),
+    })
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "log"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+
+    "crown-feed-service/cursor"
+)
+
+// TrendingWeights are the per-engagement multipliers in the trending score,
+// plus the gravity with which that score decays as the post ages.
+type TrendingWeights struct {
+    Likes    float64 // applies to reactions of every type
+    Comments float64
+    Shares   float64
+    Views    float64
+    Gravity  float64
+}
+
+// loadTrendingWeights reads TREND_WEIGHT_{LIKES,COMMENTS,SHARES,VIEWS} and
+// TREND_GRAVITY, keeping the default for any value that is missing, malformed or negative.
+func loadTrendingWeights() TrendingWeights {
+    weight := func(key string, defaultValue float64) float64 {
+        value := getEnvFloat(key, defaultValue)
+        if value < 0 {
+            log.Printf("⚠️ %s must not be negative, using %g", key, defaultValue)
+            return defaultValue
+        }
+        return value
+    }
+    return TrendingWeights{
+        Likes:    weight("TREND_WEIGHT_LIKES", 1),
+        Comments: weight("TREND_WEIGHT_COMMENTS", 2),
+        Shares:   weight("TREND_WEIGHT_SHARES", 3),
+        Views:    weight("TREND_WEIGHT_VIEWS", 0.1),
+        Gravity:  weight("TREND_GRAVITY", 1.8),
+    }
+}
+
+// ageHoursExpr is the aggregation expression for hours since createdAt as of
+// now, floored at zero so a clock-skewed future post gets no extra boost.
+func ageHoursExpr(now time.Time) bson.M {
+    return bson.M{
+        "$max": []interface{}{
+            0,
+            bson.M{"$divide": []interface{}{
+                bson.M{"$subtract": []interface{}{now, "$createdAt"}},
+                float64(time.Hour / time.Millisecond),
+            }},
+        },
+    }
+}
+
+// reactionsTotalExpr sums the per-type reaction counters. Posts liked before
+// reactions existed only have likesCount, which is itself the running total,
+// so the larger of the two is used.
+var reactionsTotalExpr = bson.M{
+    "$max": []interface{}{
+        "$likesCount",
+        bson.M{"$sum": bson.M{"$map": bson.M{
+            "input": bson.M{"$objectToArray": bson.M{"$ifNull": []interface{}{"$reactions", bson.M{}}}},
+            "in":    "$$this.v",
+        }}},
+    },
+}
+
+// scoreExpr is the aggregation expression computing the decayed score, in the
+// Hacker News style engagement/(ageHours+2)^gravity. It reads the ageHours
+// field, so it must run in a stage after ageHoursExpr has been added. With the
+// default gravity a 1h-old post with 50 likes (≈6.9) outranks a 23h-old one
+// with 500 (≈1.5).
+func (w TrendingWeights) scoreExpr() bson.M {
+    engagement := bson.M{
+        "$add": []bson.M{
+            {"$multiply": []interface{}{reactionsTotalExpr, w.Likes}},
+            {"$multiply": []interface{}{"$commentsCount", w.Comments}},
+            {"$multiply": []interface{}{"$sharesCount", w.Shares}},
+            {"$multiply": []interface{}{"$viewsCount", w.Views}},
+        },
+    }
+    return bson.M{
+        "$divide": []interface{}{
+            engagement,
+            bson.M{"$pow": []interface{}{bson.M{"$add": []interface{}{"$ageHours", 2}}, w.Gravity}},
+        },
+    }
+}
+
+// Formula describes the score for clients explaining why a post trends.
+func (w TrendingWeights) Formula() string {
+    return fmt.Sprintf("(reactions*%g + comments*%g + shares*%g + views*%g) / (ageHours+2)^%g",
+        w.Likes, w.Comments, w.Shares, w.Views, w.Gravity)
+}
+
+// staleTrendingPrefix prefixes the long-lived shadow copy of each trending
+// result, served when the aggregation itself fails.
+const staleTrendingPrefix = "stale:"
+
+// cacheTrending stores a trending page under its regular key and refreshes
+// the shadow copy used as a stale fallback.
+func (fs *FeedService) cacheTrending(ctx context.Context, cacheKey string, page feedPage) {
+    pageJSON, _ := json.Marshal(page)
+    fs.cache.Set(ctx, cacheKey, pageJSON, fs.trendingCacheTTL)
+    fs.cache.Set(ctx, staleTrendingPrefix+cacheKey, pageJSON, fs.trendingStaleTTL)
+}
+
+// staleTrending returns the shadow copy of a trending page, if any.
+func (fs *FeedService) staleTrending(ctx context.Context, cacheKey string) (feedPage, bool) {
+    data, err := fs.cache.Get(ctx, staleTrendingPrefix+cacheKey)
+    if err != nil {
+        return feedPage{}, false
+    }
+    var page feedPage
+    if json.Unmarshal(data, &page) != nil {
+        return feedPage{}, false
+    }
+    return page, true
+}
+
+// trendingAfterFilter selects posts ranked strictly below the cursor in
+// (trendingScore desc, _id desc) order. It runs after the score is computed,
+// against the current scores rather than those the cursor was taken from.
+func trendingAfterFilter(after cursor.Fields) bson.M {
+    return bson.M{"$or": []bson.M{
+        {"trendingScore": bson.M{"$lt": *after.Score}},
+        {"trendingScore": *after.Score, "_id": bson.M{"$lt": after.ID}},
+    }}
+}
+
+// trendingPagination is the pagination block of a trending response, shaped
+// like the feed's. The cursor is taken from the page before viewer filtering,
+// so a hidden last post does not pull the next page back over this one.
+func (fs *FeedService) trendingPagination(query TrendingQuery, page feedPage) gin.H {
+    pagination := gin.H{
+        "page":    query.Page,
+        "limit":   query.Limit,
+        "hasMore": page.HasMore,
+    }
+    if page.HasMore && len(page.Posts) > 0 {
+        last := page.Posts[len(page.Posts)-1]
+        score := last.TrendingScore
+        pagination["nextCursor"] = fs.cursors.Encode(cursor.Fields{CreatedAt: last.CreatedAt, ID: last.ID, Score: &score})
+    }
+    return pagination
+}
+
+// refreshTrendingAsync retries the aggregation in the background after a stale
+// response was served. At most one refresh per cache key runs at a time.
+func (fs *FeedService) refreshTrendingAsync(query TrendingQuery) {
+    cacheKey := query.cacheKey()
+    if _, running := fs.trendingRefreshing.LoadOrStore(cacheKey, true); running {
+        return
+    }
+
+    go func() {
+        defer fs.trendingRefreshing.Delete(cacheKey)
+
+        ctx, cancel := fs.opContext(context.Background())
+        defer cancel()
+
+        page, err := fs.fetchTrendingFromDB(ctx, query)
+        if err != nil {
+            log.Printf("Trending refresh failed for %s: %v", cacheKey, err)
+            return
+        }
+        fs.cacheTrending(ctx, cacheKey, page)
+    }()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo/integration/mtest"
+
+    "crown-feed-service/cursor"
+)
+
+func TestAggregationLimit(t *testing.T) {
+    fs := &FeedService{maxAggregationResults: 100}
+    for _, tc := range []struct{ in, want int }{
+        {0, 0}, {20, 20}, {100, 100}, {101, 100}, {1 << 30, 100},
+    } {
+        if got := fs.aggregationLimit(tc.in); got != tc.want {
+            t.Errorf("aggregationLimit(%d) = %d, want %d", tc.in, got, tc.want)
+        }
+    }
+}
+
+// trendingDocs returns n ranked posts as mock cursor documents.
+func trendingDocs(t *testing.T, n int) []bson.D {
+    docs := make([]bson.D, n)
+    for i := range docs {
+        docs[i] = mockDoc(t, Post{
+            ID:            primitive.NewObjectID(),
+            Author:        primitive.NewObjectID(),
+            Content:       fmt.Sprintf("trending %d", i),
+            Visibility:    VisibilityPublic,
+            IsActive:      true,
+            TrendingScore: float64(1000 - i),
+            CreatedAt:     time.Now(),
+            UpdatedAt:     time.Now(),
+        })
+    }
+    return docs
+}
+
+// sentStages returns the $skip and $limit of the aggregation mt sent, or -1
+// each when no aggregate went out.
+func sentStages(mt *mtest.T) (skip, limit int64) {
+    skip, limit = -1, -1
+    for _, event := range mt.GetAllStartedEvents() {
+        if event.CommandName != "aggregate" {
+            continue
+        }
+        stages, _ := event.Command.Lookup("pipeline").Array().Values()
+        for _, stage := range stages {
+            doc := stage.Document()
+            if v, err := doc.LookupErr("$skip"); err == nil {
+                skip = v.AsInt64()
+            }
+            if v, err := doc.LookupErr("$limit"); err == nil {
+                limit = v.AsInt64()
+            }
+        }
+    }
+    return skip, limit
+}
+
+type trendingBody struct {
+    Posts        []Post `json:"posts"`
+    LimitClamped bool   `json:"limitClamped"`
+    Pagination   struct {
+        Page       int    `json:"page"`
+        Limit      int    `json:"limit"`
+        HasMore    bool   `json:"hasMore"`
+        NextCursor string `json:"nextCursor"`
+    } `json:"pagination"`
+}
+
+func TestGetTrendingPostsClampsLimitAndPage(t *testing.T) {
+    mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+    defer mt.Close()
+
+    cases := []struct {
+        name        string
+        query       string
+        returned    int // documents the mock aggregation yields
+        wantSkip    int64
+        wantLimit   int64 // $limit sent, one past the page to detect hasMore
+        wantPage    int
+        wantSize    int
+        wantMore    bool
+        wantClamped bool
+    }{
+        {name: "zero limit takes the default", query: "limit=0", returned: 21, wantSkip: 0, wantLimit: 21, wantPage: 1, wantSize: 20, wantMore: true},
+        {name: "omitted limit takes the default", query: "", returned: 3, wantSkip: 0, wantLimit: 21, wantPage: 1, wantSize: 3},
+        {name: "limit above MAX_FEED_LIMIT is clamped", query: "limit=500", returned: 51, wantSkip: 0, wantLimit: 51, wantPage: 1, wantSize: 50, wantMore: true, wantClamped: true},
+        {name: "zero page is the first", query: "limit=10&page=0", returned: 11, wantSkip: 0, wantLimit: 11, wantPage: 1, wantSize: 10, wantMore: true},
+        {name: "second page skips the first", query: "limit=10&page=2", returned: 11, wantSkip: 10, wantLimit: 11, wantPage: 2, wantSize: 10, wantMore: true},
+        {name: "last page under the cap has no more", query: "limit=50&page=2", returned: 51, wantSkip: 50, wantLimit: 51, wantPage: 2, wantSize: 50},
+        {name: "page straddling the cap is cut short", query: "limit=30&page=4", returned: 11, wantSkip: 90, wantLimit: 11, wantPage: 4, wantSize: 10},
+        {name: "page past the cap skips the aggregation", query: "limit=50&page=3", wantSkip: -1, wantLimit: -1, wantPage: 3},
+        {name: "huge page does not overflow", query: "limit=50&page=9223372036854775807", wantSkip: -1, wantLimit: -1, wantPage: 9223372036854775807},
+    }
+    for _, tc := range cases {
+        mt.Run(tc.name, func(mt *mtest.T) {
+            fs := newTestService(t, mt.Client)
+            if tc.returned > 0 {
+                mt.AddMockResponses(mtest.CreateCursorResponse(0, "crown-social.posts", mtest.FirstBatch, trendingDocs(t, tc.returned)...))
+            }
+
+            rec := serve(fs.GetTrendingPosts, http.MethodGet, "/trending", "/trending?"+tc.query, "", nil)
+            if rec.Code != http.StatusOK {
+                t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+            }
+            var body trendingBody
+            decodeBody(t, rec, &body)
+
+            skip, limit := sentStages(mt)
+            if skip != tc.wantSkip || limit != tc.wantLimit {
+                t.Errorf("sent $skip %d $limit %d, want %d and %d", skip, limit, tc.wantSkip, tc.wantLimit)
+            }
+            if len(body.Posts) != tc.wantSize || body.Pagination.HasMore != tc.wantMore {
+                t.Errorf("got %d posts, hasMore %v; want %d, %v", len(body.Posts), body.Pagination.HasMore, tc.wantSize, tc.wantMore)
+            }
+            if body.Pagination.Page != tc.wantPage || body.LimitClamped != tc.wantClamped {
+                t.Errorf("page %d, limitClamped %v; want %d, %v", body.Pagination.Page, body.LimitClamped, tc.wantPage, tc.wantClamped)
+            }
+            if body.Pagination.HasMore == (body.Pagination.NextCursor == "") {
+                t.Errorf("nextCursor %q does not match hasMore %v", body.Pagination.NextCursor, body.Pagination.HasMore)
+            }
+        })
+    }
+}
+
+func TestGetTrendingPostsRejectsBadInput(t *testing.T) {
+    fs := newTestService(t, nil)
+    // A feed cursor carries no score
+    feedCursor := fs.cursors.Encode(cursor.Fields{CreatedAt: time.Now(), ID: primitive.NewObjectID()})
+    for _, query := range []string{"limit=-1", "limit=abc", "page=-1", "page=abc", "cursor=garbage", "cursor=" + feedCursor} {
+        rec := serve(fs.GetTrendingPosts, http.MethodGet, "/trending", "/trending?"+query, "", nil)
+        if rec.Code != http.StatusBadRequest {
+            t.Errorf("%s: status = %d, want 400", query, rec.Code)
+        }
+    }
+}
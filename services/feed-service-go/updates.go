@@ -0,0 +1,80 @@
+package main
+
+import (
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetFeedUpdates serves polling clients that cannot hold a WebSocket: the
+// caller's feed posts created after ?since, oldest first, straight from Mongo
+// and never through the page cache.
+//
+// The response's nextSince is what to pass next time. It is the server's
+// clock at query time, so client clock skew cannot open a gap. When the
+// result was capped, it is the newest post returned instead, and the client
+// should poll again at once for the rest.
+func (fs *FeedService) GetFeedUpdates(c *gin.Context) {
+    userID, err := primitive.ObjectIDFromHex(requestUserID(c))
+    if err != nil {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    since, err := time.Parse(time.RFC3339, c.Query("since"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "since must be an RFC 3339 timestamp")
+        return
+    }
+
+    req := FeedRequest{UserID: userID.Hex()}
+    if !parsePageQuery(c, &req) || !fs.applyPageDefaults(c, &req) {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+    defer fs.metrics.observeQuery("feed_updates", time.Now())
+
+    // Taken before the query, so a post created while it runs is picked up
+    // by the next poll rather than skipped
+    now := fs.now()
+
+    if req.Blocked, err = fs.blockedAuthorIDs(ctx, userID); err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch feed updates")
+        return
+    }
+    query, err := fs.feedFilter(ctx, req)
+    if err != nil {
+        respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch feed updates")
+        return
+    }
+
+    page := feedPage{Posts: []Post{}}
+    if query.Filter != nil {
+        query.Filter["createdAt"] = bson.M{"$gt": since}
+        oldestFirst := bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}
+        posts, err := query.find(ctx, fs.mongo.Database("crown-social").Collection("posts"), oldestFirst, 0, req.Limit+1)
+        if err != nil {
+            respondFetchError(c, err, ErrCodeFeedFetchFailed, "Failed to fetch feed updates")
+            return
+        }
+        page = trimPage(posts, req.Limit)
+    }
+
+    nextSince := now
+    if page.HasMore {
+        nextSince = page.Posts[len(page.Posts)-1].CreatedAt
+    }
+    fs.rewriteMediaURLs(page.Posts)
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":    true,
+        "posts":      page.Posts,
+        "hasMore":    page.HasMore,
+        "serverTime": jsonTime(now),
+        "nextSince":  jsonTime(nextSince),
+    })
+}
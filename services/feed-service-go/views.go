@@ -0,0 +1,141 @@
+package main
+
+import (
+    "context"
+    "fmt"
+    "log"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/go-redis/redis/v8"
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+    "go.mongodb.org/mongo-driver/mongo"
+)
+
+// pendingViewsKey is a hash of postId -> views not yet written to Mongo.
+const pendingViewsKey = "pending_views"
+
+// viewedKey marks that a user's view of a post was already counted.
+func viewedKey(postID, userID string) string {
+    return fmt.Sprintf("viewed:%s:%s", postID, userID)
+}
+
+// RecordView counts the caller's view of a post, once per VIEW_DEDUP_WINDOW
+// so refreshes do not inflate it. Counted views are buffered in Redis and
+// flushed to viewsCount by runViewFlush; the returned count includes the
+// buffered ones.
+func (fs *FeedService) RecordView(c *gin.Context) {
+    viewerID := requestUserID(c)
+    if viewerID == "" {
+        respondError(c, http.StatusUnauthorized, ErrCodeUnauthorized, "userId required")
+        return
+    }
+    postID, err := primitive.ObjectIDFromHex(c.Param("id"))
+    if err != nil {
+        respondError(c, http.StatusBadRequest, ErrCodeInvalidRequest, "Invalid post id")
+        return
+    }
+
+    post, ok := fs.loadVisiblePost(c, postID)
+    if !ok {
+        return
+    }
+
+    ctx, cancel := fs.opContext(c.Request.Context())
+    defer cancel()
+
+    counted, err := fs.redis.SetNX(ctx, viewedKey(postID.Hex(), viewerID), 1, fs.viewDedupWindow).Result()
+    if err != nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to record view")
+        return
+    }
+
+    var pending int64
+    if counted {
+        pending, err = fs.redis.HIncrBy(ctx, pendingViewsKey, postID.Hex(), 1).Result()
+    } else {
+        pending, err = fs.redis.HGet(ctx, pendingViewsKey, postID.Hex()).Int64()
+    }
+    if err != nil && err != redis.Nil {
+        respondFetchError(c, err, ErrCodeWriteFailed, "Failed to record view")
+        return
+    }
+
+    respondJSON(c, http.StatusOK, gin.H{
+        "success":    true,
+        "postId":     postID.Hex(),
+        "counted":    counted,
+        "viewsCount": int64(post.ViewsCount) + pending,
+    })
+}
+
+// runViewFlush periodically moves buffered views into Mongo every
+// VIEW_FLUSH_INTERVAL. Any instance may flush: the buffer is renamed away
+// atomically, so two flushes never apply the same views.
+func (fs *FeedService) runViewFlush(ctx context.Context) {
+    ticker := time.NewTicker(fs.viewFlushInterval)
+    defer ticker.Stop()
+
+    for {
+        select {
+        case <-ctx.Done():
+            // Anything still buffered stays in Redis for the next instance
+            return
+        case <-ticker.C:
+        }
+        // Not derived from ctx, so shutdown cannot strand a renamed batch
+        flushCtx, cancel := fs.opContext(context.Background())
+        if err := fs.flushViews(flushCtx); err != nil {
+            log.Printf("View flush failed: %v", err)
+        }
+        cancel()
+    }
+}
+
+func (fs *FeedService) flushViews(ctx context.Context) error {
+    batchKey := fmt.Sprintf("%s:flushing:%s", pendingViewsKey, primitive.NewObjectID().Hex())
+    if err := fs.redis.Rename(ctx, pendingViewsKey, batchKey).Err(); err != nil {
+        if err.Error() == "ERR no such key" {
+            return nil
+        }
+        return err
+    }
+
+    counts, err := fs.redis.HGetAll(ctx, batchKey).Result()
+    if err != nil {
+        return err
+    }
+
+    var models []mongo.WriteModel
+    for id, value := range counts {
+        postID, err := primitive.ObjectIDFromHex(id)
+        n, convErr := strconv.ParseInt(value, 10, 64)
+        if err != nil || convErr != nil || n <= 0 {
+            continue
+        }
+        models = append(models, mongo.NewUpdateOneModel().
+            SetFilter(bson.M{"_id": postID}).
+            SetUpdate(bson.M{"$inc": bson.M{"viewsCount": n}}))
+    }
+
+    if len(models) > 0 {
+        if _, err := fs.postsWriteCollection().BulkWrite(ctx, models); err != nil {
+            // Put the batch back so the views are retried next flush; any
+            // part of it that was applied is counted twice, which view counts
+            // can tolerate
+            pipe := fs.redis.Pipeline()
+            for id, value := range counts {
+                if n, convErr := strconv.ParseInt(value, 10, 64); convErr == nil {
+                    pipe.HIncrBy(ctx, pendingViewsKey, id, n)
+                }
+            }
+            pipe.Del(ctx, batchKey)
+            pipe.Exec(ctx)
+            return err
+        }
+    }
+    return fs.redis.Del(ctx, batchKey).Err()
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+    "context"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Post visibility tiers, matching the main app's Post model.
+const (
+    VisibilityPublic       = "public"
+    VisibilityFriends      = "friends"
+    VisibilityCloseFriends = "close_friends"
+    VisibilityPrivate      = "private"
+)
+
+// viewerRelationship is what a viewer's access to other authors' posts
+// depends on. A zero Viewer means an anonymous caller.
+type viewerRelationship struct {
+    Viewer        primitive.ObjectID
+    Friends       []primitive.ObjectID // authors whose friends-only posts are visible
+    CloseFriendOf []primitive.ObjectID // authors who listed the viewer as a close friend
+}
+
+// resolveRelationship loads everything visibilityFilter needs for a viewer.
+func (fs *FeedService) resolveRelationship(ctx context.Context, viewer primitive.ObjectID) (viewerRelationship, error) {
+    rel := viewerRelationship{Viewer: viewer}
+    if viewer.IsZero() {
+        return rel, nil
+    }
+
+    friends, err := fs.friendIDs(ctx, viewer)
+    if err != nil {
+        return rel, err
+    }
+    rel.Friends = friends
+
+    closeFriendOf, err := fs.fetchCloseFriendOf(ctx, viewer)
+    if err != nil {
+        return rel, err
+    }
+    rel.CloseFriendOf = closeFriendOf
+    return rel, nil
+}
+
+// visibilityFilter is the single source of truth for which posts a viewer may
+// read, as a Mongo clause to AND into any post query:
+//
+//   - public posts: everyone
+//   - friends posts: the author and the author's friends
+//   - close_friends posts: the author and members of the author's list
+//   - private posts: the author only
+//
+// Every read path must use it (or canView for already-loaded posts) rather
+// than building its own visibility clause.
+func visibilityFilter(rel viewerRelationship) bson.M {
+    visible := []bson.M{
+        {"visibility": VisibilityPublic},
+    }
+    if !rel.Viewer.IsZero() {
+        visible = append(visible, bson.M{"author": rel.Viewer})
+    }
+    if len(rel.Friends) > 0 {
+        visible = append(visible, bson.M{
+            "visibility": VisibilityFriends,
+            "author":     bson.M{"$in": rel.Friends},
+        })
+    }
+    if len(rel.CloseFriendOf) > 0 {
+        visible = append(visible, bson.M{
+            "visibility": VisibilityCloseFriends,
+            "author":     bson.M{"$in": rel.CloseFriendOf},
+        })
+    }
+    return bson.M{"$or": visible}
+}
+
+// canView applies the same rules as visibilityFilter to a loaded post.
+func canView(post Post, rel viewerRelationship) bool {
+    if !rel.Viewer.IsZero() && post.Author == rel.Viewer {
+        return true
+    }
+    switch post.Visibility {
+    case VisibilityPublic:
+        return true
+    case VisibilityFriends:
+        return containsID(rel.Friends, post.Author)
+    case VisibilityCloseFriends:
+        return containsID(rel.CloseFriendOf, post.Author)
+    default:
+        return false
+    }
+}
+
+// visibleTo keeps the posts rel's viewer may see, for results shared
+// between viewers that cannot carry visibilityFilter in their query.
+func visibleTo(posts []Post, rel viewerRelationship) []Post {
+    kept := posts[:0:0]
+    for _, post := range posts {
+        if canView(post, rel) {
+            kept = append(kept, post)
+        }
+    }
+    return kept
+}
+
+func containsID(ids []primitive.ObjectID, id primitive.ObjectID) bool {
+    for _, candidate := range ids {
+        if candidate == id {
+            return true
+        }
+    }
+    return false
+}
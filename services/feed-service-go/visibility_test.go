@@ -0,0 +1,116 @@
+package main
+
+import (
+    "fmt"
+    "testing"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// matchesFilter evaluates the subset of Mongo query syntax visibilityFilter
+// emits against a post, failing the test on anything else so a new operator
+// cannot slip past the comparison with canView.
+func matchesFilter(t *testing.T, filter bson.M, post Post) bool {
+    t.Helper()
+    fields := map[string]interface{}{
+        "visibility": post.Visibility,
+        "author":     post.Author,
+    }
+    for key, want := range filter {
+        if key == "$or" {
+            matched := false
+            for _, clause := range want.([]bson.M) {
+                if matchesFilter(t, clause, post) {
+                    matched = true
+                }
+            }
+            if !matched {
+                return false
+            }
+            continue
+        }
+        got, ok := fields[key]
+        if !ok {
+            t.Fatalf("filter uses unsupported field %q", key)
+        }
+        switch want := want.(type) {
+        case bson.M:
+            in, ok := want["$in"].([]primitive.ObjectID)
+            if len(want) != 1 || !ok {
+                t.Fatalf("filter uses unsupported operator %v", want)
+            }
+            if !containsID(in, got.(primitive.ObjectID)) {
+                return false
+            }
+        default:
+            if got != want {
+                return false
+            }
+        }
+    }
+    return true
+}
+
+func TestVisibilityFilterMatchesCanView(t *testing.T) {
+    author := primitive.NewObjectID()
+    viewer := primitive.NewObjectID()
+    other := primitive.NewObjectID()
+
+    relationships := []struct {
+        name string
+        rel  viewerRelationship
+    }{
+        {"anonymous", viewerRelationship{}},
+        {"author", viewerRelationship{Viewer: author}},
+        {"stranger", viewerRelationship{Viewer: viewer}},
+        {"stranger with other friends", viewerRelationship{Viewer: viewer, Friends: []primitive.ObjectID{other}, CloseFriendOf: []primitive.ObjectID{other}}},
+        {"friend", viewerRelationship{Viewer: viewer, Friends: []primitive.ObjectID{author}}},
+        {"close friend", viewerRelationship{Viewer: viewer, Friends: []primitive.ObjectID{author}, CloseFriendOf: []primitive.ObjectID{author}}},
+        {"listed but not a friend", viewerRelationship{Viewer: viewer, CloseFriendOf: []primitive.ObjectID{author}}},
+    }
+    visibilities := []string{VisibilityPublic, VisibilityFriends, VisibilityCloseFriends, VisibilityPrivate, "unknown"}
+
+    // want[relationship][visibility], in the order declared above
+    want := map[string][]bool{
+        "anonymous":                   {true, false, false, false, false},
+        "author":                      {true, true, true, true, true},
+        "stranger":                    {true, false, false, false, false},
+        "stranger with other friends": {true, false, false, false, false},
+        "friend":                      {true, true, false, false, false},
+        "close friend":                {true, true, true, false, false},
+        "listed but not a friend":     {true, false, true, false, false},
+    }
+
+    for _, r := range relationships {
+        filter := visibilityFilter(r.rel)
+        for i, visibility := range visibilities {
+            t.Run(fmt.Sprintf("%s/%s", r.name, visibility), func(t *testing.T) {
+                post := Post{Author: author, Visibility: visibility}
+                if got := canView(post, r.rel); got != want[r.name][i] {
+                    t.Errorf("canView = %v, want %v", got, want[r.name][i])
+                }
+                if got := matchesFilter(t, filter, post); got != want[r.name][i] {
+                    t.Errorf("visibilityFilter matches = %v, want %v", got, want[r.name][i])
+                }
+            })
+        }
+    }
+}
+
+func TestVisibleToKeepsOrder(t *testing.T) {
+    author := primitive.NewObjectID()
+    rel := viewerRelationship{Viewer: primitive.NewObjectID(), Friends: []primitive.ObjectID{author}}
+    posts := []Post{
+        {ID: primitive.NewObjectID(), Author: author, Visibility: VisibilityFriends},
+        {ID: primitive.NewObjectID(), Author: author, Visibility: VisibilityPrivate},
+        {ID: primitive.NewObjectID(), Author: author, Visibility: VisibilityPublic},
+    }
+    kept := visibleTo(posts, rel)
+    if len(kept) != 2 || kept[0].ID != posts[0].ID || kept[1].ID != posts[2].ID {
+        t.Fatalf("visibleTo kept %v, want the friends and public posts in order", kept)
+    }
+    if posts[1].Visibility != VisibilityPrivate {
+        t.Fatal("visibleTo modified its input")
+    }
+}
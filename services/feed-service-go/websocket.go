@@ -0,0 +1,344 @@
+package main
+
+import (
+    "bytes"
+    "compress/flate"
+    "compress/gzip"
+    "container/list"
+    "encoding/json"
+    "io"
+    "context"
+    "log"
+    "strings"
+    "time"
+
+    "github.com/go-redis/redis/v8"
+    "github.com/gorilla/websocket"
+)
+
+// tokenBucket is a minimal token bucket used to cap how fast a single
+// WebSocket client may send us frames. It is owned by the connection's
+// reader goroutine and is therefore not safe for concurrent use.
+type tokenBucket struct {
+    rate   float64 // tokens added per second
+    burst  float64 // bucket capacity
+    tokens float64
+    last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+    if burst < 1 {
+        burst = 1
+    }
+    return &tokenBucket{
+        rate:   rate,
+        burst:  float64(burst),
+        tokens: float64(burst),
+        last:   time.Now(),
+    }
+}
+
+// Allow consumes one token, reporting false when the bucket is empty.
+func (b *tokenBucket) Allow() bool {
+    now := time.Now()
+    b.tokens += now.Sub(b.last).Seconds() * b.rate
+    if b.tokens > b.burst {
+        b.tokens = b.burst
+    }
+    b.last = now
+
+    if b.tokens < 1 {
+        return false
+    }
+    b.tokens--
+    return true
+}
+
+// readClientMessages drains frames sent by the client and closes done when the
+// connection ends. A client exceeding the configured message rate is
+// disconnected with a policy-violation close code so it cannot churn the
+// control-message parser or Redis subscriptions.
+//
+// The read deadline is pushed out by WS_PONG_TIMEOUT on every pong or frame,
+// so a client that vanished without closing (typically a mobile client whose
+// NAT mapping expired) fails the read and releases its subscription.
+func (fs *FeedService) readClientMessages(conn *websocket.Conn, userID string, done chan struct{}, controls chan<- clientControl) {
+    defer close(done)
+
+    extend := func() error {
+        return conn.SetReadDeadline(time.Now().Add(fs.wsPongTimeout))
+    }
+    extend()
+    conn.SetPongHandler(func(string) error { return extend() })
+
+    limiter := newTokenBucket(fs.wsMessageRate, fs.wsMessageBurst)
+    for {
+        _, data, err := conn.ReadMessage()
+        if err != nil {
+            return
+        }
+        extend()
+
+        if !limiter.Allow() {
+            log.Printf("WebSocket message rate exceeded for user: %s", userID)
+            closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "message rate exceeded")
+            conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+            return
+        }
+
+        var control clientControl
+        if json.Unmarshal(data, &control) == nil && (control.Type != "" || control.Action != "") {
+            select {
+            case controls <- control:
+            default: // the connection loop is behind; the client will ask again
+            }
+        }
+    }
+}
+
+// clientControl is a message from the client: a backlog control in Type, or
+// a subscribe/unsubscribe Action naming Channels.
+type clientControl struct {
+    Type     string   `json:"type,omitempty"`
+    Action   string   `json:"action,omitempty"`
+    Channels []string `json:"channels,omitempty"`
+}
+
+// subscribableChannels are the channels a connection may multiplex. Only the
+// user's own streams qualify, so a client cannot listen in on someone else.
+func subscribableChannels(userID string) []string {
+    return []string{userFeedChannel(userID), notificationsChannel(userID)}
+}
+
+// updateSubscriptions applies a subscribe or unsubscribe action to the
+// connection's pub/sub and returns the frame acknowledging it. Requested
+// channels the user may not join are reported back as rejected.
+func (fs *FeedService) updateSubscriptions(pubsub *redis.PubSub, userID string, control clientControl) string {
+    allowed := subscribableChannels(userID)
+    var accepted, rejected []string
+    for _, channel := range control.Channels {
+        if containsString(allowed, channel) {
+            accepted = append(accepted, channel)
+        } else {
+            rejected = append(rejected, channel)
+        }
+    }
+
+    ack := struct {
+        Type     string   `json:"type"`
+        Channels []string `json:"channels"`
+        Rejected []string `json:"rejected,omitempty"`
+        Error    string   `json:"error,omitempty"`
+    }{Type: control.Action + "d", Channels: accepted, Rejected: rejected}
+    if ack.Channels == nil {
+        ack.Channels = []string{}
+    }
+
+    if len(accepted) > 0 {
+        ctx, cancel := fs.opContext(context.Background())
+        defer cancel()
+        var err error
+        if control.Action == "subscribe" {
+            err = pubsub.Subscribe(ctx, accepted...)
+        } else {
+            err = pubsub.Unsubscribe(ctx, accepted...)
+        }
+        if err != nil {
+            log.Printf("WebSocket %s failed for user %s: %v", control.Action, userID, err)
+            ack.Error = control.Action + " failed"
+        }
+    }
+
+    payload, _ := json.Marshal(ack)
+    return string(payload)
+}
+
+// tagChannel adds the source channel to a forwarded event, so a client
+// multiplexing several channels over one socket can route it.
+func tagChannel(channel, payload string) string {
+    if len(payload) < 2 || payload[0] != '{' {
+        return payload
+    }
+    tag, _ := json.Marshal(channel)
+    if strings.TrimSpace(payload[1:]) == "}" {
+        return `{"channel":` + string(tag) + `}`
+    }
+    return `{"channel":` + string(tag) + `,` + payload[1:]
+}
+
+// recentEvents is a fixed-size LRU of event IDs already delivered on a
+// connection. Fan-out can publish the same event to several channels a client
+// is subscribed to; this keeps it from being shown twice.
+type recentEvents struct {
+    size  int
+    order *list.List
+    ids   map[string]*list.Element
+}
+
+func newRecentEvents(size int) *recentEvents {
+    return &recentEvents{
+        size:  size,
+        order: list.New(),
+        ids:   make(map[string]*list.Element),
+    }
+}
+
+// Seen records id and reports whether it was already delivered. Events
+// without an id are never considered duplicates.
+func (r *recentEvents) Seen(id string) bool {
+    if id == "" || r.size <= 0 {
+        return false
+    }
+    if elem, ok := r.ids[id]; ok {
+        r.order.MoveToFront(elem)
+        return true
+    }
+
+    r.ids[id] = r.order.PushFront(id)
+    if r.order.Len() > r.size {
+        oldest := r.order.Back()
+        r.order.Remove(oldest)
+        delete(r.ids, oldest.Value.(string))
+    }
+    return false
+}
+
+// eventID extracts the stable "id" field of an event envelope.
+func eventID(payload string) string {
+    var envelope struct {
+        ID string `json:"id"`
+    }
+    json.Unmarshal([]byte(payload), &envelope)
+    return envelope.ID
+}
+
+// pubsubDropLogInterval bounds how often dropped pub/sub messages are logged.
+const pubsubDropLogInterval = 10 * time.Second
+
+// pumpPubSub moves messages from a Redis subscription into a buffered channel
+// of WS_PUBSUB_BUFFER messages, counting what it has to drop when the
+// connection loop falls behind. The channel is closed when the subscription
+// ends.
+//
+// Live delivery has two tuning knobs:
+//   - WS_PUBSUB_BUFFER absorbs bursts on a channel before anything is dropped;
+//     drops show up in feed_pubsub_dropped_total.
+//   - WS_SEND_BUFFER / WS_OVERFLOW_POLICY absorb a slow client socket; drops
+//     and disconnects show up in feed_ws_dropped_frames_total and
+//     feed_ws_slow_consumer_disconnects_total.
+//
+// Pub/sub drops with an idle send buffer mean bursts are too large for the
+// pub/sub buffer; send-buffer drops mean the client itself is slow.
+func (fs *FeedService) pumpPubSub(pubsub *redis.PubSub) <-chan *redis.Message {
+    out := make(chan *redis.Message, fs.wsPubSubBuffer)
+
+    go func() {
+        defer close(out)
+        for {
+            msg, err := pubsub.ReceiveMessage(context.Background())
+            if err != nil {
+                return
+            }
+
+            select {
+            case out <- msg:
+            default:
+                fs.metrics.pubsubDropped.Inc()
+                fs.logPubSubDrop(msg.Channel)
+            }
+        }
+    }()
+    return out
+}
+
+// logPubSubDrop logs at most once per pubsubDropLogInterval across all connections.
+func (fs *FeedService) logPubSubDrop(channel string) {
+    now := time.Now().UnixNano()
+    last := fs.pubsubDropLoggedAt.Load()
+    if now-last < int64(pubsubDropLogInterval) || !fs.pubsubDropLoggedAt.CompareAndSwap(last, now) {
+        return
+    }
+    log.Printf("Dropping pub/sub messages for %s: connection not keeping up (see feed_pubsub_dropped_total)", channel)
+}
+
+// writeFrames is the connection's only data writer. It drains send until the
+// channel is closed and signals failed on the first write error.
+func (fs *FeedService) writeFrames(conn *websocket.Conn, send <-chan string, failed chan struct{}) {
+    defer close(failed)
+    for payload := range send {
+        frameType, frame := fs.encodeFrame(payload)
+        if err := conn.WriteMessage(frameType, frame); err != nil {
+            log.Printf("WebSocket write error: %v", err)
+            return
+        }
+    }
+}
+
+// enqueueFrame buffers an outgoing payload without blocking. When the buffer
+// is full the "drop-oldest" policy discards the oldest queued frame to make
+// room, while "disconnect" reports false so the caller drops the client.
+func (fs *FeedService) enqueueFrame(send chan string, payload string) bool {
+    select {
+    case send <- payload:
+        return true
+    default:
+    }
+
+    if fs.wsOverflowPolicy == "disconnect" {
+        fs.metrics.wsSlowDisconnects.Inc()
+        return false
+    }
+
+    select {
+    case <-send:
+        fs.metrics.wsDroppedFrames.Inc()
+    default:
+    }
+    select {
+    case send <- payload:
+    default:
+        fs.metrics.wsDroppedFrames.Inc()
+    }
+    return true
+}
+
+// Scheme bytes prefixed to compressed binary frames.
+const (
+    frameSchemeGzip    byte = 1
+    frameSchemeDeflate byte = 2
+)
+
+// encodeFrame prepares a forwarded Redis payload for the socket. Payloads at
+// or above the configured threshold are compressed and sent as a binary frame
+// whose first byte names the scheme (1 = gzip, 2 = raw deflate); everything
+// else goes out as a plain text frame. The frame type is therefore the flag
+// telling the client whether to decompress.
+//
+// Unlike permessage-deflate, which compresses every frame and needs support in
+// the client library, this only spends CPU on large events (full post JSON)
+// and works with any client that can inflate a byte slice. Prefer
+// permessage-deflate (ENABLE_COMPRESSION) when clients support it and most
+// frames are large, and then leave this off: a frame gzipped here gains
+// nothing from being deflated again. BenchmarkEncodeFrame measures both on a
+// typical event mix.
+func (fs *FeedService) encodeFrame(payload string) (int, []byte) {
+    if fs.wsCompressThreshold <= 0 || len(payload) < fs.wsCompressThreshold {
+        return websocket.TextMessage, []byte(payload)
+    }
+
+    var buf bytes.Buffer
+    var w io.WriteCloser
+    switch fs.wsCompressScheme {
+    case "deflate":
+        buf.WriteByte(frameSchemeDeflate)
+        w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+    default:
+        buf.WriteByte(frameSchemeGzip)
+        w = gzip.NewWriter(&buf)
+    }
+
+    if _, err := io.WriteString(w, payload); err != nil || w.Close() != nil {
+        return websocket.TextMessage, []byte(payload)
+    }
+    return websocket.BinaryMessage, buf.Bytes()
+}
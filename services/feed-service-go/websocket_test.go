@@ -0,0 +1,270 @@
+package main
+
+import (
+    "bytes"
+    "compress/flate"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "github.com/go-redis/redis/v8"
+    "github.com/gorilla/websocket"
+)
+
+// dialReader serves readClientMessages for one connection and dials it.
+func dialReader(t *testing.T, fs *FeedService) *websocket.Conn {
+    t.Helper()
+    server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        conn, err := fs.upgrader.Upgrade(w, r, nil)
+        if err != nil {
+            return
+        }
+        defer conn.Close()
+        done := make(chan struct{})
+        fs.readClientMessages(conn, "u1", done, make(chan clientControl, 4))
+    }))
+    t.Cleanup(server.Close)
+
+    conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    t.Cleanup(func() { conn.Close() })
+    return conn
+}
+
+func TestReadClientMessagesDisconnectsFlood(t *testing.T) {
+    fs := newTestService(t, nil)
+    fs.wsMessageRate = 1
+    fs.wsMessageBurst = 5
+    conn := dialReader(t, fs)
+
+    for i := 0; i < 20; i++ {
+        // The server may already have closed; the read below reports why
+        if conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"backlog_next"}`)) != nil {
+            break
+        }
+    }
+
+    conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+    for {
+        _, _, err := conn.ReadMessage()
+        if err == nil {
+            continue
+        }
+        var closeErr *websocket.CloseError
+        if !errors.As(err, &closeErr) {
+            t.Fatalf("read failed with %v, want a close frame", err)
+        }
+        if closeErr.Code != websocket.ClosePolicyViolation {
+            t.Fatalf("close code %d, want %d (policy violation)", closeErr.Code, websocket.ClosePolicyViolation)
+        }
+        return
+    }
+}
+
+func TestReadClientMessagesAllowsBurst(t *testing.T) {
+    fs := newTestService(t, nil)
+    fs.wsMessageRate = 1
+    fs.wsMessageBurst = 5
+    conn := dialReader(t, fs)
+
+    for i := 0; i < fs.wsMessageBurst; i++ {
+        if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"backlog_next"}`)); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+    _, _, err := conn.ReadMessage()
+    var netErr interface{ Timeout() bool }
+    if !errors.As(err, &netErr) || !netErr.Timeout() {
+        t.Fatalf("read returned %v, want the connection to stay open", err)
+    }
+}
+
+func TestTokenBucketRefills(t *testing.T) {
+    bucket := newTokenBucket(10, 2)
+    if !bucket.Allow() || !bucket.Allow() {
+        t.Fatal("burst not available up front")
+    }
+    if bucket.Allow() {
+        t.Fatal("allowed past the burst")
+    }
+    bucket.last = bucket.last.Add(-200 * time.Millisecond)
+    if !bucket.Allow() {
+        t.Fatal("bucket did not refill at the configured rate")
+    }
+}
+
+func TestRecentEventsEvictsOldest(t *testing.T) {
+    seen := newRecentEvents(2)
+    for _, id := range []string{"a", "b"} {
+        if seen.Seen(id) {
+            t.Fatalf("%s reported as a duplicate on first delivery", id)
+        }
+    }
+    if !seen.Seen("a") {
+        t.Fatal("a not reported as a duplicate")
+    }
+    seen.Seen("c") // evicts b, the least recently seen
+    if seen.Seen("b") {
+        t.Fatal("b survived eviction")
+    }
+    if seen.Seen("") || seen.Seen("") {
+        t.Fatal("events without an id must never be duplicates")
+    }
+}
+
+func feedEventPayload(id string) string {
+    return fmt.Sprintf(`{"id":%q,"type":"new_post","data":{},"timestamp":"2026-03-01T12:00:00.000Z"}`, id)
+}
+
+// TestWebSocketDeliversEventsOnce reconnects with lastEventId, so e1 arrives
+// in the backlog, then republishes e1 and publishes e2 twice live. Each must
+// reach the client exactly once.
+func TestWebSocketDeliversEventsOnce(t *testing.T) {
+    fs := newTestService(t, nil)
+    fs.wsBacklogMax = 200
+    fs.wsStreamTTL = time.Hour
+    useMiniredis(t, fs)
+    ctx := context.Background()
+
+    for _, id := range []string{"e0", "e1"} {
+        err := fs.redis.XAdd(ctx, &redis.XAddArgs{
+            Stream: userFeedStreamKey("u1"),
+            Values: map[string]interface{}{"id": id, "event": feedEventPayload(id)},
+        }).Err()
+        if err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    router := gin.New()
+    router.GET("/ws", fs.HandleWebSocket)
+    server := httptest.NewServer(router)
+    defer server.Close()
+    conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws?userId=u1&lastEventId=e0", nil)
+    if err != nil {
+        t.Fatal(err)
+    }
+    defer conn.Close()
+    conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+    var page backlogPage
+    if err := conn.ReadJSON(&page); err != nil {
+        t.Fatal(err)
+    }
+    if page.Type != "backlog_page" || len(page.Events) != 1 || eventID(string(page.Events[0])) != "e1" {
+        t.Fatalf("backlog = %+v, want e1 alone", page)
+    }
+
+    // The backlog is only sent once the subscription is confirmed
+    for _, id := range []string{"e1", "e2", "e2", "e3"} {
+        if err := fs.redis.Publish(ctx, userFeedChannel("u1"), feedEventPayload(id)).Err(); err != nil {
+            t.Fatal(err)
+        }
+    }
+
+    var live []string
+    for len(live) == 0 || live[len(live)-1] != "e3" {
+        _, data, err := conn.ReadMessage()
+        if err != nil {
+            t.Fatalf("read after %v: %v", live, err)
+        }
+        var envelope struct {
+            ID string `json:"id"`
+        }
+        json.Unmarshal(data, &envelope)
+        live = append(live, envelope.ID)
+    }
+    if strings.Join(live, ",") != "e2,e3" {
+        t.Fatalf("live events %v, want e2,e3", live)
+    }
+}
+
+// Representative forwarded payloads: a reaction event and a full post.
+var (
+    smallFramePayload = `{"type":"reaction","postId":"652f1c2ab1e4a0d3c1a9e001","userId":"u1","reaction":"like","count":12}`
+    largeFramePayload = `{"type":"new_post","post":{"content":"` + strings.Repeat("Crown social update with some repeated words. ", 160) + `"}}`
+)
+
+func TestEncodeFrameRoundTrip(t *testing.T) {
+    fs := newTestService(t, nil)
+    fs.wsCompressThreshold = 1024
+
+    frameType, frame := fs.encodeFrame(smallFramePayload)
+    if frameType != websocket.TextMessage || string(frame) != smallFramePayload {
+        t.Fatalf("small payload not sent as plain text")
+    }
+
+    for _, scheme := range []string{"gzip", "deflate"} {
+        fs.wsCompressScheme = scheme
+        frameType, frame := fs.encodeFrame(largeFramePayload)
+        if frameType != websocket.BinaryMessage || len(frame) >= len(largeFramePayload) {
+            t.Fatalf("%s: large payload not compressed (%d bytes)", scheme, len(frame))
+        }
+        var r io.Reader
+        switch frame[0] {
+        case frameSchemeGzip:
+            gz, err := gzip.NewReader(bytes.NewReader(frame[1:]))
+            if err != nil {
+                t.Fatal(err)
+            }
+            r = gz
+        case frameSchemeDeflate:
+            r = flate.NewReader(bytes.NewReader(frame[1:]))
+        default:
+            t.Fatalf("%s: unknown scheme byte %d", scheme, frame[0])
+        }
+        decoded, err := io.ReadAll(r)
+        if err != nil || string(decoded) != largeFramePayload {
+            t.Fatalf("%s: round trip failed: %v", scheme, err)
+        }
+    }
+}
+
+// BenchmarkEncodeFrame compares selective compression (only frames at or
+// over the threshold) with compressing every frame, as permessage-deflate
+// does, on a mix of nine small events to one large post. wire/raw is the
+// share of payload bytes left to send. gorilla pools its flate writers, so
+// every-frame overstates permessage-deflate's allocations but not the CPU
+// spent deflating small frames.
+func BenchmarkEncodeFrame(b *testing.B) {
+    payloads := make([]string, 0, 10)
+    for i := 0; i < 9; i++ {
+        payloads = append(payloads, smallFramePayload)
+    }
+    payloads = append(payloads, largeFramePayload)
+
+    for _, bc := range []struct {
+        name      string
+        threshold int
+    }{
+        {"none", 0},
+        {"selective", 1024},
+        {"every-frame", 1},
+    } {
+        b.Run(bc.name, func(b *testing.B) {
+            fs := &FeedService{wsCompressThreshold: bc.threshold, wsCompressScheme: "deflate"}
+            var in, out int
+            b.ReportAllocs()
+            for i := 0; i < b.N; i++ {
+                payload := payloads[i%len(payloads)]
+                _, frame := fs.encodeFrame(payload)
+                in += len(payload)
+                out += len(frame)
+            }
+            b.ReportMetric(float64(out)/float64(in), "wire/raw")
+        })
+    }
+}